@@ -0,0 +1,178 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/batch"
+	"bk-iam-cli/pkg/client"
+	"bk-iam-cli/pkg/logger"
+)
+
+// the commands supported in batch
+const (
+	batchCommandPolicy  = "policy"
+	batchCommandCheck   = "check"
+	batchCommandSubject = "subject"
+	batchCommandCache   = "cache"
+)
+
+// batchCmd represents the batch command
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Run the queries in a csv/jsonl file concurrently",
+	Long: `Run the queries in a csv/jsonl file concurrently, e.g. check dozens of users against the same action.
+The failure of a row does not abort the batch, the error is in the output of the row,
+and a summary of the successes/failures is printed to stderr at the end.
+
+The commands:
+  policy:  query policy {subject_type} {subject_id} {action}, the result is the expression
+  check:   check {subject_type} {subject_id} {action} --resource {resource}..., the result is allowed or not
+  subject: query subject {subject_type} {subject_id}
+  cache:   cache policy {subject_type} {subject_id} {action}
+
+csv, the header and the lines start with # are skipped, the columns after action are the resources:
+  command,subject_type,subject_id,action,resource
+  policy,user,tom,project_view
+  check,user,tom,project_view,project:42
+
+jsonl:
+  {"command":"check","subject_type":"user","subject_id":"tom","action":"project_view","resources":["project:42"]}
+
+batch --file requests.csv --concurrency 8 --rate 20
+batch --file requests.jsonl --format csv --out results.csv
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		file, _ := cmd.Flags().GetString("file")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		rate, _ := cmd.Flags().GetFloat64("rate")
+		format, _ := cmd.Flags().GetString("format")
+		out, _ := cmd.Flags().GetString("out")
+
+		if format != batch.FormatJSONL && format != batch.FormatCSV {
+			logger.Error("unsupported format `%s`, should be jsonl or csv", format)
+			return
+		}
+
+		rows, err := readBatchRows(file)
+		if err != nil {
+			logger.Error("read rows fail! %s", err.Error())
+			return
+		}
+
+		client, system, err := newSystemBackendClient()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		runner := batch.Runner{Concurrency: concurrency, Rate: rate}
+		results := runner.Run(rows, func(row batch.Row) (interface{}, error) {
+			return executeBatchRow(client, system, row)
+		})
+
+		var w io.Writer = os.Stdout
+		if out != "" {
+			f, err := os.Create(out)
+			if err != nil {
+				logger.Error("create output file fail! %s", err.Error())
+				return
+			}
+			defer f.Close()
+			w = f
+		}
+		if err = batch.Write(w, format, results); err != nil {
+			logger.Error("write results fail! %s", err.Error())
+			return
+		}
+
+		// the summary to stderr, keep the stdout parseable
+		s := batch.Summarize(results)
+		fmt.Fprintf(os.Stderr, "total: %d, success: %d, failure: %d\n", s.Total, s.Success, s.Failure)
+	},
+}
+
+func readBatchRows(file string) ([]batch.Row, error) {
+	if file == "" {
+		return nil, errors.New("--file is required")
+	}
+	if file == "-" {
+		return batch.ReadRows(os.Stdin)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return batch.ReadRows(f)
+}
+
+// executeBatchRow runs the command of the row, returns the result data
+func executeBatchRow(c client.IAMBackendClient, system string, row batch.Row) (interface{}, error) {
+	if row.Command != batchCommandSubject && row.Action == "" {
+		return nil, fmt.Errorf("action is required by command %s", row.Command)
+	}
+
+	switch row.Command {
+	case batchCommandPolicy:
+		expr, err := c.GetPolicyExpression(system, row.SubjectType, row.SubjectID, row.Action, apiForceEnabled())
+		if err != nil {
+			return nil, err
+		}
+		return expr.String(), nil
+	case batchCommandCheck:
+		if len(row.Resources) == 0 {
+			return nil, errors.New("resources are required by command check")
+		}
+		result, err := checkPermission(c, system, row.SubjectType, row.SubjectID, row.Action, row.Resources)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"allowed":     result.Allowed,
+			"expression":  result.Expression,
+			"granted_via": result.GrantedVia,
+		}, nil
+	case batchCommandSubject:
+		subject, err := c.GetSubject(row.SubjectType, row.SubjectID)
+		if err != nil {
+			return nil, err
+		}
+		return subject, nil
+	case batchCommandCache:
+		cached, err := c.GetCachePolicy(system, row.SubjectType, row.SubjectID, row.Action)
+		if err != nil {
+			return nil, err
+		}
+		return cached, nil
+	default:
+		return nil, fmt.Errorf("unsupported command `%s`, should be one of policy/check/subject/cache", row.Command)
+	}
+}
+
+func init() {
+	batchCmd.Flags().StringP("file", "f", "", "the csv/jsonl file of the rows, - for stdin")
+	batchCmd.Flags().Int("concurrency", 4, "the number of the concurrent workers")
+	batchCmd.Flags().Float64("rate", 0, "the max rows per second, 0 for no limit; a check row may send 2 requests")
+	batchCmd.Flags().String("format", batch.FormatJSONL, "the output format, jsonl or csv")
+	batchCmd.Flags().String("out", "", "write the results to the file instead of stdout")
+	addForceFlag(batchCmd)
+
+	rootCmd.AddCommand(batchCmd)
+}
@@ -17,9 +17,8 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"bk-iam-cli/pkg/client"
 	"bk-iam-cli/pkg/logger"
-	"bk-iam-cli/pkg/storage"
+	"bk-iam-cli/pkg/printer"
 )
 
 // cacheCmd represents the cache command
@@ -43,21 +42,18 @@ var cacheCmd = &cobra.Command{
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		system, err := storage.ReadUseSystem()
+		system, err := readUseSystem()
 		if err != nil {
 			logger.Error(err.Error())
 			return
 		}
 
-		credential := storage.NewCredential(backendCredentialFile)
-		host, appCode, appSecret, err := credential.Read()
+		client, _, err := newBackendClient()
 		if err != nil {
 			logger.Error(err.Error())
 			return
 		}
 
-		client := client.NewIAMBackendClient(host, "", appCode, appSecret)
-
 		switch args[0] {
 		case "policy":
 
@@ -79,7 +75,7 @@ var cacheCmd = &cobra.Command{
 				return
 			}
 			// NOTE: notInCache=false, 可能是in cache but expired
-			logger.PrettyJson(data)
+			printResult(printer.KindCachePolicy, data)
 		case "expression":
 			// cache-query expression 查询缓存中的表达式; 参数: pks=1,2,3,4
 			if len(args) < 2 {
@@ -102,7 +98,7 @@ var cacheCmd = &cobra.Command{
 				logger.Error("cache expression pks fail!", err.Error())
 				return
 			}
-			logger.PrettyJson(data)
+			printResult(printer.KindCacheExpression, data)
 		default:
 			logger.Error("not support yet")
 		}
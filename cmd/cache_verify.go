@@ -0,0 +1,367 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/client"
+	"bk-iam-cli/pkg/expression"
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/model"
+	"bk-iam-cli/pkg/printer"
+)
+
+// the kinds of the inconsistency between the cache and the database
+const (
+	// in database but not in cache
+	findingMissing = "missing"
+	// in cache but not in database, or outdated
+	findingStale = "stale"
+	// both in cache and database, but different
+	findingMismatch = "mismatch"
+	// in cache but expired, the `notInCache=false` may be this
+	findingExpired = "expired"
+	// the policies of the action not in cache, nothing to compare
+	findingNotInCache = "not_in_cache"
+)
+
+type cacheFinding struct {
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// inconsistent returns true if the cache is different from the database
+func (f cacheFinding) inconsistent() bool {
+	return f.Kind == findingMissing || f.Kind == findingStale || f.Kind == findingMismatch
+}
+
+type cacheVerifyAction struct {
+	Action     string         `json:"action"`
+	Consistent bool           `json:"consistent"`
+	Findings   []cacheFinding `json:"findings"`
+	Error      string         `json:"error,omitempty"`
+}
+
+type cacheVerifyResult struct {
+	System     string `json:"system"`
+	Subject    string `json:"subject"`
+	Consistent bool   `json:"consistent"`
+	// the number of the actions failed to verify, e.g. the query fail
+	Failed  int                 `json:"failed"`
+	Actions []cacheVerifyAction `json:"actions"`
+}
+
+var cacheVerifyCmd = &cobra.Command{
+	Use:   "verify [subject_type] [subject_id] [action]",
+	Short: "Compare the policies in cache with the ones in database",
+	Long: `Compare the policies in cache with the ones in database, report the stale, missing or mismatching entries.
+The cached policies are from "cache policy", the cached expressions from "cache expression",
+and the policies in database from "query policy" with force(bypass the cache) and debug.
+All the actions in the cache of the subject are verified if no action specified.
+
+cache verify user tom project_view
+cache verify user tom
+`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 && len(args) != 3 {
+			return errors.New("cache verify {subject_type} {subject_id} [{action_id}]")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		subjectType, subjectID := args[0], args[1]
+
+		client, system, err := newSystemBackendClient()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		actions := args[2:]
+		if len(actions) == 0 {
+			cached, err := client.GetCachePolicy(system, subjectType, subjectID, "")
+			if err != nil {
+				logger.Error("cache policy fail! %s", err.Error())
+				return
+			}
+			for _, a := range cached.Actions {
+				if a.System == "" || a.System == system {
+					actions = append(actions, a.ID)
+				}
+			}
+		}
+
+		result := cacheVerifyResult{
+			System:     system,
+			Subject:    fmt.Sprintf("%s:%s", subjectType, subjectID),
+			Consistent: true,
+			Actions:    make([]cacheVerifyAction, 0, len(actions)),
+		}
+		for _, action := range actions {
+			v := verifyActionCache(client, system, subjectType, subjectID, action)
+			result.Consistent = result.Consistent && v.Consistent
+			if v.Error != "" {
+				result.Failed++
+			}
+			result.Actions = append(result.Actions, v)
+		}
+
+		printCacheVerifyResult(result)
+	},
+}
+
+func verifyActionCache(c client.IAMBackendClient, system, subjectType, subjectID, action string) cacheVerifyAction {
+	v := cacheVerifyAction{Action: action, Findings: []cacheFinding{}}
+	fail := func(format string, err error) cacheVerifyAction {
+		v.Error = fmt.Sprintf(format, err.Error())
+		return v
+	}
+
+	cached, err := c.GetCachePolicy(system, subjectType, subjectID, action)
+	if err != nil {
+		return fail("cache policy fail! %s", err)
+	}
+
+	var cachedExpressions *model.CacheExpression
+	if pks := expressionPKs(cached.Policies); len(pks) > 0 {
+		cachedExpressions, err = c.GetCacheExpression(pks)
+		if err != nil {
+			return fail("cache expression fail! %s", err)
+		}
+	}
+
+	_, debug, err := c.QueryPolicyWithDebug(system, subjectType, subjectID, action, true)
+	if err != nil {
+		return fail("query policy fail! %s", err)
+	}
+	var (
+		policies    []model.CachedPolicy
+		expressions []model.Expression
+	)
+	if err = decodeDebugList(debug, "policies", &policies); err == nil {
+		err = decodeDebugList(debug, "expressions", &expressions)
+	}
+	if err != nil {
+		return fail("invalid debug info of query policy! %s", err)
+	}
+
+	v.Findings = compareCache(cached, cachedExpressions, policies, expressions, time.Now())
+	v.Consistent = true
+	for _, f := range v.Findings {
+		if f.inconsistent() {
+			v.Consistent = false
+		}
+	}
+	return v
+}
+
+func expressionPKs(policies []model.CachedPolicy) []int {
+	seen := map[int64]bool{}
+	pks := []int{}
+	for _, p := range policies {
+		if !seen[p.ExpressionPK] {
+			seen[p.ExpressionPK] = true
+			pks = append(pks, int(p.ExpressionPK))
+		}
+	}
+	return pks
+}
+
+// decodeDebugList decodes the list of the key in the debug steps, e.g. the policies queried from database
+func decodeDebugList(debug map[string]interface{}, key string, v interface{}) error {
+	data, err := json.Marshal(findDebugList(debug, key))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// compareCache compares the cached policies/expressions with the ones in database
+func compareCache(
+	cached *model.CachePolicy,
+	cachedExpressions *model.CacheExpression,
+	policies []model.CachedPolicy,
+	expressions []model.Expression,
+	now time.Time,
+) []cacheFinding {
+	findings := []cacheFinding{}
+	add := func(kind, format string, args ...interface{}) {
+		findings = append(findings, cacheFinding{Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	if cached.NotInCache {
+		add(findingNotInCache, "the policies are not in cache, will be loaded from database by the next query")
+		return findings
+	}
+
+	// 1. the policies
+	cachedPolicies := map[int64]model.CachedPolicy{}
+	for _, p := range cached.Policies {
+		cachedPolicies[p.PK] = p
+	}
+	dbPolicies := map[int64]model.CachedPolicy{}
+	for _, p := range policies {
+		dbPolicies[p.PK] = p
+		if _, ok := cachedPolicies[p.PK]; !ok {
+			add(findingMissing, "policy %d(subject_pk=%d) in database but not in cache", p.PK, p.SubjectPK)
+		}
+	}
+	for _, p := range cached.Policies {
+		db, ok := dbPolicies[p.PK]
+		if !ok {
+			add(findingStale, "policy %d(subject_pk=%d) in cache but not in database", p.PK, p.SubjectPK)
+		} else {
+			if p.ExpressionPK != db.ExpressionPK {
+				add(findingMismatch, "policy %d expression_pk: cache %d, database %d", p.PK, p.ExpressionPK, db.ExpressionPK)
+			}
+			if p.ExpiredAt != db.ExpiredAt {
+				add(findingStale, "policy %d expired_at: cache %s, database %s", p.PK,
+					printer.FormatTimestamp(p.ExpiredAt), printer.FormatTimestamp(db.ExpiredAt))
+			}
+		}
+		if p.ExpiredAt < now.Unix() {
+			add(findingExpired, "policy %d in cache but expired at %s", p.PK, printer.FormatTimestamp(p.ExpiredAt))
+		}
+	}
+
+	// 2. the expressions, the expression cache takes precedence over the ones in the policy cache
+	cachedContents := map[int64]model.Expression{}
+	for _, e := range cached.Expressions {
+		cachedContents[e.PK] = e
+	}
+	if cachedExpressions != nil {
+		for _, e := range cachedExpressions.Expressions {
+			cachedContents[e.PK] = e
+		}
+		for _, pk := range cachedExpressions.NoCachePKs {
+			if containsInt(expressionPKs(cached.Policies), int(pk)) {
+				add(findingMissing, "expression %d not in expression cache", pk)
+			}
+		}
+	}
+
+	pks := make([]int64, 0, len(cachedContents))
+	for pk := range cachedContents {
+		pks = append(pks, pk)
+	}
+	sort.Slice(pks, func(i, j int) bool { return pks[i] < pks[j] })
+
+	dbExpressions := map[int64]model.Expression{}
+	for _, e := range expressions {
+		dbExpressions[e.PK] = e
+	}
+	for _, pk := range pks {
+		db, ok := dbExpressions[pk]
+		if !ok {
+			continue
+		}
+		if detail := diffExpressionContent(cachedContents[pk], db); detail != "" {
+			add(findingMismatch, "expression %d: %s", pk, detail)
+		}
+	}
+	return findings
+}
+
+// diffExpressionContent returns the semantic difference of the cached and the database expression, empty if equal
+func diffExpressionContent(cached, db model.Expression) string {
+	if cached.Expression == db.Expression {
+		return ""
+	}
+
+	c, err := cached.Parse()
+	if err != nil {
+		return fmt.Sprintf("invalid expression in cache, %s", err.Error())
+	}
+	d, err := db.Parse()
+	if err != nil {
+		return fmt.Sprintf("invalid expression in database, %s", err.Error())
+	}
+
+	diff := expression.DiffExpressions(c, d)
+	if diff.Equal() {
+		return ""
+	}
+
+	var parts []string
+	if len(diff.OnlyLeft) > 0 {
+		parts = append(parts, "only in cache: "+joinExpressions(diff.OnlyLeft))
+	}
+	if len(diff.OnlyRight) > 0 {
+		parts = append(parts, "only in database: "+joinExpressions(diff.OnlyRight))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func joinExpressions(es []*expression.Expression) string {
+	texts := make([]string, 0, len(es))
+	for _, e := range es {
+		texts = append(texts, e.String())
+	}
+	return strings.Join(texts, " OR ")
+}
+
+func containsInt(list []int, i int) bool {
+	for _, x := range list {
+		if x == i {
+			return true
+		}
+	}
+	return false
+}
+
+func printCacheVerifyResult(result cacheVerifyResult) {
+	if output != "" {
+		printResult(printer.KindUnknown, result)
+		return
+	}
+
+	fmt.Printf("system: %s, subject: %s\n", result.System, result.Subject)
+	inconsistent := 0
+	for _, a := range result.Actions {
+		switch {
+		case a.Error != "":
+			fmt.Printf("? %s: %s\n", a.Action, a.Error)
+		case a.Consistent:
+			fmt.Printf("✓ %s\n", a.Action)
+		default:
+			fmt.Printf("✗ %s\n", a.Action)
+		}
+		for _, f := range a.Findings {
+			fmt.Printf("  [%s] %s\n", f.Kind, f.Detail)
+			if f.inconsistent() {
+				inconsistent++
+			}
+		}
+	}
+
+	switch {
+	case result.Failed > 0:
+		// NOTE: not consistent, the failed actions are unknown
+		logger.Error("%d actions failed to verify, %d inconsistent entries found", result.Failed, inconsistent)
+	case result.Consistent:
+		logger.Info("the cache is consistent with the database")
+	default:
+		logger.Warn("%d inconsistent entries found", inconsistent)
+	}
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheVerifyCmd)
+}
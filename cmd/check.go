@@ -0,0 +1,346 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/client"
+	"bk-iam-cli/pkg/expression"
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/model"
+	"bk-iam-cli/pkg/printer"
+)
+
+type checkResult struct {
+	Subject    string            `json:"subject"`
+	Action     string            `json:"action"`
+	Resources  []string          `json:"resources"`
+	Allowed    bool              `json:"allowed"`
+	Expression string            `json:"expression"`
+	GrantedVia []string          `json:"granted_via"`
+	Trace      *expression.Trace `json:"trace"`
+}
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check [subject_type] [subject_id] [action]",
+	Short: "Check if the subject has the permission of the action on the resource",
+	Long: `Check if the subject has the permission of the action on the resource,
+query the policy with debug, evaluate it locally, and explain which group/department grants the permission.
+
+check user tom project_view --resource project:42
+check user tom host_view --resource host:1,_bk_iam_path_=/biz,1/set,2/ --resource biz:1
+`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 3 {
+			return errors.New("check {subject_type} {subject_id} {action} --resource {type}:{id}[,{attr}={value}]")
+		}
+		if args[0] != "user" && args[0] != "group" {
+			return errors.New("subject_type should be user or group")
+		}
+		if _, err := strconv.Atoi(args[1]); err != nil && args[0] == "group" {
+			return errors.New("subject_id should be an integer")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		subjectType, subjectID, action := args[0], args[1], args[2]
+		resourceSpecs, _ := cmd.Flags().GetStringArray("resource")
+
+		client, system, err := newSystemBackendClient()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		result, err := checkPermission(client, system, subjectType, subjectID, action, resourceSpecs)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		printCheckResult(result)
+	},
+}
+
+// checkPermission queries the policy with debug, evaluates it with the resources,
+// and explains the granted paths if allowed
+func checkPermission(
+	c client.IAMBackendClient,
+	system, subjectType, subjectID, action string,
+	resourceSpecs []string,
+) (result checkResult, err error) {
+	resource := expression.Resource{}
+	for _, spec := range resourceSpecs {
+		if err = parseResourceSpec(resource, spec); err != nil {
+			return
+		}
+	}
+
+	data, debug, err := c.QueryPolicyWithDebug(system, subjectType, subjectID, action, apiForceEnabled())
+	if err != nil {
+		err = fmt.Errorf("query policy fail! %w", err)
+		return
+	}
+
+	expr, err := expression.FromInterface(data)
+	if err != nil {
+		err = fmt.Errorf("parse policy expression fail! %w", err)
+		return
+	}
+
+	allowed, trace := expression.Eval(expr, resource)
+
+	result = checkResult{
+		Subject:    fmt.Sprintf("%s:%s", subjectType, subjectID),
+		Action:     action,
+		Resources:  resourceSpecs,
+		Allowed:    allowed,
+		Expression: expr.String(),
+		GrantedVia: []string{},
+		Trace:      trace,
+	}
+
+	if allowed {
+		subject, err := c.GetSubject(subjectType, subjectID)
+		if err != nil {
+			logger.Warn("query subject fail, can not explain the permission! %s", err.Error())
+		} else {
+			result.GrantedVia = explainGrantedVia(subject, debug, resource)
+		}
+	}
+	return result, nil
+}
+
+func printCheckResult(result checkResult) {
+	if output != "" {
+		printResult(printer.KindUnknown, result)
+		return
+	}
+
+	fmt.Printf("subject: %s, action: %s, resources: %s\n", result.Subject, result.Action,
+		strings.Join(result.Resources, " "))
+	fmt.Print(result.Trace.String())
+
+	if !result.Allowed {
+		logger.Warn("deny")
+		return
+	}
+
+	logger.Info("allow")
+	if len(result.GrantedVia) == 0 {
+		fmt.Println("granted via: unknown, the debug info contains no policies")
+		return
+	}
+	fmt.Println("granted via:")
+	for _, via := range result.GrantedVia {
+		fmt.Printf("  - %s\n", via)
+	}
+}
+
+// parseResourceSpec parses `{type}:{id}[,{attr}={value}]` into the resource,
+// NOTE: the value may contain comma, e.g. _bk_iam_path_=/biz,1/set,2/
+func parseResourceSpec(resource expression.Resource, spec string) error {
+	invalid := fmt.Errorf("invalid resource `%s`, should be {type}:{id}[,{attr}={value}]", spec)
+
+	idx := strings.Index(spec, ":")
+	if idx <= 0 {
+		return invalid
+	}
+	_type, rest := spec[:idx], spec[idx+1:]
+
+	parts := strings.Split(rest, ",")
+	attrs := make([]string, 0, len(parts))
+	for i, p := range parts {
+		// the first part is the id, the part without `=` belongs to the previous value
+		if i == 0 || strings.Contains(p, "=") {
+			attrs = append(attrs, p)
+			continue
+		}
+		attrs[len(attrs)-1] += "," + p
+	}
+
+	if attrs[0] == "" || strings.Contains(attrs[0], "=") {
+		return invalid
+	}
+	resource.Set(_type+".id", attrs[0])
+
+	for _, attr := range attrs[1:] {
+		kv := strings.SplitN(attr, "=", 2)
+		resource.Set(_type+"."+kv[0], kv[1])
+	}
+	return nil
+}
+
+// explainGrantedVia returns the paths(subject self/group/department-group) which grant the permission,
+// the policies and expressions are from the debug info of query policy
+func explainGrantedVia(
+	subject *model.SubjectDetail,
+	debug map[string]interface{},
+	resource expression.Resource,
+) []string {
+	paths := subjectPaths(subject)
+
+	expressions := map[string]*expression.Expression{}
+	for _, e := range findDebugList(debug, "expressions") {
+		pk := toText(lookupField(e, "pk"))
+		raw := lookupField(e, "expression")
+		if s, ok := raw.(string); ok && s == "" {
+			// the action without resource types
+			expressions[pk] = &expression.Expression{Op: expression.OpAny}
+			continue
+		}
+		expr, err := expression.FromInterface(raw)
+		if err != nil {
+			logger.Debug("parse expression %s fail: %s", pk, err.Error())
+			continue
+		}
+		expressions[pk] = expr
+	}
+
+	viaSet := map[string]struct{}{}
+	for _, p := range findDebugList(debug, "policies") {
+		expr, ok := expressions[toText(lookupField(p, "expression_pk"))]
+		if !ok {
+			continue
+		}
+		if matched, _ := expression.Eval(expr, resource); !matched {
+			continue
+		}
+
+		subjectPK := toText(lookupField(p, "subject_pk"))
+		path, ok := paths[subjectPK]
+		if !ok {
+			path = fmt.Sprintf("subject(pk=%s)", subjectPK)
+		}
+		viaSet[path] = struct{}{}
+	}
+
+	via := make([]string, 0, len(viaSet))
+	for path := range viaSet {
+		via = append(via, path)
+	}
+	sort.Strings(via)
+	return via
+}
+
+// subjectPaths returns the readable path of the subject self/groups/department-groups, key is the subject pk
+func subjectPaths(subject *model.SubjectDetail) map[string]string {
+	paths := map[string]string{}
+
+	self := subject.Subject
+	paths[strconv.FormatInt(self.PK, 10)] = fmt.Sprintf("%s %s(pk=%d), the policy of itself", self.Type, self.ID,
+		self.PK)
+
+	// NOTE: the group may be joined directly and via department, keep the direct one
+	for _, g := range subject.AllGroups() {
+		pk := strconv.FormatInt(g.PK, 10)
+		if _, ok := paths[pk]; ok {
+			continue
+		}
+
+		path := fmt.Sprintf("group(pk=%s), expired at %s", pk, printer.FormatTimestamp(g.PolicyExpiredAt))
+		if g.Department != nil {
+			path = fmt.Sprintf("department %s(id=%s) -> %s", g.Department.Name, g.Department.ID, path)
+		}
+		paths[pk] = path
+	}
+	return paths
+}
+
+// findDebugList finds the list of objects by the key in the debug info recursively
+func findDebugList(data interface{}, key string) []map[string]interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		if list := toMapList(lookupField(v, key)); len(list) > 0 {
+			return list
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if list := findDebugList(v[k], key); len(list) > 0 {
+				return list
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if list := findDebugList(item, key); len(list) > 0 {
+				return list
+			}
+		}
+	}
+	return nil
+}
+
+// lookupField returns the value of the key, case and underscore insensitive, e.g. subject_pk matches SubjectPK
+func lookupField(m map[string]interface{}, key string) interface{} {
+	if v, ok := m[key]; ok {
+		return v
+	}
+
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "_", ""))
+	}
+	key = normalize(key)
+	for k, v := range m {
+		if normalize(k) == key {
+			return v
+		}
+	}
+	return nil
+}
+
+func toMapList(v interface{}) []map[string]interface{} {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	result := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(map[string]interface{}); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func toText(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func init() {
+	checkCmd.Flags().StringArray("resource", []string{},
+		"the resource, {type}:{id}[,{attr}={value}], can be set multiple times")
+
+	addForceFlag(checkCmd)
+
+	rootCmd.AddCommand(checkCmd)
+}
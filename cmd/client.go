@@ -0,0 +1,313 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"bk-iam-cli/pkg/client"
+	"bk-iam-cli/pkg/client/transport"
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/storage"
+	"bk-iam-cli/pkg/util"
+)
+
+type cachedBackendClient struct {
+	client client.IAMBackendClient
+	host   string
+}
+
+type cachedSaaSClient struct {
+	client client.IAMSaaSClient
+	host   string
+}
+
+// sessionClients caches the clients between the commands in the shell session, nil means not in shell;
+// the key is {context}/{system}
+var (
+	sessionBackendClients map[string]cachedBackendClient
+	sessionSaaSClients    map[string]cachedSaaSClient
+)
+
+// enableSessionClientCache enables the client cache, should only be called by the shell
+func enableSessionClientCache() {
+	sessionBackendClients = map[string]cachedBackendClient{}
+	sessionSaaSClients = map[string]cachedSaaSClient{}
+}
+
+// resetSessionClientCache clears the cached clients, e.g. after login/use/context switched
+func resetSessionClientCache() {
+	if sessionBackendClients != nil {
+		enableSessionClientCache()
+	}
+}
+
+func sessionCacheKey(system string, stored storage.TLSConfig) (string, error) {
+	c, err := activeContext()
+	if err != nil {
+		return "", err
+	}
+	// the transport flags may be different between the commands
+	cfg, err := transportConfig(stored)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s/%+v", c.Name, system, cfg), nil
+}
+
+// storedTLS returns the tls settings stored with the login, empty if the host is set by flags/env/config file,
+// the settings of the stored host should not be applied to another one
+func storedTLS(hostKey string, credential func() (*storage.Credential, error)) (storage.TLSConfig, error) {
+	if viper.GetString(hostKey) != "" {
+		return storage.TLSConfig{}, nil
+	}
+	c, err := credential()
+	if err != nil {
+		return storage.TLSConfig{}, err
+	}
+	return c.ReadTLS()
+}
+
+// dryRunExitNotSent is the exit code of --dry-run if any request printed instead of sent
+const dryRunExitNotSent = 3
+
+// dryRunOutput prints the curl commands of --dry-run into stdout, and mutes the logs after the first one,
+// the command fails without the response then, the error is expected
+type dryRunOutput struct {
+	mu      sync.Mutex
+	printed bool
+}
+
+var dryRunOut = &dryRunOutput{}
+
+func (o *dryRunOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.printed = true
+	logger.SetMuted(true)
+	return os.Stdout.Write(p)
+}
+
+// finish unmutes the logs and reports the dry run, should be called after the command
+func (o *dryRunOutput) finish() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	logger.SetMuted(false)
+	if o.printed {
+		fmt.Fprintln(os.Stderr, "DRY RUN: the request is printed instead of sent, "+
+			"the command stops at the first request as no response")
+		setExitCode(dryRunExitNotSent)
+	}
+	o.printed = false
+}
+
+// transportConfig returns the config of the transport resolved from flags > env > the config file,
+// the tls settings not set fall back to the stored ones, e.g. --insecure-skip-verify=false overrides the stored true
+func transportConfig(stored storage.TLSConfig) (transport.Config, error) {
+	cfg := transport.Config{
+		Proxy:              viper.GetString(configKeyProxy),
+		CAFile:             stored.CAFile,
+		InsecureSkipVerify: stored.InsecureSkipVerify,
+		CertFile:           stored.CertFile,
+		KeyFile:            stored.KeyFile,
+		Retries:            transport.DefaultRetries,
+		RetryMaxWait:       transport.DefaultRetryMaxWait,
+		Record:             recordDir,
+		Replay:             replayDir,
+		Curl:               curlEnabled,
+		DryRun:             dryRun,
+		DryRunOutput:       dryRunOut,
+		ShowSecret:         showSecret,
+	}
+	if recordDir != "" && replayDir != "" {
+		return cfg, errors.New("--record and --replay can not be used together")
+	}
+	if caFile := viper.GetString(configKeyCAFile); caFile != "" {
+		cfg.CAFile = caFile
+	}
+	if insecure := viper.GetString(configKeyInsecureSkipVerify); insecure != "" {
+		cfg.InsecureSkipVerify = viper.GetBool(configKeyInsecureSkipVerify)
+	}
+	// the certificate and key are a pair
+	certFile, keyFile := viper.GetString(configKeyClientCert), viper.GetString(configKeyClientKey)
+	if certFile != "" || keyFile != "" {
+		cfg.CertFile = certFile
+		cfg.KeyFile = keyFile
+	}
+	if timeout := viper.GetString(configKeyTimeout); timeout != "" {
+		d, err := util.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid timeout `%s`, should be like 30s or 2m", timeout)
+		}
+		cfg.Timeout = d
+	}
+	if retries := viper.GetString(configKeyRetries); retries != "" {
+		n, err := strconv.Atoi(retries)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid retries `%s`, should be a number >= 0", retries)
+		}
+		cfg.Retries = n
+	}
+	if maxWait := viper.GetString(configKeyRetryMaxWait); maxWait != "" {
+		d, err := util.ParseDuration(maxWait)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid retry max wait `%s`, should be like 5s", maxWait)
+		}
+		cfg.RetryMaxWait = d
+	}
+	return cfg, nil
+}
+
+// newTransport returns the transport shared by the clients, with the proxy/ca file/timeout set by flags,
+// and the tls settings stored with the login
+func newTransport(stored storage.TLSConfig) (*transport.Client, error) {
+	cfg, err := transportConfig(stored)
+	if err != nil {
+		return nil, err
+	}
+	return transport.New(cfg)
+}
+
+// newBackendClient returns the backend client of the active context, and the host
+func newBackendClient() (client.IAMBackendClient, string, error) {
+	return newBackendClientWithSystem("")
+}
+
+// newSystemBackendClient returns the backend client with the system selected by `use`, and the system
+func newSystemBackendClient() (client.IAMBackendClient, string, error) {
+	system, err := readUseSystem()
+	if err != nil {
+		return nil, "", err
+	}
+
+	c, _, err := newBackendClientWithSystem(system)
+	return c, system, err
+}
+
+func newBackendClientWithSystem(system string) (client.IAMBackendClient, string, error) {
+	tls, err := storedTLS(configKeyHost, backendCredential)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := sessionCacheKey(system, tls)
+	if err != nil {
+		return nil, "", err
+	}
+	// the options may be different between the commands
+	key = fmt.Sprintf("%s/debug=%t/force=%t", key, apiDebugEnabled(), apiForceEnabled())
+	// NOTE: not cached if any of the auth info set by flags/env/config file
+	cacheable := sessionBackendClients != nil && configuredAuth(configKeyHost).empty()
+	if cached, ok := sessionBackendClients[key]; ok && cacheable {
+		return cached.client, cached.host, nil
+	}
+
+	auth, err := resolveAuth(configKeyHost, backendCredential)
+	if err != nil {
+		return nil, "", err
+	}
+	opts, err := backendClientOptions(tls)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c := client.NewIAMBackendClient(auth.host, system, auth.appCode, auth.appSecret, opts...)
+	if cacheable {
+		sessionBackendClients[key] = cachedBackendClient{client: c, host: auth.host}
+	}
+	return c, auth.host, nil
+}
+
+// the flags of the debug-capable commands, e.g. query policy
+var (
+	apiForce   bool
+	apiDebug   bool
+	apiNoDebug bool
+)
+
+func addForceFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&apiForce, "force", false,
+		"bypass the cache, all data from database (default from env IAM_API_FORCE=true)")
+}
+
+func addDebugFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&apiDebug, "debug", false,
+		"return the debug info and print the trace (default from env IAM_API_DEBUG=true)")
+	cmd.Flags().BoolVar(&apiNoDebug, "no-debug", false, "disable the debug even if env IAM_API_DEBUG=true")
+}
+
+// apiDebugEnabled returns true if --debug, or env IAM_API_DEBUG=true without --no-debug
+func apiDebugEnabled() bool {
+	if apiNoDebug {
+		return false
+	}
+	return apiDebug || envEnabled("IAM_API_DEBUG")
+}
+
+// apiForceEnabled returns true if --force or env IAM_API_FORCE=true
+func apiForceEnabled() bool {
+	return apiForce || envEnabled("IAM_API_FORCE")
+}
+
+func envEnabled(name string) bool {
+	return os.Getenv(name) == "true" || os.Getenv("BKAPP_"+name) == "true"
+}
+
+// backendClientOptions returns the options resolved from the flags and env, for all requests of the client
+func backendClientOptions(stored storage.TLSConfig) ([]client.Option, error) {
+	t, err := newTransport(stored)
+	if err != nil {
+		return nil, err
+	}
+	return []client.Option{
+		client.WithAPIDebug(apiDebugEnabled()),
+		client.WithAPIForce(apiForceEnabled()),
+		client.WithTransport(t),
+	}, nil
+}
+
+// newSaaSClient returns the SaaS client of the active context, and the host
+func newSaaSClient() (client.IAMSaaSClient, string, error) {
+	tls, err := storedTLS(configKeySaaSHost, saasCredential)
+	if err != nil {
+		return nil, "", err
+	}
+	key, err := sessionCacheKey("", tls)
+	if err != nil {
+		return nil, "", err
+	}
+	cacheable := sessionSaaSClients != nil && configuredAuth(configKeySaaSHost).empty()
+	if cached, ok := sessionSaaSClients[key]; ok && cacheable {
+		return cached.client, cached.host, nil
+	}
+
+	auth, err := resolveAuth(configKeySaaSHost, saasCredential)
+	if err != nil {
+		return nil, "", err
+	}
+	t, err := newTransport(tls)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c := client.NewIAMSaaSClient(auth.host, auth.appCode, auth.appSecret, client.WithSaaSTransport(t))
+	if cacheable {
+		sessionSaaSClients[key] = cachedSaaSClient{client: c, host: auth.host}
+	}
+	return c, auth.host, nil
+}
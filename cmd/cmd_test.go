@@ -0,0 +1,847 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+
+	"bk-iam-cli/pkg/batch"
+	"bk-iam-cli/pkg/mockserver"
+	"bk-iam-cli/pkg/model"
+	"bk-iam-cli/pkg/printer"
+	"bk-iam-cli/pkg/storage"
+)
+
+// setupMockServer starts a mock server with a temp home dir
+func setupMockServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	homedir.DisableCache = true
+	t.Setenv("HOME", t.TempDir())
+
+	handler, err := mockserver.New(mockserver.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// setupMockEnv starts a mock server, logins to it with a temp home dir, and uses the system bk_sops
+func setupMockEnv(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := setupMockServer(t)
+	runCommand(t, "login", server.URL, mockserver.DefaultAppCode, mockserver.DefaultAppSecret,
+		"--credential-backend", "file")
+	runCommand(t, "saas", "login", server.URL, mockserver.DefaultAppCode, mockserver.DefaultAppSecret,
+		"--credential-backend", "file")
+	runCommand(t, "use", "bk_sops")
+	return server
+}
+
+// runCommand runs the command like the shell does, returns the stdout
+func runCommand(t *testing.T, args ...string) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+
+	done := make(chan []byte)
+	go func() {
+		out, _ := ioutil.ReadAll(r)
+		done <- out
+	}()
+
+	resetFlags(rootCmd)
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+
+	w.Close()
+	os.Stdout = stdout
+	out := <-done
+
+	if err != nil {
+		t.Fatalf("run %v fail: %s", args, err)
+	}
+	return string(out)
+}
+
+func runJSONCommand(t *testing.T, v interface{}, args ...string) {
+	t.Helper()
+
+	out := runCommand(t, append(args, "-o", "json")...)
+	if err := json.Unmarshal([]byte(out), v); err != nil {
+		t.Fatalf("run %v, invalid json output: %s", args, out)
+	}
+}
+
+func TestLoginContext(t *testing.T) {
+	server := setupMockEnv(t)
+
+	c, err := activeContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, appCode, _, err := storage.NewCredential(c.Path(backendCredentialFile)).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != server.URL || appCode != mockserver.DefaultAppCode {
+		t.Errorf("got credential %s %s", host, appCode)
+	}
+
+	system, err := readUseSystem()
+	if err != nil || system != "bk_sops" {
+		t.Errorf("got system %s, %v", system, err)
+	}
+}
+
+func TestContextImport(t *testing.T) {
+	setupMockServer(t)
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, useSystemFile), []byte("bk_paas"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing imported until the command
+	if _, err := readUseSystem(); err == nil {
+		t.Fatal("the legacy file should not be used before import")
+	}
+	runCommand(t, "context", "import", dir)
+	if system, err := readUseSystem(); err != nil || system != "bk_paas" {
+		t.Errorf("got system %s, %v", system, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, useSystemFile)); err != nil {
+		t.Errorf("the legacy file should be kept, %v", err)
+	}
+}
+
+func TestLoginHosts(t *testing.T) {
+	for host, want := range map[string]string{
+		"iam.example.com":                  "https://iam.example.com,http://iam.example.com",
+		" 127.0.0.1:8080/prefix/ ":         "https://127.0.0.1:8080/prefix,http://127.0.0.1:8080/prefix",
+		"http://iam.example.com":           "http://iam.example.com",
+		"HTTPS://iam.example.com:443/iam/": "https://iam.example.com:443/iam",
+	} {
+		got, err := loginHosts(host)
+		if err != nil || strings.Join(got, ",") != want {
+			t.Errorf("loginHosts(%s) = %v %v, want %s", host, got, err, want)
+		}
+	}
+	for _, host := range []string{"", "ftp://iam.example.com", "http://", "iam.example.com?a=1"} {
+		if _, err := loginHosts(host); err == nil {
+			t.Errorf("loginHosts(%s) should fail", host)
+		}
+	}
+}
+
+func TestLoginHTTPS(t *testing.T) {
+	setupMockServer(t)
+	handler, err := mockserver.New(mockserver.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err = ioutil.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the bare host, https first
+	bare := strings.TrimPrefix(server.URL, "https://")
+	runCommand(t, "login", bare, mockserver.DefaultAppCode, mockserver.DefaultAppSecret,
+		"--credential-backend", "file")
+	c, err := activeContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential := storage.NewCredential(c.Path(backendCredentialFile))
+	if _, _, _, err = credential.Read(); err == nil {
+		t.Fatal("login should fail without the ca file")
+	}
+
+	runCommand(t, "login", bare, mockserver.DefaultAppCode, mockserver.DefaultAppSecret,
+		"--credential-backend", "file", "--ca-file", caFile)
+	host, _, _, err := credential.Read()
+	if err != nil || host != server.URL {
+		t.Fatalf("got host %s %v, want %s", host, err, server.URL)
+	}
+	if tls, _ := credential.ReadTLS(); tls.CAFile != caFile {
+		t.Errorf("the ca file should be stored, got %+v", tls)
+	}
+
+	// the later commands reuse the stored ca file
+	runCommand(t, "use", "bk_sops")
+	var policy map[string]interface{}
+	runJSONCommand(t, &policy, "query", "policy", "user", "tom", "project_view")
+	if policy["op"] != "OR" {
+		t.Errorf("unexpected policy %v", policy)
+	}
+}
+
+func TestQueryCommands(t *testing.T) {
+	setupMockEnv(t)
+
+	var subject map[string]interface{}
+	runJSONCommand(t, &subject, "query", "subject", "user", "tom")
+	if s, _ := subject["subject"].(map[string]interface{}); s["id"] != "tom" {
+		t.Errorf("unexpected subject %v", subject)
+	}
+
+	var policy map[string]interface{}
+	runJSONCommand(t, &policy, "query", "policy", "user", "tom", "project_view")
+	if policy["op"] != "OR" {
+		t.Errorf("unexpected policy %v", policy)
+	}
+
+	out := runCommand(t, "query", "action", "-o", "jsonpath={.pks.project_view}")
+	if strings.TrimSpace(out) != "2" {
+		t.Errorf("got pk %s, want 2", out)
+	}
+
+	var policies map[string]interface{}
+	runJSONCommand(t, &policies, "policy", "list", "--action", "project_view")
+	if results, _ := policies["results"].([]interface{}); len(results) != 2 {
+		t.Errorf("unexpected policies %v", policies)
+	}
+
+	var debugs []interface{}
+	runJSONCommand(t, &debugs, "saas", "debug", "list", "20220310")
+	if len(debugs) != 2 {
+		t.Errorf("unexpected debug list %v", debugs)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	server := setupMockEnv(t)
+	dir := t.TempDir()
+
+	var recorded map[string]interface{}
+	runJSONCommand(t, &recorded, "query", "policy", "user", "tom", "project_view", "--record", dir)
+	server.Close()
+
+	var replayed map[string]interface{}
+	runJSONCommand(t, &replayed, "query", "policy", "user", "tom", "project_view", "--replay", dir)
+	if replayed["op"] != "OR" || len(replayed) != len(recorded) {
+		t.Errorf("got %v, want %v", replayed, recorded)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	server := setupMockEnv(t)
+	server.Close()
+
+	out := runCommand(t, "query", "policy", "user", "tom", "project_view", "--dry-run")
+	if !strings.Contains(out, "curl -X 'GET'") || !strings.Contains(out, "/api/v1/debug/query/policy?") ||
+		strings.Contains(out, mockserver.DefaultAppSecret) {
+		t.Errorf("unexpected dry run output %s", out)
+	}
+	if exitCode != dryRunExitNotSent {
+		t.Errorf("exit code = %d, want %d", exitCode, dryRunExitNotSent)
+	}
+
+	// only the first page
+	out = runCommand(t, "policy", "list", "--action", "project_view", "--dry-run")
+	if strings.Count(out, "curl ") != 1 || !strings.Contains(out, "page=1") || exitCode != dryRunExitNotSent {
+		t.Errorf("unexpected dry run output %s, exit code %d", out, exitCode)
+	}
+
+	// nothing printed without request
+	runCommand(t, "config", "get", "system", "--dry-run")
+	if exitCode != 0 || dryRunOut.printed {
+		t.Errorf("exit code = %d, printed = %t", exitCode, dryRunOut.printed)
+	}
+
+	out = runCommand(t, "query", "policy", "user", "tom", "project_view", "--dry-run", "--show-secret")
+	if !strings.Contains(out, "X-Bk-App-Secret: "+mockserver.DefaultAppSecret) {
+		t.Errorf("the secret should be shown, got %s", out)
+	}
+}
+
+func TestPolicyListWithoutCount(t *testing.T) {
+	setupMockServer(t)
+	handler, err := mockserver.New(mockserver.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 5 policies in pages without count
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/systems/bk_sops/policies" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+		results := []map[string]interface{}{}
+		for id := (page-1)*pageSize + 1; id <= page*pageSize && id <= 5; id++ {
+			results = append(results, map[string]interface{}{"id": id})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{"metadata": map[string]interface{}{"timestamp": 1}, "results": results},
+		})
+	}))
+	defer server.Close()
+
+	t.Setenv("BK_IAM_HOST", server.URL)
+	t.Setenv("BK_IAM_APP_CODE", mockserver.DefaultAppCode)
+	t.Setenv("BK_IAM_APP_SECRET", mockserver.DefaultAppSecret)
+	t.Setenv("BK_IAM_SYSTEM", "bk_sops")
+
+	var policies struct {
+		Count   int64         `json:"count"`
+		Results []interface{} `json:"results"`
+	}
+	runJSONCommand(t, &policies, "policy", "list", "--action", "project_view", "--page-size", "2")
+	if policies.Count != 5 || len(policies.Results) != 5 {
+		t.Errorf("all the pages should be fetched, got %+v", policies)
+	}
+}
+
+func TestQueryPolicyDebug(t *testing.T) {
+	setupMockEnv(t)
+
+	out := runCommand(t, "query", "policy", "user", "tom", "project_view", "--debug", "--force")
+	for _, want := range []string{"[1] Fill Action Detail", "[2] Query Policies", "expression=project.id in [8, 14, 42]"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("debug trace should contain %q, got:\n%s", want, out)
+		}
+	}
+
+	var result map[string]interface{}
+	runJSONCommand(t, &result, "query", "policy", "user", "tom", "project_view", "--debug")
+	if _, ok := result["debug"].(map[string]interface{}); !ok {
+		t.Errorf("unexpected result %v", result)
+	}
+
+	// --no-debug wins over the env
+	t.Setenv("IAM_API_DEBUG", "true")
+	result = map[string]interface{}{}
+	runJSONCommand(t, &result, "query", "policy", "user", "tom", "project_view", "--no-debug")
+	if _, ok := result["debug"]; ok || result["op"] != "OR" {
+		t.Errorf("unexpected result %v", result)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	setupMockEnv(t)
+
+	var result checkResult
+	runJSONCommand(t, &result, "check", "user", "tom", "project_view", "--resource", "project:42")
+	if !result.Allowed {
+		t.Fatalf("tom should be allowed, got %+v", result)
+	}
+	if len(result.GrantedVia) != 1 || !strings.HasPrefix(result.GrantedVia[0], "group(pk=168966)") {
+		t.Errorf("unexpected granted via %v", result.GrantedVia)
+	}
+
+	result = checkResult{}
+	runJSONCommand(t, &result, "check", "user", "jerry", "project_view", "--resource", "project:42")
+	if result.Allowed {
+		t.Errorf("jerry should be denied, got %+v", result)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	setupMockEnv(t)
+
+	file := filepath.Join(t.TempDir(), "rows.csv")
+	rows := "command,subject_type,subject_id,action,resource\n" +
+		"check,user,tom,project_view,project:42\n" +
+		"check,user,jerry,project_view,project:42\n" +
+		"unknown,user,tom,project_view\n"
+	if err := ioutil.WriteFile(file, []byte(rows), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out := runCommand(t, "batch", "--file", file, "--concurrency", "2")
+	var records []batch.Record
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		record := batch.Record{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid jsonl output: %s", out)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+
+	allowed := func(r batch.Record) interface{} {
+		m, _ := r.Result.(map[string]interface{})
+		return m["allowed"]
+	}
+	if !records[0].OK || allowed(records[0]) != true {
+		t.Errorf("tom should be allowed, got %+v", records[0])
+	}
+	if !records[1].OK || allowed(records[1]) != false {
+		t.Errorf("jerry should be denied, got %+v", records[1])
+	}
+	if records[2].OK || !strings.Contains(records[2].Error, "unsupported command") {
+		t.Errorf("unexpected record %+v", records[2])
+	}
+}
+
+func TestTreeSubject(t *testing.T) {
+	setupMockEnv(t)
+
+	out := runCommand(t, "tree", "subject", "user", "tom")
+	want := `user:tom (pk=93162)
+├── departments (1)
+│   └── department:2871 部门1 (pk=121346)
+│       └── group pk=159041, expired at ` + printer.FormatTimestamp(int64(1649591084)) + ` [EXPIRED]
+└── groups (1)
+    └── group pk=168966, permanent
+`
+	if out != want {
+		t.Errorf("got tree:\n%s\nwant:\n%s", out, want)
+	}
+
+	out = runCommand(t, "tree", "subject", "user", "tom", "--format", "mermaid")
+	if !strings.HasPrefix(out, "graph LR\n") || !strings.Contains(out, "class n3 expired") {
+		t.Errorf("unexpected mermaid:\n%s", out)
+	}
+
+	// the soon-to-expire membership and the errors on the branch
+	now := time.Unix(1649591084, 0).Add(-48 * time.Hour)
+	root := buildSubjectTree(&model.SubjectDetail{
+		Subject:     model.Subject{PK: 1, Type: "user", ID: "tom"},
+		Departments: []model.SubjectDepartment{{Subject: model.Subject{PK: 2, Type: "department", ID: "1"}}},
+		Groups:      []model.SubjectGroup{{PK: 3, PolicyExpiredAt: 1649591084}},
+		Errs:        map[string]interface{}{"department_groups": "timeout", "subject": "not found"},
+	}, now, 7*24*time.Hour)
+
+	dot := &strings.Builder{}
+	if err := printer.PrintTree(dot, root, printer.TreeFormatDot, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`n2 [label="error(department_groups): timeout", color=red, fontcolor=red];`,
+		`n1 -> n2;`,
+		`[EXPIRING 2d0h]", style=filled, fillcolor="#fff3cd"];`,
+		`[label="error(subject): not found", color=red, fontcolor=red];`,
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("dot should contain %s, got:\n%s", want, dot)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	setupMockEnv(t)
+
+	file := filepath.Join(t.TempDir(), "users.txt")
+	if err := ioutil.WriteFile(file, []byte("# users\ntom\n\ntom\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var result expiryResult
+	runJSONCommand(t, &result, "expiry", "user", "--file", file)
+	if len(result.Memberships) != 2 || exitCode != 0 {
+		t.Fatalf("unexpected result %+v, exit code %d", result, exitCode)
+	}
+	if m := result.Memberships[0]; m.GroupPK != 159041 || m.Status != expiryStatusExpired ||
+		!strings.HasPrefix(m.Remaining, "-") {
+		t.Errorf("unexpected membership %+v", m)
+	}
+	if m := result.Memberships[1]; m.GroupPK != 168966 || m.Status != expiryStatusPermanent || m.Remaining != "" {
+		t.Errorf("unexpected membership %+v", m)
+	}
+
+	// expires within the window
+	detail := &model.SubjectDetail{
+		Subject: model.Subject{Type: "user", ID: "tom"},
+		Groups:  []model.SubjectGroup{{PK: 1, PolicyExpiredAt: 1649591084}, {PK: 2, PolicyExpiredAt: 1659591084}},
+	}
+	now := time.Unix(1649591084, 0).Add(-72 * time.Hour)
+	expiring := expiryResult{Memberships: membershipExpiries(detail, now, 7*24*time.Hour)}
+	if s := expiring.Memberships; s[0].Status != expiryStatusExpiring || s[0].Remaining != "3d0h" ||
+		s[1].Status != expiryStatusOK {
+		t.Errorf("unexpected memberships %+v", s)
+	}
+	if code := expiring.exitCode(); code != expiryExitExpiring {
+		t.Errorf("got exit code %d, want %d", code, expiryExitExpiring)
+	}
+	expiring.Errors = []expiryError{{Subject: "user:jerry", Error: "not found"}}
+	if code := expiring.exitCode(); code != expiryExitError {
+		t.Errorf("got exit code %d, want %d", code, expiryExitError)
+	}
+
+	// the command fails before any query
+	for _, args := range [][]string{
+		{"expiry", "user", "tom", "--within", "soon"},
+		{"expiry", "user", "--file", filepath.Join(t.TempDir(), "missing.txt")},
+		{"expiry", "user", "tom", "--context", "missing"},
+	} {
+		runCommand(t, args...)
+		if exitCode != expiryExitError {
+			t.Errorf("%v: got exit code %d, want %d", args, exitCode, expiryExitError)
+		}
+	}
+}
+
+func TestModelExplorer(t *testing.T) {
+	setupMockEnv(t)
+
+	var actions []modelActionRow
+	runJSONCommand(t, &actions, "model", "actions")
+	if len(actions) != 2 || actions[1].ID != "flow_view" || actions[1].PK != 3 ||
+		actions[1].RelatedResourceTypes[0] != "flow" || actions[1].RelatedActions[0] != "project_view" {
+		t.Errorf("unexpected actions %+v", actions)
+	}
+
+	out := runCommand(t, "model", "instance-selections", "-o", "table")
+	if !strings.Contains(out, "project -> flow") {
+		t.Errorf("unexpected instance selections:\n%s", out)
+	}
+
+	var detail modelActionDetail
+	runJSONCommand(t, &detail, "model", "action", "project_view")
+	if detail.PK != 2 || len(detail.InstanceSelections) != 1 || len(detail.DependedBy) != 1 ||
+		detail.DependedBy[0] != "flow_view" {
+		t.Errorf("unexpected action detail %+v", detail)
+	}
+
+	out = runCommand(t, "model", "graph")
+	if !strings.Contains(out, `"flow_view" -> "project_view";`) {
+		t.Errorf("unexpected graph:\n%s", out)
+	}
+
+	// the related action not in the model
+	g := actionGraph(&model.SystemModel{
+		System:  model.System{ID: "bk_sops"},
+		Actions: []model.Action{{ID: "flow_edit", Name: "edit", RelatedActions: []string{"flow_view"}}},
+	})
+	mermaid := &strings.Builder{}
+	if err := printer.PrintGraph(mermaid, g, printer.TreeFormatMermaid); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`n0["flow_edit<br/>edit"]`, `n1["flow_view"]`, "class n1 missing", "n0 --> n1"} {
+		if !strings.Contains(mermaid.String(), want) {
+			t.Errorf("mermaid should contain %s, got:\n%s", want, mermaid)
+		}
+	}
+}
+
+func TestModelLint(t *testing.T) {
+	setupMockEnv(t)
+
+	var result modelLintResult
+	runJSONCommand(t, &result, "model", "lint")
+	if result.System != "bk_sops" || len(result.Findings) != 0 || exitCode != 0 {
+		t.Errorf("unexpected result %+v, exit code %d", result, exitCode)
+	}
+
+	file := filepath.Join(t.TempDir(), "model.json")
+	broken := `{"data": {"system": {"id": "demo"}, "actions": [{"id": "a", "name": "a", "name_en": "a",
+		"related_actions": ["b"]}]}}`
+	if err := ioutil.WriteFile(file, []byte(broken), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	result = modelLintResult{}
+	runJSONCommand(t, &result, "model", "lint", "--file", file, "--disable", "required-fields")
+	if result.Errors != 1 || result.Findings[0].Rule != "related-action-exists" || exitCode != lintExitError {
+		t.Errorf("unexpected result %+v, exit code %d", result, exitCode)
+	}
+
+	// can not lint at all
+	for _, args := range [][]string{
+		{"model", "lint", "--file", filepath.Join(t.TempDir(), "missing.json")},
+		{"model", "lint", "--rules", "unknown-rule"},
+	} {
+		runCommand(t, args...)
+		if exitCode != lintExitFail {
+			t.Errorf("%v: got exit code %d, want %d", args, exitCode, lintExitFail)
+		}
+	}
+}
+
+func TestModelExportAndDiff(t *testing.T) {
+	setupMockEnv(t)
+
+	dir := t.TempDir()
+	exported := runCommand(t, "model", "export", "-o", "json")
+	m := &model.SystemModel{}
+	if err := json.Unmarshal([]byte(exported), m); err != nil || len(m.Actions) == 0 {
+		t.Fatalf("invalid export %s", exported)
+	}
+	left := filepath.Join(dir, "model.json")
+	if err := ioutil.WriteFile(left, []byte(exported), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var result modelDiffResult
+	runJSONCommand(t, &result, "model", "diff", "--file", left)
+	if !result.Equal || exitCode != 0 {
+		t.Errorf("the snapshot should equal to the system, got %+v, exit code %d", result, exitCode)
+	}
+
+	removed := m.Actions[0].ID
+	m.Actions = m.Actions[1:]
+	m.ResourceTypes[0].Name = "changed"
+	b, _ := json.Marshal(m)
+	right := filepath.Join(dir, "new.json")
+	if err := ioutil.WriteFile(right, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result = modelDiffResult{}
+	runJSONCommand(t, &result, "model", "diff", "--file", left, "--file", right)
+	d := result.Diff
+	if result.Equal || exitCode != modelDiffExitChanged {
+		t.Fatalf("the models should be different, got %+v, exit code %d", result, exitCode)
+	}
+	if len(d.Actions.Removed) != 1 || d.Actions.Removed[0] != removed || len(d.Actions.Added) != 0 {
+		t.Errorf("unexpected actions diff %+v", d.Actions)
+	}
+	if len(d.ResourceTypes.Changed) != 1 || d.ResourceTypes.Changed[0].Fields[0].Field != "name" {
+		t.Errorf("unexpected resource types diff %+v", d.ResourceTypes)
+	}
+
+	// the error is not reported as changed
+	for _, args := range [][]string{
+		{"model", "diff", "--file", left, "--file", filepath.Join(dir, "missing.json")},
+		{"model", "diff", "--context", "default", "--context", "missing"},
+		{"model", "export", "--context", "missing"},
+	} {
+		runCommand(t, args...)
+		if exitCode != modelExitError {
+			t.Errorf("%v: got exit code %d, want %d", args, exitCode, modelExitError)
+		}
+	}
+}
+
+func TestWhoamiAndLogout(t *testing.T) {
+	server := setupMockEnv(t)
+	runCommand(t, "login", server.URL, mockserver.DefaultAppCode, mockserver.DefaultAppSecret,
+		"--credential-backend", "file", "--ttl", "1d")
+
+	var result whoamiResult
+	runJSONCommand(t, &result, "whoami")
+	if len(result.Credentials) != 2 {
+		t.Fatalf("unexpected whoami %+v", result)
+	}
+	backend, saas := result.Credentials[0], result.Credentials[1]
+	if backend.Status != loginStatusOK || backend.Host != server.URL || backend.System != "bk_sops" {
+		t.Errorf("unexpected backend status %+v", backend)
+	}
+	if remaining := time.Until(time.Unix(backend.ExpiredAt, 0)); remaining < 23*time.Hour {
+		t.Errorf("ttl not applied, remaining %s", remaining)
+	}
+	if saas.Status != loginStatusOK || saas.Host != server.URL {
+		t.Errorf("unexpected saas status %+v", saas)
+	}
+
+	runCommand(t, "logout", "--saas")
+	result = whoamiResult{}
+	runJSONCommand(t, &result, "status")
+	if result.Credentials[0].Status != loginStatusOK || result.Credentials[1].Status != loginStatusNotLogin {
+		t.Errorf("only saas should logout, got %+v", result)
+	}
+
+	runCommand(t, "logout")
+	result = whoamiResult{}
+	runJSONCommand(t, &result, "status")
+	if result.Credentials[0].Status != loginStatusNotLogin || result.Credentials[0].System != "" {
+		t.Errorf("backend should logout, got %+v", result)
+	}
+}
+
+func TestEnvAuth(t *testing.T) {
+	server := setupMockServer(t)
+	t.Setenv("BK_IAM_HOST", server.URL)
+	t.Setenv("BK_IAM_APP_CODE", mockserver.DefaultAppCode)
+	t.Setenv("BK_IAM_APP_SECRET", mockserver.DefaultAppSecret)
+	t.Setenv("BK_IAM_SYSTEM", "bk_sops")
+
+	// no login at all
+	var policy map[string]interface{}
+	runJSONCommand(t, &policy, "query", "policy", "user", "tom", "project_view")
+	if policy["op"] != "OR" {
+		t.Errorf("unexpected policy %v", policy)
+	}
+
+	// flags override env
+	out := runCommand(t, "config", "get", "system", "--system", "bk_cmdb")
+	if strings.TrimSpace(out) != "bk_cmdb" {
+		t.Errorf("got system %s, want bk_cmdb", out)
+	}
+
+	var result whoamiResult
+	runJSONCommand(t, &result, "whoami")
+	if backend := result.Credentials[0]; backend.Status != loginStatusOK || backend.Source != credentialSourceConfig {
+		t.Errorf("unexpected backend status %+v", backend)
+	}
+
+	// the secret is masked unless --show-secret
+	if out = runCommand(t, "config", "get", "app_secret"); strings.TrimSpace(out) != maskedSecret {
+		t.Errorf("app_secret should be masked, got %s", out)
+	}
+	out = runCommand(t, "config", "get", "app_secret", "--show-secret")
+	if strings.TrimSpace(out) != mockserver.DefaultAppSecret {
+		t.Errorf("got app_secret %s with --show-secret", out)
+	}
+}
+
+func TestHostOverride(t *testing.T) {
+	server := setupMockEnv(t)
+
+	// the stored app_code/app_secret are not sent to the other host
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("the request should not be sent, got %s %s", r.Method, r.URL)
+	}))
+	defer other.Close()
+
+	if out := runCommand(t, "query", "policy", "user", "tom", "project_view", "--host", other.URL); out != "" {
+		t.Errorf("should fail if only the host is overridden, got %s", out)
+	}
+	// the flags are kept after the command
+	if _, err := resolveAuth(configKeyHost, backendCredential); err == nil ||
+		!strings.Contains(err.Error(), "the host is overridden") {
+		t.Errorf("resolveAuth() should fail if only the host is overridden, got %v", err)
+	}
+	// whoami shows the same as the auth used
+	var result whoamiResult
+	runJSONCommand(t, &result, "whoami", "--host", other.URL)
+	if backend := result.Credentials[0]; backend.Status != loginStatusNotLogin || backend.Host != other.URL ||
+		backend.AppCode != "" || !strings.Contains(backend.Error, "the host is overridden") {
+		t.Errorf("unexpected backend status %+v", backend)
+	}
+
+	// ok if all set
+	var policy map[string]interface{}
+	runJSONCommand(t, &policy, "query", "policy", "user", "tom", "project_view", "--host", server.URL,
+		"--app-code", mockserver.DefaultAppCode, "--app-secret", mockserver.DefaultAppSecret)
+	if policy["op"] != "OR" {
+		t.Errorf("unexpected policy %v", policy)
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	server := setupMockServer(t)
+
+	runCommand(t, "config", "set", "host", server.URL)
+	runCommand(t, "config", "set", "app_code", mockserver.DefaultAppCode)
+	runCommand(t, "config", "set", "app_secret", mockserver.DefaultAppSecret)
+	runCommand(t, "config", "set", "system", "bk_sops")
+
+	home, _ := homedir.Dir()
+	info, err := os.Stat(filepath.Join(home, ".bk-iam-cli.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("config file perm = %o, want 600", info.Mode().Perm())
+	}
+
+	out := runCommand(t, "config", "view")
+	if !strings.Contains(out, "app_secret: '******'") || strings.Contains(out, mockserver.DefaultAppSecret) {
+		t.Errorf("app_secret should be masked: %s", out)
+	}
+
+	// the config file is read by the next command
+	out = runCommand(t, "query", "action", "-o", "jsonpath={.pks.project_view}")
+	if strings.TrimSpace(out) != "2" {
+		t.Errorf("got pk %s, want 2", out)
+	}
+
+	runCommand(t, "config", "unset", "app_secret")
+	if out = runCommand(t, "config", "view"); strings.Contains(out, "app_secret") {
+		t.Errorf("app_secret should be removed: %s", out)
+	}
+
+	// the removed config file should not be kept
+	if err = os.Remove(filepath.Join(home, ".bk-iam-cli.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if out = runCommand(t, "config", "get", "host"); strings.TrimSpace(out) != "" {
+		t.Errorf("got host %s, want empty", out)
+	}
+}
+
+func TestDiffPolicy(t *testing.T) {
+	server := setupMockEnv(t)
+
+	var result diffPolicyResult
+	runJSONCommand(t, &result, "diff", "policy", "user", "tom", "user", "jerry", "project_view")
+	if result.Equal || len(result.OnlyLeft) != 2 || len(result.OnlyRight) != 0 {
+		t.Errorf("unexpected diff %+v", result)
+	}
+
+	// the same server in two contexts
+	runCommand(t, "context", "add", "prod", "--host", server.URL, "--app-code", mockserver.DefaultAppCode,
+		"--app-secret", mockserver.DefaultAppSecret, "--system", "bk_sops", "--credential-backend", "file")
+	result = diffPolicyResult{}
+	runJSONCommand(t, &result, "diff", "policy", "--context", "default", "--context", "prod",
+		"user", "tom", "project_view")
+	if !result.Equal || result.Left != "default user:tom" || len(result.Common) != 2 {
+		t.Errorf("unexpected diff %+v", result)
+	}
+}
+
+func TestCacheVerify(t *testing.T) {
+	setupMockEnv(t)
+
+	var result cacheVerifyResult
+	runJSONCommand(t, &result, "cache", "verify", "user", "tom", "project_view")
+	if result.Consistent || len(result.Actions) != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	kinds := map[string]int{}
+	for _, f := range result.Actions[0].Findings {
+		kinds[f.Kind]++
+	}
+	if kinds[findingMismatch] != 2 || kinds[findingExpired] != 1 {
+		t.Errorf("unexpected findings %+v", result.Actions[0].Findings)
+	}
+
+	// all the actions in cache
+	result = cacheVerifyResult{}
+	runJSONCommand(t, &result, "cache", "verify", "user", "tom")
+	if len(result.Actions) != 2 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	result = cacheVerifyResult{}
+	runJSONCommand(t, &result, "cache", "verify", "user", "jerry", "project_view")
+	if !result.Consistent || result.Actions[0].Findings[0].Kind != findingNotInCache {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// nothing recorded, all the actions fail
+	result = cacheVerifyResult{}
+	runJSONCommand(t, &result, "cache", "verify", "user", "tom", "project_view",
+		"--replay", t.TempDir())
+	if result.Consistent || result.Failed != 1 || result.Actions[0].Error == "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,330 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"bk-iam-cli/pkg/client/transport"
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/printer"
+	"bk-iam-cli/pkg/storage"
+)
+
+// the keys can be set by flags, env BK_IAM_{KEY} and the config file, in order
+const (
+	configKeyHost      = "host"
+	configKeySaaSHost  = "saas_host"
+	configKeyAppCode   = "app_code"
+	configKeyAppSecret = "app_secret"
+	configKeySystem    = "system"
+
+	// the keys of the transport, see transportConfig
+	configKeyProxy              = "proxy"
+	configKeyCAFile             = "ca_file"
+	configKeyInsecureSkipVerify = "insecure_skip_verify"
+	configKeyClientCert         = "client_cert"
+	configKeyClientKey          = "client_key"
+	configKeyTimeout            = "timeout"
+	configKeyRetries            = "retries"
+	configKeyRetryMaxWait       = "retry_max_wait"
+
+	configEnvPrefix = "BK_IAM"
+	defaultCfgFile  = ".bk-iam-cli.yaml"
+
+	maskedSecret = "******"
+)
+
+var configKeys = []string{
+	configKeyHost, configKeySaaSHost, configKeyAppCode, configKeyAppSecret, configKeySystem,
+	configKeyProxy, configKeyCAFile, configKeyInsecureSkipVerify, configKeyClientCert, configKeyClientKey,
+	configKeyTimeout,
+	configKeyRetries, configKeyRetryMaxWait,
+}
+
+// addConfigFlags adds the global flags of the config keys and binds them to viper,
+// the flag name is the key with `-`, e.g. --saas-host
+func addConfigFlags(cmd *cobra.Command) {
+	usages := map[string]string{
+		configKeyHost:      "the host of IAM backend, override the login credential",
+		configKeySaaSHost:  "the host of IAM SaaS, override the saas login credential",
+		configKeyAppCode:   "the app_code, override the login credential",
+		configKeyAppSecret: "the app_secret, override the login credential",
+		configKeySystem:    "the system to query, override the one set by 'use'",
+
+		configKeyProxy:              "the proxy of the requests, default from env HTTP_PROXY/HTTPS_PROXY",
+		configKeyCAFile:             "the ca bundle(pem) to verify the https IAM deployments",
+		configKeyInsecureSkipVerify: "skip the verification of the https server certificate, true or false",
+		configKeyClientCert:         "the client certificate(pem) for the https IAM deployments require mTLS",
+		configKeyClientKey:          "the key(pem) of the client certificate",
+		configKeyTimeout:            "the timeout of each request, e.g. 30s, override the default of each api",
+		configKeyRetries: fmt.Sprintf("the max retries of the failed queries, 0 to disable (default %d)",
+			transport.DefaultRetries),
+		configKeyRetryMaxWait: fmt.Sprintf("the max wait between the retries, with exponential backoff (default %s)",
+			transport.DefaultRetryMaxWait),
+	}
+
+	viper.SetEnvPrefix(configEnvPrefix)
+	for _, key := range configKeys {
+		name := strings.ReplaceAll(key, "_", "-")
+		cmd.PersistentFlags().String(name, "", usages[key]+fmt.Sprintf(" (env %s_%s)",
+			configEnvPrefix, strings.ToUpper(key)))
+		if key == configKeyInsecureSkipVerify {
+			// --insecure-skip-verify without value
+			cmd.PersistentFlags().Lookup(name).NoOptDefVal = "true"
+		}
+
+		_ = viper.BindPFlag(key, cmd.PersistentFlags().Lookup(name))
+		_ = viper.BindEnv(key)
+	}
+}
+
+// resolvedAuth is the auth info resolved from flags > env > config file > the stored credential
+type resolvedAuth struct {
+	host      string
+	appCode   string
+	appSecret string
+}
+
+// configuredAuth returns the auth info set by flags/env/config file, may be partial
+func configuredAuth(hostKey string) resolvedAuth {
+	return resolvedAuth{
+		host:      viper.GetString(hostKey),
+		appCode:   viper.GetString(configKeyAppCode),
+		appSecret: viper.GetString(configKeyAppSecret),
+	}
+}
+
+func (a resolvedAuth) empty() bool {
+	return a.host == "" && a.appCode == "" && a.appSecret == ""
+}
+
+func (a resolvedAuth) complete() bool {
+	return a.host != "" && a.appCode != "" && a.appSecret != ""
+}
+
+// resolveAuth resolves each field of the auth info in order, the credential is only read if some field missing,
+// so it can run without any on-disk state if all fields are set by flags/env/config file;
+// if the host is overridden, the credential is not used at all, the same as the stored tls settings
+func resolveAuth(hostKey string, credential func() (*storage.Credential, error)) (resolvedAuth, error) {
+	auth := configuredAuth(hostKey)
+	if auth.complete() {
+		return auth, nil
+	}
+	// NOTE: the stored app_code/app_secret are for the stored host, should not be sent to the other one
+	if auth.host != "" {
+		return auth, fmt.Errorf("the host is overridden by --%s/env/config file, "+
+			"the app_code and app_secret should be set by --app-code/--app-secret/env/config file too",
+			strings.ReplaceAll(hostKey, "_", "-"))
+	}
+
+	c, err := credential()
+	if err != nil {
+		return auth, err
+	}
+	host, appCode, appSecret, err := c.Read()
+	if err != nil {
+		return auth, err
+	}
+
+	if auth.host == "" {
+		auth.host = host
+	}
+	if auth.appCode == "" {
+		auth.appCode = appCode
+	}
+	if auth.appSecret == "" {
+		auth.appSecret = appSecret
+	}
+	return auth, nil
+}
+
+// configFile returns the config file used, default $HOME/.bk-iam-cli.yaml
+func configFile() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	if used := viper.ConfigFileUsed(); used != "" {
+		return used, nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, defaultCfgFile), nil
+}
+
+// readConfigFile returns the config file and its content, only yaml is supported
+func readConfigFile() (string, map[string]interface{}, error) {
+	file, err := configFile()
+	if err != nil {
+		return "", nil, err
+	}
+
+	ext := filepath.Ext(file)
+	if ext != ".yaml" && ext != ".yml" {
+		return "", nil, fmt.Errorf("only yaml config file supported, got %s", file)
+	}
+
+	config, err := storage.ReadConfig(file)
+	return file, config, err
+}
+
+func validConfigKey(key string) error {
+	for _, k := range configKeys {
+		if k == key {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported key `%s`, should be one of %s", key, strings.Join(configKeys, "|"))
+}
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the config file",
+	Long: fmt.Sprintf(`Manage the config file, default $HOME/%s, or the one specified by --config.
+
+The keys %s are resolved in order:
+flags(e.g. --saas-host) > env(e.g. BK_IAM_SAAS_HOST) > the config file > the login credential / 'use',
+so the commands can run without login, e.g. in CI:
+  BK_IAM_HOST=http://{iam_host} BK_IAM_APP_CODE=bk_iam BK_IAM_APP_SECRET={app_secret} BK_IAM_SYSTEM=bk_paas \
+  iam-cli query policy user tom project_view
+`, defaultCfgFile, strings.Join(configKeys, "/")),
+}
+
+var configViewCmd = &cobra.Command{
+	Use:   "view",
+	Short: "Show the content of the config file",
+	Long:  `Show the content of the config file, the app_secret is masked unless --show-secret`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, config, err := readConfigFile()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		if _, ok := config[configKeyAppSecret]; ok && !showSecret {
+			config[configKeyAppSecret] = maskedSecret
+		}
+
+		// yaml by default, the same as the file
+		format := output
+		if format == "" {
+			format = string(printer.FormatYAML)
+		}
+		p, err := printer.New(format)
+		if err == nil {
+			err = p.Print(os.Stdout, printer.KindUnknown, config)
+		}
+		if err != nil {
+			logger.Error("print fail! %s", err.Error())
+		}
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Get the value of the key",
+	Long: `Get the effective value of the key, resolved from flags > env > the config file,
+the app_secret is masked unless --show-secret`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := args[0]
+		if err := validConfigKey(key); err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		value := viper.GetString(key)
+		if key == configKeyAppSecret && value != "" && !showSecret {
+			value = maskedSecret
+		}
+		fmt.Println(value)
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set [key] [value]",
+	Short: "Set the value of the key in the config file",
+	Long:  `Set the value of the key in the config file, the file is created with 0600 permissions if not exists`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, value := args[0], args[1]
+		if err := validConfigKey(key); err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		file, config, err := readConfigFile()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		config[key] = value
+		err = storage.WriteConfig(file, config)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		logger.Info("success")
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset [key]",
+	Short: "Remove the key from the config file",
+	Long:  `Remove the key from the config file`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		key := args[0]
+		if err := validConfigKey(key); err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		file, config, err := readConfigFile()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		delete(config, key)
+		err = storage.WriteConfig(file, config)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		logger.Info("success")
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configViewCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+
+	rootCmd.AddCommand(configCmd)
+}
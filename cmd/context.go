@@ -0,0 +1,317 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/storage"
+)
+
+const useSystemFile = ".use"
+
+// activeContext returns the context specified by --context, or the current context
+func activeContext() (*storage.Context, error) {
+	return storage.ResolveContext(contextName)
+}
+
+// loginContext returns the context to login into, the context specified by --context will be created if not exists
+func loginContext() (*storage.Context, error) {
+	if contextName != "" {
+		return storage.NewContext(contextName)
+	}
+	return activeContext()
+}
+
+func backendCredential() (*storage.Credential, error) {
+	c, err := activeContext()
+	if err != nil {
+		return nil, err
+	}
+	warnLegacyCredential(c, backendCredentialFile)
+	return storage.NewCredential(c.Path(backendCredentialFile)), nil
+}
+
+func saasCredential() (*storage.Credential, error) {
+	c, err := activeContext()
+	if err != nil {
+		return nil, err
+	}
+	warnLegacyCredential(c, saasCredentialFile)
+	return storage.NewCredential(c.Path(saasCredentialFile)), nil
+}
+
+// readUseSystem returns the system set by --system/env/config file, or the one selected by `use`
+func readUseSystem() (string, error) {
+	if system := viper.GetString(configKeySystem); system != "" {
+		return system, nil
+	}
+
+	c, err := activeContext()
+	if err != nil {
+		return "", err
+	}
+	return storage.ReadUseSystem(c.Path(useSystemFile))
+}
+
+// legacyFiles are written into the working dir by the old versions(before the contexts)
+var legacyFiles = []string{backendCredentialFile, saasCredentialFile, useSystemFile}
+
+// warnLegacyCredential hints to import the credential of the old versions if the context has none
+func warnLegacyCredential(c *storage.Context, file string) {
+	if _, err := os.Stat(c.Path(file)); !os.IsNotExist(err) {
+		return
+	}
+	if _, err := os.Stat(file); err == nil {
+		logger.Warn("found ./%s of the old version, run `context import` in this dir to import it", file)
+	}
+}
+
+// contextCmd represents the context command
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Manage the contexts(IAM environments)",
+	Long: `Manage the contexts(IAM environments), e.g. dev/stage/prod.
+Each context holds the backend host, SaaS host, app_code/app_secret and the selected system,
+stored under $HOME/.bk-iam-cli/contexts/{name}.
+
+context add {name} [--host ...] [--app-code ...] [--app-secret ...] [--saas-host ...] [--system ...]
+context list
+context use {name}
+context delete {name}
+context rename {old_name} {new_name}
+context import [dir]
+
+All commands use the current context, or the one specified by --context.
+`,
+}
+
+var contextAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Add a context",
+	Long: `Add a context, the credentials can be set via flags(without validation) or via login later:
+context add stage --host http://{iam_host} --app-code {app_code} --app-secret {app_secret}
+login --context stage http://{iam_host} {app_code} {app_secret}
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		// NOTE: only the flags, the env/config file is not written into the context
+		host, _ := cmd.Flags().GetString("host")
+		saasHost, _ := cmd.Flags().GetString("saas-host")
+		appCode, _ := cmd.Flags().GetString("app-code")
+		appSecret, _ := cmd.Flags().GetString("app-secret")
+		system, _ := cmd.Flags().GetString("system")
+
+		if (host != "" || saasHost != "") && (appCode == "" || appSecret == "") {
+			logger.Error("--app-code and --app-secret are required while --host or --saas-host set")
+			return
+		}
+
+		c, err := storage.AddContext(name)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		if host != "" {
+			credential, err := newCredential(cmd, c.Path(backendCredentialFile))
+			if err == nil {
+				err = credential.Write(host, appCode, appSecret)
+			}
+			if err != nil {
+				logger.Error(err.Error())
+				return
+			}
+		}
+		if saasHost != "" {
+			credential, err := newCredential(cmd, c.Path(saasCredentialFile))
+			if err == nil {
+				err = credential.Write(saasHost, appCode, appSecret)
+			}
+			if err != nil {
+				logger.Error(err.Error())
+				return
+			}
+		}
+		if system != "" {
+			err = storage.WriteUseSystem(c.Path(useSystemFile), system)
+			if err != nil {
+				logger.Error(err.Error())
+				return
+			}
+		}
+
+		logger.Info("success")
+	},
+}
+
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all the contexts",
+	Long:  `List all the contexts, the current one is marked with *`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		names, err := storage.ListContexts()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		current, err := storage.ReadCurrentContext()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CURRENT\tNAME\tHOST\tSAAS HOST\tAPP CODE\tSYSTEM")
+		for _, name := range names {
+			c, err := storage.NewContext(name)
+			if err != nil {
+				continue
+			}
+
+			mark := ""
+			if name == current {
+				mark = "*"
+			}
+
+			// NOTE: the credential may be expired, still show the host and app_code
+			host, appCode, _, _ := storage.NewCredential(c.Path(backendCredentialFile)).ReadInfo()
+			saasHost, saasAppCode, _, _ := storage.NewCredential(c.Path(saasCredentialFile)).ReadInfo()
+			if appCode == "" {
+				appCode = saasAppCode
+			}
+			system, _ := storage.ReadUseSystem(c.Path(useSystemFile))
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				mark, name, orDash(host), orDash(saasHost), orDash(appCode), orDash(system))
+		}
+		w.Flush()
+	},
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use [name]",
+	Short: "Switch the current context",
+	Long:  `Switch the current context, all commands will use it if no --context specified`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := storage.WriteCurrentContext(args[0])
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		logger.Info("success")
+	},
+}
+
+var contextDeleteCmd = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "Delete a context",
+	Long:  `Delete a context, and all the credentials of it`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := storage.DeleteContext(args[0])
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		logger.Info("success")
+	},
+}
+
+var contextRenameCmd = &cobra.Command{
+	Use:   "rename [old_name] [new_name]",
+	Short: "Rename a context",
+	Long:  `Rename a context`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := storage.RenameContext(args[0], args[1])
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		logger.Info("success")
+	},
+}
+
+var contextImportCmd = &cobra.Command{
+	Use:   "import [dir]",
+	Short: "Import the credentials of the old versions into the context",
+	Long: `Import the credentials(.credential/.saas-credential) and the use system(.use)
+written into the working dir by the old versions, into the current context or the one specified by --context.
+The files are copied from the dir(default the working dir) and kept,
+the existing ones of the context are not overwritten.
+`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		c, err := loginContext()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		imported, skipped, err := c.ImportLegacyFiles(dir, legacyFiles...)
+		for _, name := range imported {
+			logger.Info("%s imported into the context `%s`", filepath.Join(dir, name), c.Name)
+		}
+		for _, name := range skipped {
+			logger.Warn("%s skipped, the context `%s` has one already", filepath.Join(dir, name), c.Name)
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+		if len(imported) == 0 && len(skipped) == 0 {
+			logger.Warn("no file of the old versions found in %s", dir)
+		}
+	},
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func init() {
+	// NOTE: --host/--saas-host/--app-code/--app-secret/--system are the global flags
+	addCredentialBackendFlag(contextAddCmd)
+
+	contextCmd.AddCommand(contextAddCmd)
+	contextCmd.AddCommand(contextListCmd)
+	contextCmd.AddCommand(contextUseCmd)
+	contextCmd.AddCommand(contextDeleteCmd)
+	contextCmd.AddCommand(contextRenameCmd)
+	contextCmd.AddCommand(contextImportCmd)
+
+	rootCmd.AddCommand(contextCmd)
+}
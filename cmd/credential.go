@@ -0,0 +1,80 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/printer"
+	"bk-iam-cli/pkg/storage"
+	"bk-iam-cli/pkg/util"
+)
+
+// addCredentialBackendFlag adds --credential-backend to the commands writing the credential,
+// the default value can be set by $BK_IAM_CLI_CREDENTIAL_BACKEND
+func addCredentialBackendFlag(cmd *cobra.Command) {
+	backend := os.Getenv("BK_IAM_CLI_CREDENTIAL_BACKEND")
+	if backend == "" {
+		backend = storage.CredentialBackendAuto
+	}
+
+	cmd.Flags().String("credential-backend", backend,
+		"how to protect the app_secret, one of "+storage.CredentialBackends+
+			", auto uses the OS keyring if available, otherwise the file encrypted by the per-user key")
+}
+
+// addCredentialTTLFlag adds --ttl to the login commands
+func addCredentialTTLFlag(cmd *cobra.Command) {
+	cmd.Flags().String("ttl", storage.DefaultCredentialTTL.String(), "the lifetime of the credential, e.g. 30m, 8h, 7d")
+}
+
+// newCredential returns the credential of the file,
+// which is written by the backend of --credential-backend, and expires after --ttl if the command has it
+func newCredential(cmd *cobra.Command, file string) (*storage.Credential, error) {
+	credential := storage.NewCredential(file)
+
+	backend, _ := cmd.Flags().GetString("credential-backend")
+	if err := credential.SetBackend(backend); err != nil {
+		return nil, err
+	}
+
+	if cmd.Flags().Lookup("ttl") != nil {
+		value, _ := cmd.Flags().GetString("ttl")
+		ttl, err := util.ParseDuration(value)
+		if err != nil {
+			return nil, err
+		}
+		if err = credential.SetTTL(ttl); err != nil {
+			return nil, err
+		}
+	}
+	return credential, nil
+}
+
+// promptPassphrase reads the passphrase of the passphrase backend from $BK_IAM_CLI_PASSPHRASE or the terminal
+func promptPassphrase() ([]byte, error) {
+	if p := os.Getenv("BK_IAM_CLI_PASSPHRASE"); p != "" {
+		return []byte(p), nil
+	}
+	if !printer.IsTerminal(os.Stdin) {
+		return nil, errors.New("passphrase required, please set the env BK_IAM_CLI_PASSPHRASE")
+	}
+	return readline.Password("passphrase: ")
+}
+
+func init() {
+	storage.PassphraseFunc = promptPassphrase
+}
@@ -0,0 +1,134 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"bk-iam-cli/pkg/expression"
+	"bk-iam-cli/pkg/model"
+	"bk-iam-cli/pkg/printer"
+)
+
+// formatDebugTrace renders the debug info as a readable step-by-step trace, e.g.
+//
+//	debug trace at 2022-03-10T08:12:41+08:00: action.id=project_view subject.id=tom subject.type=user system=bk_sops
+//	[1] Fill Action Detail
+//	    action_pk: 2
+//	[2] Query Policies
+//	    policies:
+//	      - pk=1001 subject_pk=168966 expression_pk=11332 expired_at=2100-01-01 08:00:00
+//	    expressions:
+//	      - pk=11332 expression=project.id in [8, 14, 42]
+func formatDebugTrace(d *model.PolicyDebug) string {
+	sb := &strings.Builder{}
+	writeDebugTrace(sb, d, "")
+	return sb.String()
+}
+
+func writeDebugTrace(sb *strings.Builder, d *model.PolicyDebug, indent string) {
+	fmt.Fprintf(sb, "%sdebug trace at %s: %s\n", indent, d.Time, flattenDebugMap(d.Context))
+
+	for _, step := range d.Steps {
+		fmt.Fprintf(sb, "%s[%d] %s\n", indent, step.Index, step.Name)
+		writeDebugData(sb, step.Data, indent+"    ")
+	}
+	if len(d.Evals) > 0 {
+		fmt.Fprintf(sb, "%sevals:\n", indent)
+		writeDebugData(sb, d.Evals, indent+"    ")
+	}
+	if d.Error != "" {
+		fmt.Fprintf(sb, "%serror: %s\n", indent, d.Error)
+	}
+
+	for i := range d.SubDebugs {
+		writeDebugTrace(sb, &d.SubDebugs[i], indent+"  ")
+	}
+}
+
+func writeDebugData(sb *strings.Builder, data map[string]interface{}, indent string) {
+	for _, key := range sortedKeys(data) {
+		switch v := data[key].(type) {
+		case map[string]interface{}:
+			fmt.Fprintf(sb, "%s%s:\n", indent, key)
+			writeDebugData(sb, v, indent+"  ")
+		case []interface{}:
+			if items := toMapList(v); len(items) > 0 && len(items) == len(v) {
+				fmt.Fprintf(sb, "%s%s:\n", indent, key)
+				for _, item := range items {
+					fmt.Fprintf(sb, "%s  - %s\n", indent, flattenDebugMap(item))
+				}
+				continue
+			}
+			fmt.Fprintf(sb, "%s%s: %s\n", indent, key, formatDebugValue(key, v))
+		default:
+			fmt.Fprintf(sb, "%s%s: %s\n", indent, key, formatDebugValue(key, v))
+		}
+	}
+}
+
+// flattenDebugMap returns the `k=v` of the map, the nested keys are joined by `.`
+func flattenDebugMap(m map[string]interface{}) string {
+	var parts []string
+	var flatten func(prefix string, m map[string]interface{})
+	flatten = func(prefix string, m map[string]interface{}) {
+		for _, key := range sortedKeys(m) {
+			if nested, ok := m[key].(map[string]interface{}); ok {
+				flatten(prefix+key+".", nested)
+				continue
+			}
+			parts = append(parts, fmt.Sprintf("%s%s=%s", prefix, key, formatDebugValue(key, m[key])))
+		}
+	}
+	flatten("", m)
+	return strings.Join(parts, " ")
+}
+
+// formatDebugValue formats the value readable: the timestamps as local time, the expressions as text
+func formatDebugValue(key string, v interface{}) string {
+	lower := strings.ToLower(key)
+	switch {
+	case lower == "expression":
+		if s, ok := v.(string); ok {
+			if s == "" {
+				return expression.OpAny
+			}
+			if e, err := expression.Parse([]byte(s)); err == nil {
+				return e.String()
+			}
+		}
+	case strings.HasSuffix(lower, "expired_at") || strings.HasSuffix(lower, "expiredat"):
+		if _, ok := v.(float64); ok {
+			return printer.FormatTimestamp(v)
+		}
+	}
+
+	switch x := v.(type) {
+	case []interface{}, map[string]interface{}:
+		b, _ := json.Marshal(x)
+		return string(b)
+	default:
+		return toText(x)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,176 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/expression"
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/printer"
+)
+
+type diffPolicyResult struct {
+	Left  string `json:"left"`
+	Right string `json:"right"`
+	Equal bool   `json:"equal"`
+	*expression.Diff
+}
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the data between subjects, contexts or files",
+	Long:  `Compare the data between subjects, contexts(IAM environments) or files`,
+}
+
+var diffPolicyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Compare the policies of an action between two subjects, contexts or files",
+	Long: `Compare the policies of an action between two subjects, contexts or files.
+The expressions are normalized(sort the AND/OR operands, dedupe the in values) before compared,
+and the grants only in one side are printed, e.g. "project.id in [1]" only granted to alice.
+
+diff policy user alice user bob project_view
+diff policy --context stage --context prod user tom project_view
+diff policy --file alice.json --file bob.json
+
+The file is the output of "query policy" or "cache expression", use "-" to read one from stdin.
+`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		files, _ := cmd.Flags().GetStringArray("file")
+		switch {
+		case len(files) > 0:
+			if len(files) != 2 || len(args) != 0 {
+				return errors.New("diff policy --file {file1} --file {file2}")
+			}
+		case len(contextNames) > 1:
+			if len(contextNames) != 2 || len(args) != 3 {
+				return errors.New("diff policy --context {context1} --context {context2} {subject_type} {subject_id} {action}")
+			}
+		case len(args) != 5:
+			return errors.New("diff policy {subject_type} {subject_id} {subject_type} {subject_id} {action}")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		files, _ := cmd.Flags().GetStringArray("file")
+
+		var (
+			left, right           *expression.Expression
+			leftLabel, rightLabel string
+			err                   error
+		)
+		switch {
+		case len(files) > 0:
+			leftLabel, rightLabel = files[0], files[1]
+			left, err = readExpression(files[0])
+			if err == nil {
+				right, err = readExpression(files[1])
+			}
+		case len(contextNames) > 1:
+			subjectType, subjectID, action := args[0], args[1], args[2]
+			contexts := contextNames
+
+			leftLabel = fmt.Sprintf("%s %s:%s", contexts[0], subjectType, subjectID)
+			rightLabel = fmt.Sprintf("%s %s:%s", contexts[1], subjectType, subjectID)
+			err = inContext(contexts[0], func() (err error) {
+				left, err = queryPolicyExpression(subjectType, subjectID, action)
+				return
+			})
+			if err == nil {
+				err = inContext(contexts[1], func() (err error) {
+					right, err = queryPolicyExpression(subjectType, subjectID, action)
+					return
+				})
+			}
+		default:
+			action := args[4]
+			leftLabel = fmt.Sprintf("%s:%s", args[0], args[1])
+			rightLabel = fmt.Sprintf("%s:%s", args[2], args[3])
+			left, err = queryPolicyExpression(args[0], args[1], action)
+			if err == nil {
+				right, err = queryPolicyExpression(args[2], args[3], action)
+			}
+		}
+		if err != nil {
+			logger.Error("get policy fail! %s", err.Error())
+			return
+		}
+
+		d := expression.DiffExpressions(left, right)
+		printDiffPolicyResult(diffPolicyResult{
+			Left:  leftLabel,
+			Right: rightLabel,
+			Equal: d.Equal(),
+			Diff:  d,
+		})
+	},
+}
+
+// inContext runs fn with the context as the active one, e.g. to query from two contexts in one command
+func inContext(name string, fn func() error) error {
+	current := contextName
+	contextName = name
+	defer func() { contextName = current }()
+
+	if _, err := activeContext(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+func queryPolicyExpression(subjectType, subjectID, action string) (*expression.Expression, error) {
+	client, system, err := newSystemBackendClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.GetPolicyExpression(system, subjectType, subjectID, action, apiForceEnabled())
+}
+
+func printDiffPolicyResult(result diffPolicyResult) {
+	if output != "" {
+		printResult(printer.KindUnknown, result)
+		return
+	}
+
+	fmt.Println(color.Red.Sprintf("--- %s", result.Left))
+	fmt.Println(color.Green.Sprintf("+++ %s", result.Right))
+	for _, e := range result.Common {
+		fmt.Printf("  %s\n", e)
+	}
+	for _, e := range result.OnlyLeft {
+		fmt.Println(color.Red.Sprintf("- %s", e))
+	}
+	for _, e := range result.OnlyRight {
+		fmt.Println(color.Green.Sprintf("+ %s", e))
+	}
+
+	if result.Equal {
+		logger.Info("the same permissions granted")
+		return
+	}
+	logger.Warn("%d grant(s) only for %s, %d grant(s) only for %s",
+		len(result.OnlyLeft), result.Left, len(result.OnlyRight), result.Right)
+}
+
+func init() {
+	diffPolicyCmd.Flags().StringArray("file", nil, "the saved policy json file, should be specified twice")
+	addForceFlag(diffPolicyCmd)
+
+	diffCmd.AddCommand(diffPolicyCmd)
+	rootCmd.AddCommand(diffCmd)
+}
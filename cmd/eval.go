@@ -0,0 +1,169 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/expression"
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/printer"
+)
+
+type evalResult struct {
+	Allowed    bool              `json:"allowed"`
+	Expression string            `json:"expression"`
+	Trace      *expression.Trace `json:"trace"`
+}
+
+// evalCmd represents the eval command
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Evaluate the policy expression against the resource locally",
+	Long: `Evaluate the policy expression against the resource locally(offline),
+and print the trace of the sub-expressions.
+The expression can be the output of "query policy" or "cache expression", use "-" to read from stdin.
+
+eval --expr policy.json --resource project.id=1
+eval --expr policy.json --resource host.id=1 --resource host._bk_iam_path_=/biz,1/ --resource host._bk_iam_path_=/biz,2/
+eval --expr policy.json --resource-file resource.json
+
+The resource file is a json object, e.g. {"project.id": "1", "host._bk_iam_path_": ["/biz,1/"]}
+or {"project": {"id": "1"}}
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		exprFile, _ := cmd.Flags().GetString("expr")
+		resourceAttrs, _ := cmd.Flags().GetStringArray("resource")
+		resourceFile, _ := cmd.Flags().GetString("resource-file")
+
+		if exprFile == "" {
+			logger.Error("eval --expr {file} required")
+			return
+		}
+
+		expr, err := readExpression(exprFile)
+		if err != nil {
+			logger.Error("read expression fail! %s", err.Error())
+			return
+		}
+
+		resource := expression.Resource{}
+		if resourceFile != "" {
+			resource, err = readResourceFile(resourceFile)
+			if err != nil {
+				logger.Error("read resource file fail! %s", err.Error())
+				return
+			}
+		}
+		for _, attr := range resourceAttrs {
+			err = setResourceAttr(resource, attr)
+			if err != nil {
+				logger.Error(err.Error())
+				return
+			}
+		}
+
+		allowed, trace := expression.Eval(expr, resource)
+		printEvalResult(evalResult{
+			Allowed:    allowed,
+			Expression: expr.String(),
+			Trace:      trace,
+		})
+	},
+}
+
+func printEvalResult(result evalResult) {
+	if output != "" {
+		printResult(printer.KindUnknown, result)
+		return
+	}
+
+	fmt.Print(result.Trace.String())
+	if result.Allowed {
+		logger.Info("allow")
+	} else {
+		logger.Warn("deny")
+	}
+}
+
+func readExpression(file string) (*expression.Expression, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return expression.Parse(data)
+}
+
+// setResourceAttr sets the attribute like `project.id=1`
+func setResourceAttr(resource expression.Resource, attr string) error {
+	parts := strings.SplitN(attr, "=", 2)
+	if len(parts) != 2 || !strings.Contains(parts[0], ".") {
+		return fmt.Errorf("invalid resource attribute `%s`, should be {resource_type}.{attribute}={value}", attr)
+	}
+	resource.Set(parts[0], parts[1])
+	return nil
+}
+
+func readResourceFile(file string) (expression.Resource, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+
+	resource := expression.Resource{}
+	flattenResource(resource, "", raw)
+	return resource, nil
+}
+
+// flattenResource flattens {"project": {"id": "1"}} into {"project.id": "1"}
+func flattenResource(resource expression.Resource, prefix string, raw map[string]interface{}) {
+	for key, value := range raw {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if m, ok := value.(map[string]interface{}); ok {
+			flattenResource(resource, key, m)
+			continue
+		}
+		resource[key] = value
+	}
+}
+
+func init() {
+	evalCmd.Flags().String("expr", "", "the file of the expression, `-` means stdin")
+	evalCmd.Flags().StringArray("resource", []string{},
+		"the attribute of the resource, {resource_type}.{attribute}={value}, can be set multiple times")
+	evalCmd.Flags().String("resource-file", "", "the json file of the resource attributes")
+
+	rootCmd.AddCommand(evalCmd)
+}
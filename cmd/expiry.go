@@ -0,0 +1,245 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/model"
+	"bk-iam-cli/pkg/printer"
+	"bk-iam-cli/pkg/util"
+)
+
+// the status of the group membership
+const (
+	expiryStatusExpired   = "EXPIRED"
+	expiryStatusExpiring  = "EXPIRING"
+	expiryStatusPermanent = "PERMANENT"
+	expiryStatusOK        = "OK"
+)
+
+// the exit codes of expiry, for the nightly check
+const (
+	// some memberships expire within the window
+	expiryExitExpiring = 1
+	// query the subject fail, or the command fail, e.g. not login
+	expiryExitError = 2
+)
+
+type membershipExpiry struct {
+	Subject   string `json:"subject"`
+	GroupPK   int64  `json:"group_pk"`
+	From      string `json:"from"`
+	ExpiredAt int64  `json:"expired_at"`
+	// empty if permanent, negative if expired
+	Remaining string `json:"remaining,omitempty"`
+	Status    string `json:"status"`
+}
+
+type expiryError struct {
+	Subject string `json:"subject"`
+	Error   string `json:"error"`
+}
+
+type expiryResult struct {
+	Within      string             `json:"within"`
+	Memberships []membershipExpiry `json:"memberships"`
+	Errors      []expiryError      `json:"errors,omitempty"`
+}
+
+// expiryCmd represents the expiry command
+var expiryCmd = &cobra.Command{
+	Use:   "expiry",
+	Short: "Report the expiry of the group memberships",
+	Long:  `Report the expiry of the group memberships`,
+}
+
+var expiryUserCmd = &cobra.Command{
+	Use:   "user [user_id...]",
+	Short: "Report the expiry of the group memberships of the users",
+	Long: `Report the expiry of the group memberships of the users, joined directly or via the departments.
+The status is EXPIRED, EXPIRING(expires within --within), PERMANENT(expired_at 4102444800, 2100-01-01) or OK.
+Exit with code 1 if some memberships expire within the window, 2 if query any user fail or the command fail
+(e.g. not login, invalid --within or --file), so it can be a nightly check.
+
+expiry user tom
+expiry user tom jerry --within 7d
+expiry user --file users.txt -o json
+
+The users file: one user id per line, the blank lines and the lines start with # are skipped.
+`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		if len(args) == 0 && file == "" {
+			return errors.New("expiry user {user_id}... [--file {users_file}] [--within 30d]")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		file, _ := cmd.Flags().GetString("file")
+		withinFlag, _ := cmd.Flags().GetString("within")
+
+		within, err := util.ParseDuration(withinFlag)
+		if err != nil {
+			logger.Error(err.Error())
+			setExitCode(expiryExitError)
+			return
+		}
+
+		users := args
+		if file != "" {
+			fileUsers, err := readUsersFile(file)
+			if err != nil {
+				logger.Error("read users file fail! %s", err.Error())
+				setExitCode(expiryExitError)
+				return
+			}
+			users = append(users, fileUsers...)
+		}
+		users = util.UniqueStrings(users)
+
+		client, _, err := newBackendClient()
+		if err != nil {
+			logger.Error(err.Error())
+			setExitCode(expiryExitError)
+			return
+		}
+
+		now := time.Now()
+		result := expiryResult{Within: withinFlag, Memberships: []membershipExpiry{}}
+		for _, user := range users {
+			subject, err := client.GetSubject("user", user)
+			if err != nil {
+				result.Errors = append(result.Errors, expiryError{Subject: "user:" + user, Error: err.Error()})
+				continue
+			}
+			result.Memberships = append(result.Memberships, membershipExpiries(subject, now, within)...)
+		}
+		sort.SliceStable(result.Memberships, func(i, j int) bool {
+			return result.Memberships[i].ExpiredAt < result.Memberships[j].ExpiredAt
+		})
+
+		printExpiryResult(result)
+	},
+}
+
+// membershipExpiries returns the expiry of all the groups of the subject
+func membershipExpiries(d *model.SubjectDetail, now time.Time, within time.Duration) []membershipExpiry {
+	expiries := []membershipExpiry{}
+	for _, g := range d.AllGroups() {
+		e := membershipExpiry{
+			Subject:   d.Subject.String(),
+			GroupPK:   g.PK,
+			From:      "direct",
+			ExpiredAt: g.PolicyExpiredAt,
+		}
+		if g.Department != nil {
+			e.From = fmt.Sprintf("department %s(%s)", g.Department.Name, g.Department.ID)
+		}
+
+		remaining := g.ExpiredAt().Sub(now)
+		switch {
+		case g.IsPermanent():
+			e.Status = expiryStatusPermanent
+		case g.IsExpired(now):
+			e.Status = expiryStatusExpired
+			e.Remaining = "-" + formatRemaining(-remaining)
+		case remaining <= within:
+			e.Status = expiryStatusExpiring
+			e.Remaining = formatRemaining(remaining)
+		default:
+			e.Status = expiryStatusOK
+			e.Remaining = formatRemaining(remaining)
+		}
+		expiries = append(expiries, e)
+	}
+	return expiries
+}
+
+// expiringCount returns the number of the memberships expire within the window
+func (r expiryResult) expiringCount() int {
+	count := 0
+	for _, m := range r.Memberships {
+		if m.Status == expiryStatusExpiring {
+			count++
+		}
+	}
+	return count
+}
+
+// exitCode returns 2 if query any subject fail, 1 if some memberships expire within the window
+func (r expiryResult) exitCode() int {
+	switch {
+	case len(r.Errors) > 0:
+		return expiryExitError
+	case r.expiringCount() > 0:
+		return expiryExitExpiring
+	}
+	return 0
+}
+
+func printExpiryResult(result expiryResult) {
+	setExitCode(result.exitCode())
+	expiring := result.expiringCount()
+
+	if output != "" {
+		printResult(printer.KindExpiry, result)
+		return
+	}
+
+	printResultAs(string(printer.FormatTable), printer.KindExpiry, result)
+	for _, e := range result.Errors {
+		logger.Error("query %s fail! %s", e.Subject, e.Error)
+	}
+	if expiring > 0 {
+		logger.Warn("%d membership(s) expire within %s", expiring, result.Within)
+		return
+	}
+	logger.Info("no membership expires within %s", result.Within)
+}
+
+// readUsersFile reads the user ids, one per line
+func readUsersFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var users []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		users = append(users, line)
+	}
+	return users, scanner.Err()
+}
+
+func init() {
+	expiryUserCmd.Flags().String("within", "30d", "the window of expiring, e.g. 30d, 12h")
+	expiryUserCmd.Flags().StringP("file", "f", "", "the file of the user ids, one per line")
+
+	expiryCmd.AddCommand(expiryUserCmd)
+	rootCmd.AddCommand(expiryCmd)
+}
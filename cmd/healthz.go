@@ -14,9 +14,7 @@ package cmd
 import (
 	"github.com/spf13/cobra"
 
-	"bk-iam-cli/pkg/client"
 	"bk-iam-cli/pkg/logger"
-	"bk-iam-cli/pkg/storage"
 )
 
 // healthzCmd represents the healthz command
@@ -25,15 +23,12 @@ var healthzCmd = &cobra.Command{
 	Short: "call /healthz to check if the iam backend service is health",
 	Long:  `call /healthz to check if the iam backend service is health`,
 	Run: func(cmd *cobra.Command, args []string) {
-		credential := storage.NewCredential(backendCredentialFile)
-		host, appCode, appSecret, err := credential.Read()
+		client, host, err := newBackendClient()
 		if err != nil {
 			logger.Error(err.Error())
 			return
 		}
 
-		client := client.NewIAMBackendClient(host, "", appCode, appSecret)
-
 		err = client.Healthz()
 		if err != nil {
 			logger.Error("healthz check fail: %s", host, err.Error())
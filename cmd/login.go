@@ -12,13 +12,17 @@
 package cmd
 
 import (
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/url"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 
 	"bk-iam-cli/pkg/client"
+	"bk-iam-cli/pkg/client/transport"
 	"bk-iam-cli/pkg/logger"
 	"bk-iam-cli/pkg/storage"
 )
@@ -30,41 +34,54 @@ var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Login via app_code/app_secret of IAM",
 	Long: `Login via app_code/app_secret of IAM. 
-The login credentials will be encrypted and store at current dir.
-And you should login every 1 hour.
+The login credentials will be stored at the dir of current context(or the one specified by --context),
+the app_secret is protected by the backend of --credential-backend.
+The credential expires after --ttl(default 1h), and you should login again after that.
+
+The host can be http://{iam_host}, https://{iam_host} or a bare {iam_host}(with optional port and path),
+https is tried first then http for the bare one. For the https host, the --ca-file/--insecure-skip-verify
+and the client certificate(--client-cert/--client-key, for the gateway requires mTLS) are stored with the login,
+and reused by the later commands.
 `,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 3 {
-			return errors.New("login http://{iam_host} {app_code} {app_secret}")
+			return errors.New("login {iam_host} {app_code} {app_secret}")
 		}
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		// NOTE:
 		// 执行login, 传入host地址/app_code/app_secret, 调用后台ping(可达), 并调用一个接口确认app_code/app_secret正确性;
-		// 如果正确, 将信息加密保存到本地, 2h过期
+		// 如果正确, 将信息加密保存到本地, --ttl 后过期(默认1h)
 		// 过期后需要重新登录
 		// 如果不正确, 提示用户;
 
-		// TODO: 可以执行clear, 清理掉login状态
-		// ? 问题: 怎么同时login到后台和saas
+		// NOTE: logout 清理掉login状态; saas 通过 saas login 登录
 
-		host := args[0]
 		appCode := args[1]
 		appSecret := args[2]
 
-		if !strings.HasPrefix(host, "http://") {
-			host = fmt.Sprintf("http://%s", host)
+		hosts, err := loginHosts(args[0])
+		if err != nil {
+			logger.Error(err.Error())
+			return
 		}
 
 		// 1. host is connectable : /ping
-		client := client.NewIAMBackendClient(host, "", appCode, appSecret)
-
-		err := client.Ping()
+		// NOTE: the stored tls settings of the last login are not used
+		t, err := newTransport(storage.TLSConfig{})
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+		host, err := probeHost(hosts, func(host string) error {
+			return client.NewIAMBackendClient(host, "", appCode, appSecret, client.WithTransport(t)).Ping()
+		})
 		if err != nil {
-			logger.Error("connect to host %s fail! %s\n", host, err.Error())
+			logger.Error("connect to host %s fail! %s\n", args[0], err.Error())
 			return
 		}
+		client := client.NewIAMBackendClient(host, "", appCode, appSecret, client.WithTransport(t))
 
 		// 2. the app_code/app_secret is valid: /api/v1/web/systems
 		_, err = client.ListSystems()
@@ -74,7 +91,16 @@ And you should login every 1 hour.
 		}
 
 		// 3. create the credential
-		credential := storage.NewCredential(backendCredentialFile)
+		c, err := loginContext()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+		credential, err := newLoginCredential(cmd, c.Path(backendCredentialFile), host, t.Config())
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
 		err = credential.Write(host, appCode, appSecret)
 		if err != nil {
 			logger.Error(err.Error())
@@ -86,6 +112,92 @@ And you should login every 1 hour.
 	},
 }
 
+// loginHosts returns the urls to try of the host, e.g. iam.example.com:8080/prefix,
+// https first then http if no scheme given
+func loginHosts(host string) ([]string, error) {
+	host = strings.TrimSpace(host)
+	bare := !strings.Contains(host, "://")
+	raw := host
+	if bare {
+		raw = "https://" + host
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid host `%s`, should be like https://{iam_host}[:port][/path]", host)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme `%s` of host %s, should be http or https", u.Scheme, host)
+	}
+
+	// the scheme is lower case, without the trailing slash
+	address := u.Host + strings.TrimRight(u.Path, "/")
+	if bare {
+		return []string{"https://" + address, "http://" + address}, nil
+	}
+	return []string{u.Scheme + "://" + address}, nil
+}
+
+// probeHost pings the hosts in order, returns the first connectable one;
+// stop at the certificate error, the host speaks https and should not fall back to http
+func probeHost(hosts []string, ping func(host string) error) (string, error) {
+	errs := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		err := ping(host)
+		if err == nil {
+			return host, nil
+		}
+		logger.Debug("ping %s fail! %s", host, err.Error())
+
+		errs = append(errs, fmt.Sprintf("%s: %s", host, err.Error()))
+		if certificateError(err) {
+			return "", fmt.Errorf("%s, please set --ca-file to verify the server certificate, "+
+				"or --insecure-skip-verify for test only", strings.Join(errs, "; "))
+		}
+	}
+	return "", errors.New(strings.Join(errs, "; "))
+}
+
+func certificateError(err error) bool {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+	)
+	return errors.As(err, &unknownAuthority) || errors.As(err, &hostname) || errors.As(err, &invalid)
+}
+
+// newLoginCredential returns the credential to write with the tls settings of the https host,
+// the files are stored as absolute paths, so the later commands can run in any dir
+func newLoginCredential(cmd *cobra.Command, file, host string, cfg transport.Config) (*storage.Credential, error) {
+	credential, err := newCredential(cmd, file)
+	if err != nil || !strings.HasPrefix(host, "https://") {
+		return credential, err
+	}
+
+	tls := storage.TLSConfig{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	for _, f := range []struct {
+		from string
+		to   *string
+	}{{cfg.CAFile, &tls.CAFile}, {cfg.CertFile, &tls.CertFile}, {cfg.KeyFile, &tls.KeyFile}} {
+		if f.from == "" {
+			continue
+		}
+		if *f.to, err = filepath.Abs(f.from); err != nil {
+			return nil, err
+		}
+	}
+	if tls.InsecureSkipVerify {
+		logger.Warn("the certificate of %s will not be verified by the later commands, please login again without "+
+			"--insecure-skip-verify to enable", host)
+	}
+
+	credential.SetTLS(tls)
+	return credential, nil
+}
+
 func init() {
 	rootCmd.AddCommand(loginCmd)
+	addCredentialBackendFlag(loginCmd)
+	addCredentialTTLFlag(loginCmd)
 }
@@ -0,0 +1,72 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/storage"
+)
+
+// logoutCmd represents the logout command
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Clear the login state of IAM",
+	Long: `Clear the login state of IAM in current context(or the one specified by --context),
+remove the credential and the system selected by 'use'.
+With --saas, remove the credential of IAM SaaS only.
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := activeContext()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		saas, _ := cmd.Flags().GetBool("saas")
+		file := backendCredentialFile
+		if saas {
+			file = saasCredentialFile
+		}
+
+		err = storage.NewCredential(c.Path(file)).RemoveCredential()
+		if err != nil && !os.IsNotExist(err) {
+			logger.Error("remove credential fail! %s", err.Error())
+			return
+		}
+		notLogin := os.IsNotExist(err)
+
+		if !saas {
+			err = storage.RemoveUseSystem(c.Path(useSystemFile))
+			if err != nil && !os.IsNotExist(err) {
+				logger.Error("remove use system fail! %s", err.Error())
+				return
+			}
+		}
+
+		if notLogin {
+			logger.Warn("not login")
+			return
+		}
+		logger.Info("success")
+	},
+}
+
+func init() {
+	logoutCmd.Flags().Bool("saas", false, "logout from IAM SaaS instead of the backend")
+
+	rootCmd.AddCommand(logoutCmd)
+}
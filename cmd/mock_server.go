@@ -0,0 +1,114 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/mockserver"
+)
+
+// mockServerCmd represents the mock-server command
+var mockServerCmd = &cobra.Command{
+	Use:   "mock-server",
+	Short: "Run an offline mock IAM backend and SaaS server",
+	Long: `Run an offline mock IAM backend and SaaS server, the responses are from the fixture files.
+The built-in fixtures are used if --fixtures not set.
+
+The fixture of a request is looked up from the most specific name to the generic one, e.g.
+query_policy.{subject_type}.{subject_id}.{action}.json > query_policy.{subject_type}.{subject_id}.json
+> query_policy.json
+
+mock-server --addr 127.0.0.1:9000 --fixtures ./fixtures
+mock-server --fault /api/v1/debug/query/policy=status:500,rate:0.5 --fault '*=latency:200ms'
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("addr")
+		fixtures, _ := cmd.Flags().GetString("fixtures")
+		appCode, _ := cmd.Flags().GetString("app-code")
+		appSecret, _ := cmd.Flags().GetString("app-secret")
+		latency, _ := cmd.Flags().GetDuration("latency")
+		faultSpecs, _ := cmd.Flags().GetStringArray("fault")
+
+		faults := make([]mockserver.Fault, 0, len(faultSpecs))
+		for _, spec := range faultSpecs {
+			f, err := mockserver.ParseFault(spec)
+			if err != nil {
+				logger.Error(err.Error())
+				return
+			}
+			faults = append(faults, f)
+		}
+
+		server, err := mockserver.New(mockserver.Options{
+			FixturesDir: fixtures,
+			AppCode:     appCode,
+			AppSecret:   appSecret,
+			Latency:     latency,
+			Faults:      faults,
+		})
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		logger.Info("mock server listening on http://%s", addr)
+		logger.Info("login with: iam-cli login http://%s %s %s", addr, appCode, appSecret)
+		logger.Info("saas login with: iam-cli saas login http://%s %s %s", addr, appCode, appSecret)
+
+		err = http.ListenAndServe(addr, accessLog(server))
+		if err != nil {
+			logger.Error("run mock server fail! %s", err.Error())
+			return
+		}
+	},
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		logger.Debug("%s %s %d %s", r.Method, r.URL.RequestURI(), recorder.status, time.Since(start))
+		if recorder.status != http.StatusOK {
+			logger.Warn("%s %s %d", r.Method, r.URL.RequestURI(), recorder.status)
+		}
+	})
+}
+
+func init() {
+	mockServerCmd.Flags().String("addr", "127.0.0.1:9000", "the address to listen")
+	mockServerCmd.Flags().String("fixtures", "", "the dir of the fixture files (default is the built-in fixtures)")
+	mockServerCmd.Flags().String("app-code", mockserver.DefaultAppCode, "the app_code accepted")
+	mockServerCmd.Flags().String("app-secret", mockserver.DefaultAppSecret, "the app_secret accepted")
+	mockServerCmd.Flags().Duration("latency", 0, "the latency added to all the requests, e.g. 100ms")
+	mockServerCmd.Flags().StringArray("fault", []string{},
+		"inject error/latency, {path}={key}:{value}[,{key}:{value}], the keys are status/code/latency/rate, "+
+			"can be set multiple times")
+
+	rootCmd.AddCommand(mockServerCmd)
+}
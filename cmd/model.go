@@ -0,0 +1,386 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/model"
+	"bk-iam-cli/pkg/printer"
+)
+
+type modelActionRow struct {
+	ID                   string   `json:"id"`
+	PK                   int64    `json:"pk"`
+	Name                 string   `json:"name"`
+	Type                 string   `json:"type"`
+	AuthType             string   `json:"auth_type"`
+	RelatedResourceTypes []string `json:"related_resource_types"`
+	RelatedActions       []string `json:"related_actions"`
+}
+
+type modelResourceTypeRow struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	NameEn  string   `json:"name_en"`
+	Parents []string `json:"parents"`
+	Path    string   `json:"path"`
+}
+
+type modelInstanceSelectionRow struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	IsDynamic         bool   `json:"is_dynamic"`
+	ResourceTypeChain string `json:"resource_type_chain"`
+}
+
+// modelActionDetail is the action and the resource types/instance selections/actions it relates to
+type modelActionDetail struct {
+	model.Action
+
+	PK                 int64                     `json:"pk"`
+	InstanceSelections []model.InstanceSelection `json:"instance_selections"`
+	// the related actions, the missing ones only have the id
+	RelatedActionDetails []model.Action `json:"related_action_details"`
+	// the actions which relate to this action
+	DependedBy []string `json:"depended_by"`
+}
+
+// modelCmd represents the model command
+var modelCmd = &cobra.Command{
+	Use:   "model",
+	Short: "Browse the permission model of the system",
+	Long: `Browse the permission model of the system(set by 'use'), the actions, resource types and instance selections.
+The lists are printed as table by default, use -o json/yaml for the details.
+`,
+}
+
+var modelActionsCmd = &cobra.Command{
+	Use:   "actions",
+	Short: "List the actions with the related resource types",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, actions, err := queryModel(true)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		rows := make([]modelActionRow, 0, len(m.Actions))
+		for _, a := range m.Actions {
+			rows = append(rows, modelActionRow{
+				ID:                   a.ID,
+				PK:                   actions.PK(a.ID),
+				Name:                 a.Name,
+				Type:                 a.Type,
+				AuthType:             a.AuthType,
+				RelatedResourceTypes: relatedResourceTypeIDs(m.System.ID, a),
+				RelatedActions:       nonNilStrings(a.RelatedActions),
+			})
+		}
+		printModelResult(printer.KindModelActions, rows)
+	},
+}
+
+var modelActionCmd = &cobra.Command{
+	Use:   "action [action_id]",
+	Short: "Show the related resource types, instance selections and related actions of the action",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("model action {action_id}")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		m, actions, err := queryModel(true)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		detail, err := actionDetail(m, actions, args[0])
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		if output != "" {
+			printResult(printer.KindUnknown, detail)
+			return
+		}
+		fmt.Print(formatActionDetail(m.System.ID, detail))
+	},
+}
+
+var modelResourceTypesCmd = &cobra.Command{
+	Use:   "resource-types",
+	Short: "List the resource types with the parents",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, _, err := queryModel(false)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		rows := make([]modelResourceTypeRow, 0, len(m.ResourceTypes))
+		for _, rt := range m.ResourceTypes {
+			row := modelResourceTypeRow{ID: rt.ID, Name: rt.Name, NameEn: rt.NameEn, Parents: []string{}}
+			for _, p := range rt.Parents {
+				row.Parents = append(row.Parents, formatRef(m.System.ID, p))
+			}
+			if rt.ProviderConfig != nil {
+				row.Path = rt.ProviderConfig.Path
+			}
+			rows = append(rows, row)
+		}
+		printModelResult(printer.KindModelResourceTypes, rows)
+	},
+}
+
+var modelInstanceSelectionsCmd = &cobra.Command{
+	Use:   "instance-selections",
+	Short: "List the instance selections with the resource type chain",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, _, err := queryModel(false)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		rows := make([]modelInstanceSelectionRow, 0, len(m.InstanceSelections))
+		for _, is := range m.InstanceSelections {
+			rows = append(rows, modelInstanceSelectionRow{
+				ID:                is.ID,
+				Name:              is.Name,
+				IsDynamic:         is.IsDynamic,
+				ResourceTypeChain: formatChain(m.System.ID, is.ResourceTypeChain),
+			})
+		}
+		printModelResult(printer.KindModelInstanceSelections, rows)
+	},
+}
+
+var modelGraphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Show the dependencies between the actions as a graph",
+	Long: `Show the dependencies between the actions(related_actions) as a graph in dot or mermaid,
+the related actions not in the model are marked in red.
+
+model graph --format dot | dot -Tsvg -o actions.svg
+model graph --format mermaid
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+
+		m, _, err := queryModel(false)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		g := actionGraph(m)
+		if output != "" {
+			printResult(printer.KindUnknown, g)
+			return
+		}
+		if err = printer.PrintGraph(os.Stdout, g, format); err != nil {
+			logger.Error(err.Error())
+		}
+	},
+}
+
+// queryModel queries the model of the system, and the actions with pk if withPK
+func queryModel(withPK bool) (*model.SystemModel, *model.ActionList, error) {
+	client, system, err := newSystemBackendClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err := client.GetModel(system)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query model fail! %w", err)
+	}
+	if m.System.ID == "" {
+		m.System.ID = system
+	}
+
+	actions := &model.ActionList{}
+	if withPK {
+		if actions, err = client.GetActions(system); err != nil {
+			return nil, nil, fmt.Errorf("query action fail! %w", err)
+		}
+	}
+	return m, actions, nil
+}
+
+// printModelResult prints the list as table by default
+func printModelResult(kind printer.Kind, data interface{}) {
+	if output != "" {
+		printResult(kind, data)
+		return
+	}
+	printResultAs(string(printer.FormatTable), kind, data)
+}
+
+func actionDetail(m *model.SystemModel, actions *model.ActionList, actionID string) (*modelActionDetail, error) {
+	index := map[string]model.Action{}
+	for _, a := range m.Actions {
+		index[a.ID] = a
+	}
+	action, ok := index[actionID]
+	if !ok {
+		return nil, fmt.Errorf("action `%s` not found in system %s", actionID, m.System.ID)
+	}
+
+	selections := map[string]model.InstanceSelection{}
+	for _, is := range m.InstanceSelections {
+		selections[is.ID] = is
+	}
+
+	detail := &modelActionDetail{
+		Action:               action,
+		PK:                   actions.PK(actionID),
+		InstanceSelections:   []model.InstanceSelection{},
+		RelatedActionDetails: []model.Action{},
+		DependedBy:           []string{},
+	}
+	for _, rrt := range action.RelatedResourceTypes {
+		for _, ref := range rrt.InstanceSelections {
+			if is, ok := selections[ref.ID]; ok && (ref.System == "" || ref.System == m.System.ID) {
+				detail.InstanceSelections = append(detail.InstanceSelections, is)
+			}
+		}
+	}
+	for _, id := range action.RelatedActions {
+		related, ok := index[id]
+		if !ok {
+			related = model.Action{ID: id}
+		}
+		detail.RelatedActionDetails = append(detail.RelatedActionDetails, related)
+	}
+	for _, a := range m.Actions {
+		for _, id := range a.RelatedActions {
+			if id == actionID {
+				detail.DependedBy = append(detail.DependedBy, a.ID)
+			}
+		}
+	}
+	return detail, nil
+}
+
+func formatActionDetail(system string, d *modelActionDetail) string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "action: %s(%s), pk=%d, type=%s, auth_type=%s\n", d.ID, d.Name, d.PK, d.Type, d.AuthType)
+
+	fmt.Fprintln(sb, "related resource types:")
+	if len(d.RelatedResourceTypes) == 0 {
+		fmt.Fprintln(sb, "  - (none)")
+	}
+	for _, rrt := range d.RelatedResourceTypes {
+		fmt.Fprintf(sb, "  - %s (selection_mode=%s)\n",
+			formatRef(system, model.ResourceTypeRef{System: rrt.System, ID: rrt.ID}), rrt.SelectionMode)
+		for _, ref := range rrt.InstanceSelections {
+			chain := "(not found)"
+			for _, is := range d.InstanceSelections {
+				if is.ID == ref.ID {
+					chain = fmt.Sprintf("%s: %s", is.Name, formatChain(system, is.ResourceTypeChain))
+				}
+			}
+			fmt.Fprintf(sb, "      instance selection %s %s\n", formatRef(system, ref), chain)
+		}
+	}
+
+	fmt.Fprintln(sb, "related actions:")
+	if len(d.RelatedActionDetails) == 0 {
+		fmt.Fprintln(sb, "  - (none)")
+	}
+	for _, a := range d.RelatedActionDetails {
+		if a.Name == "" {
+			fmt.Fprintf(sb, "  - %s (not found)\n", a.ID)
+			continue
+		}
+		fmt.Fprintf(sb, "  - %s(%s)\n", a.ID, a.Name)
+	}
+
+	if len(d.DependedBy) > 0 {
+		fmt.Fprintf(sb, "depended by: %s\n", strings.Join(d.DependedBy, ", "))
+	}
+	return sb.String()
+}
+
+// actionGraph returns the graph of the actions, the edge is from the action to the related action
+func actionGraph(m *model.SystemModel) *printer.Graph {
+	g := &printer.Graph{Name: m.System.ID, Nodes: []printer.GraphNode{}, Edges: []printer.GraphEdge{}}
+
+	defined := map[string]bool{}
+	for _, a := range m.Actions {
+		defined[a.ID] = true
+		g.Nodes = append(g.Nodes, printer.GraphNode{ID: a.ID, Label: fmt.Sprintf("%s\n%s", a.ID, a.Name)})
+	}
+	for _, a := range m.Actions {
+		for _, id := range a.RelatedActions {
+			if !defined[id] {
+				defined[id] = true
+				g.Nodes = append(g.Nodes, printer.GraphNode{ID: id, Label: id, Missing: true})
+			}
+			g.Edges = append(g.Edges, printer.GraphEdge{From: a.ID, To: id})
+		}
+	}
+	return g
+}
+
+func relatedResourceTypeIDs(system string, a model.Action) []string {
+	ids := make([]string, 0, len(a.RelatedResourceTypes))
+	for _, rrt := range a.RelatedResourceTypes {
+		ids = append(ids, formatRef(system, model.ResourceTypeRef{System: rrt.System, ID: rrt.ID}))
+	}
+	return ids
+}
+
+// formatRef returns the id, with the system prefix if it belongs to another system, e.g. bk_cmdb:host
+func formatRef(system string, ref model.ResourceTypeRef) string {
+	if ref.System == "" || ref.System == system {
+		return ref.ID
+	}
+	return ref.System + ":" + ref.ID
+}
+
+func formatChain(system string, chain []model.ResourceTypeRef) string {
+	ids := make([]string, 0, len(chain))
+	for _, ref := range chain {
+		ids = append(ids, formatRef(system, ref))
+	}
+	return strings.Join(ids, " -> ")
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func init() {
+	modelGraphCmd.Flags().String("format", printer.TreeFormatDot, "the graph format, dot or mermaid")
+
+	modelCmd.AddCommand(modelActionsCmd)
+	modelCmd.AddCommand(modelActionCmd)
+	modelCmd.AddCommand(modelResourceTypesCmd)
+	modelCmd.AddCommand(modelInstanceSelectionsCmd)
+	modelCmd.AddCommand(modelGraphCmd)
+	rootCmd.AddCommand(modelCmd)
+}
@@ -0,0 +1,226 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/model"
+	"bk-iam-cli/pkg/printer"
+)
+
+// the exit codes of model export/diff, e.g. in the ci
+const (
+	// the models are different
+	modelDiffExitChanged = 1
+	// the model can not be queried or read
+	modelExitError = 2
+)
+
+type modelDiffResult struct {
+	Left  string           `json:"left"`
+	Right string           `json:"right"`
+	Equal bool             `json:"equal"`
+	Diff  *model.ModelDiff `json:"diff"`
+}
+
+var modelExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the normalized permission model as a snapshot",
+	Long: `Export the permission model of the system(set by 'use' or --system) as a stable snapshot,
+the actions, resource types and instance selections are sorted by id, and the pks of the actions are removed,
+so the snapshots of the same model are identical and can be saved in git, compared by 'model diff' or 'model lint'.
+
+Exit with code 2 if the model can not be queried.
+
+model export > model.json
+model export --system bk_cmdb -o yaml
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		m, _, err := queryModel(false)
+		if err != nil {
+			logger.Error(err.Error())
+			setExitCode(modelExitError)
+			return
+		}
+		printResult(printer.KindUnknown, m.Normalize())
+	},
+}
+
+var modelDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare the permission models between two contexts or files",
+	Long: `Compare the permission models between two contexts or files, report the added/removed/changed
+actions, resource types and instance selections, and the changed fields of them.
+The models are normalized before compared, so the order of the lists does not matter.
+Exit with code 1 if the models are different, 2 if any model can not be queried or read.
+
+model diff --context stage --context prod
+model diff --file model.json --file new.json
+model diff --file model.json                  # compare the snapshot with the system of the current context
+model diff --context stage --context prod -o json
+
+The file is the output of "model export" or "query model", use "-" to read one from stdin.
+`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		files, _ := cmd.Flags().GetStringArray("file")
+		if len(args) != 0 {
+			return errors.New("model diff takes no arguments, use --context or --file")
+		}
+		switch {
+		case len(files) > 2:
+			return errors.New("model diff --file {file1} --file {file2}")
+		case len(files) > 0 && len(contextNames) > 1:
+			return errors.New("model diff accepts two contexts or files, not both")
+		case len(files) == 0 && len(contextNames) != 2:
+			return errors.New("model diff --context {context1} --context {context2}")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		files, _ := cmd.Flags().GetStringArray("file")
+
+		var (
+			left, right           *model.SystemModel
+			leftLabel, rightLabel string
+			err                   error
+		)
+		switch len(files) {
+		case 2:
+			leftLabel, rightLabel = files[0], files[1]
+			left, err = readModelFile(files[0])
+			if err == nil {
+				right, err = readModelFile(files[1])
+			}
+		case 1:
+			leftLabel = files[0]
+			left, err = readModelFile(files[0])
+			if err == nil {
+				right, _, err = queryModel(false)
+			}
+			if err == nil {
+				rightLabel = right.System.ID
+			}
+		default:
+			contexts := contextNames
+			err = inContext(contexts[0], func() (err error) {
+				left, _, err = queryModel(false)
+				return
+			})
+			if err == nil {
+				err = inContext(contexts[1], func() (err error) {
+					right, _, err = queryModel(false)
+					return
+				})
+			}
+			if err == nil {
+				leftLabel = fmt.Sprintf("%s %s", contexts[0], left.System.ID)
+				rightLabel = fmt.Sprintf("%s %s", contexts[1], right.System.ID)
+			}
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			setExitCode(modelExitError)
+			return
+		}
+
+		d := model.DiffModels(left, right)
+		if !d.Equal() {
+			setExitCode(modelDiffExitChanged)
+		}
+		printModelDiffResult(modelDiffResult{
+			Left:  leftLabel,
+			Right: rightLabel,
+			Equal: d.Equal(),
+			Diff:  d,
+		})
+	},
+}
+
+func printModelDiffResult(result modelDiffResult) {
+	if output != "" {
+		printResult(printer.KindUnknown, result)
+		return
+	}
+
+	fmt.Println(color.Red.Sprintf("--- %s", result.Left))
+	fmt.Println(color.Green.Sprintf("+++ %s", result.Right))
+
+	d := result.Diff
+	if len(d.System) > 0 {
+		fmt.Println("system:")
+		printFieldChanges("  ", d.System)
+	}
+	printEntityDiff("actions", d.Actions)
+	printEntityDiff("resource_types", d.ResourceTypes)
+	printEntityDiff("instance_selections", d.InstanceSelections)
+
+	if result.Equal {
+		logger.Info("the models are the same")
+		return
+	}
+	logger.Warn("actions: %s; resource_types: %s; instance_selections: %s",
+		entityDiffSummary(d.Actions), entityDiffSummary(d.ResourceTypes), entityDiffSummary(d.InstanceSelections))
+}
+
+func printEntityDiff(kind string, d model.EntityDiff) {
+	if d.Equal() {
+		return
+	}
+
+	fmt.Printf("%s:\n", kind)
+	for _, id := range d.Removed {
+		fmt.Println(color.Red.Sprintf("  - %s", id))
+	}
+	for _, id := range d.Added {
+		fmt.Println(color.Green.Sprintf("  + %s", id))
+	}
+	for _, c := range d.Changed {
+		fmt.Println(color.Yellow.Sprintf("  ~ %s", c.ID))
+		printFieldChanges("      ", c.Fields)
+	}
+}
+
+func printFieldChanges(indent string, fields []model.FieldChange) {
+	for _, f := range fields {
+		fmt.Printf("%s%s: %s -> %s\n", indent, f.Field, formatFieldValue(f.Left), formatFieldValue(f.Right))
+	}
+}
+
+// formatFieldValue returns the value as compact json, e.g. "flow" or [{"system_id":"bk_sops","id":"flow"}]
+func formatFieldValue(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func entityDiffSummary(d model.EntityDiff) string {
+	return fmt.Sprintf("%d added, %d removed, %d changed", len(d.Added), len(d.Removed), len(d.Changed))
+}
+
+func init() {
+	modelDiffCmd.Flags().StringArray("file", nil, "the model file, specified twice, or once to compare with the system")
+
+	modelCmd.AddCommand(modelExportCmd)
+	modelCmd.AddCommand(modelDiffCmd)
+}
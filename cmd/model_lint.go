@@ -0,0 +1,214 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/gookit/color"
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/lint"
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/model"
+	"bk-iam-cli/pkg/printer"
+)
+
+// the exit codes of lint, e.g. in the ci
+const (
+	// any error found
+	lintExitError = 1
+	// the lint fail, e.g. the model can not be read or the rule unknown
+	lintExitFail = 2
+)
+
+type modelLintResult struct {
+	System   string         `json:"system"`
+	Errors   int            `json:"errors"`
+	Warnings int            `json:"warnings"`
+	Findings []lint.Finding `json:"findings"`
+}
+
+var modelLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Validate the permission model for the common mistakes",
+	Long: `Validate the permission model for the common mistakes, e.g. the action refers to an unknown resource type,
+the related action not exists, or the resource type chain of the instance selection is broken.
+The model is queried from the system(set by 'use' or --system), or read from the file(the output of 'model export').
+Exit with code 1 if any error found, 2 if the lint fail, e.g. the model can not be read.
+
+model lint
+model lint --system bk_cmdb
+model lint --file model.json --disable required-fields
+model lint --list-rules
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		file, _ := cmd.Flags().GetString("file")
+		listRules, _ := cmd.Flags().GetBool("list-rules")
+		enabled, _ := cmd.Flags().GetStringSlice("rules")
+		disabled, _ := cmd.Flags().GetStringSlice("disable")
+
+		if listRules {
+			printLintRules()
+			return
+		}
+
+		rules, err := selectLintRules(enabled, disabled)
+		if err != nil {
+			logger.Error(err.Error())
+			setExitCode(lintExitFail)
+			return
+		}
+
+		var m *model.SystemModel
+		if file != "" {
+			m, err = readModelFile(file)
+		} else {
+			m, _, err = queryModel(false)
+		}
+		if err != nil {
+			logger.Error(err.Error())
+			setExitCode(lintExitFail)
+			return
+		}
+
+		result := modelLintResult{System: m.System.ID, Findings: lint.Lint(m, rules)}
+		for _, f := range result.Findings {
+			switch f.Severity {
+			case lint.SeverityError:
+				result.Errors++
+			case lint.SeverityWarning:
+				result.Warnings++
+			}
+		}
+		if lint.HasErrors(result.Findings) {
+			setExitCode(lintExitError)
+		}
+
+		printModelLintResult(result)
+	},
+}
+
+// selectLintRules returns the registered rules, only the enabled ones if specified, without the disabled ones
+func selectLintRules(enabled, disabled []string) ([]lint.Rule, error) {
+	known := map[string]bool{}
+	for _, r := range lint.Rules() {
+		known[r.Name()] = true
+	}
+	for _, name := range append(append([]string{}, enabled...), disabled...) {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown lint rule `%s`, see --list-rules", name)
+		}
+	}
+
+	skip := map[string]bool{}
+	for _, name := range disabled {
+		skip[name] = true
+	}
+	only := map[string]bool{}
+	for _, name := range enabled {
+		only[name] = true
+	}
+
+	rules := []lint.Rule{}
+	for _, r := range lint.Rules() {
+		if skip[r.Name()] || (len(only) > 0 && !only[r.Name()]) {
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// readModelFile reads the model from the file, the output of 'model export' or 'query model -o json',
+// the whole api response with `data` is also accepted
+func readModelFile(file string) (*model.SystemModel, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read model file fail! %w", err)
+	}
+
+	var wrapper struct {
+		Data *model.SystemModel `json:"data"`
+	}
+	if err = json.Unmarshal(data, &wrapper); err == nil && wrapper.Data != nil {
+		return wrapper.Data, nil
+	}
+
+	m := &model.SystemModel{}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid model file %s! %w", file, err)
+	}
+	if m.System.ID == "" && len(m.Actions) == 0 && len(m.ResourceTypes) == 0 {
+		return nil, fmt.Errorf("invalid model file %s! no system, actions or resource types", file)
+	}
+	return m, nil
+}
+
+func printLintRules() {
+	rows := []map[string]interface{}{}
+	for _, r := range lint.Rules() {
+		rows = append(rows, map[string]interface{}{
+			"name":        r.Name(),
+			"severity":    r.Severity(),
+			"description": r.Description(),
+		})
+	}
+	printModelResult(printer.KindLintRules, rows)
+}
+
+func printModelLintResult(result modelLintResult) {
+	if output != "" {
+		printResult(printer.KindUnknown, result)
+		return
+	}
+
+	for _, f := range result.Findings {
+		severity := string(f.Severity)
+		switch f.Severity {
+		case lint.SeverityError:
+			severity = color.Red.Sprint(severity)
+		case lint.SeverityWarning:
+			severity = color.Yellow.Sprint(severity)
+		}
+		fmt.Printf("%s [%s] %s: %s\n", severity, f.Rule, f.Path, f.Message)
+	}
+
+	switch {
+	case result.Errors > 0:
+		logger.Error("%d error(s), %d warning(s) found in the model of %s", result.Errors, result.Warnings, result.System)
+	case result.Warnings > 0:
+		logger.Warn("%d warning(s) found in the model of %s", result.Warnings, result.System)
+	default:
+		logger.Info("no mistakes found in the model of %s", result.System)
+	}
+}
+
+func init() {
+	modelLintCmd.Flags().StringP("file", "f", "", "the model file to validate, - for stdin")
+	modelLintCmd.Flags().StringSlice("rules", nil, "only run the rules, comma separated")
+	modelLintCmd.Flags().StringSlice("disable", nil, "skip the rules, comma separated")
+	modelLintCmd.Flags().Bool("list-rules", false, "list all the rules")
+
+	modelCmd.AddCommand(modelLintCmd)
+}
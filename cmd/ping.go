@@ -14,9 +14,7 @@ package cmd
 import (
 	"github.com/spf13/cobra"
 
-	"bk-iam-cli/pkg/client"
 	"bk-iam-cli/pkg/logger"
-	"bk-iam-cli/pkg/storage"
 )
 
 // pingCmd represents the ping command
@@ -26,15 +24,12 @@ var pingCmd = &cobra.Command{
 	Long:  `call /ping to check if the iam backend service is alive`,
 	Run: func(cmd *cobra.Command, args []string) {
 
-		credential := storage.NewCredential(backendCredentialFile)
-		host, appCode, appSecret, err := credential.Read()
+		client, host, err := newBackendClient()
 		if err != nil {
 			logger.Error(err.Error())
 			return
 		}
 
-		client := client.NewIAMBackendClient(host, "", appCode, appSecret)
-
 		err = client.Ping()
 		if err != nil {
 			logger.Error("connect to host %s fail! %s", host, err.Error())
@@ -0,0 +1,229 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/client"
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/printer"
+)
+
+const defaultPolicyListPageSize = 100
+
+// policyCmd represents the policy command
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Query the policies of the system, the same as the system pulls",
+	Long: `Query the policies of the system(selected by use),
+the same as the system pulls via /api/v1/systems/{system}/policies
+policy get {policy_id}
+policy list --action {action_id} [--page-size 100] [--timestamp 1642493707]
+policy subjects {policy_id,policy_id}
+`,
+}
+
+var policyGetCmd = &cobra.Command{
+	Use:   "get [policy_id]",
+	Short: "Get a policy by id",
+	Long:  `Get a policy by id`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("policy get {policy_id}")
+		}
+		if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+			return errors.New("policy get {policy_id}, policy_id should be an integer")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		policyID, _ := strconv.ParseInt(args[0], 10, 64)
+
+		client, _, err := newSystemBackendClient()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		data, err := client.PolicyGet(policyID)
+		if err != nil {
+			logger.Error("policy get fail! %s", err.Error())
+			return
+		}
+		printResult(printer.KindPolicy, data)
+	},
+}
+
+var policyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all the policies of an action",
+	Long: `List all the policies of an action, will fetch all the pages automatically.
+All the pages are fetched with the same timestamp, the policies expired before the timestamp are excluded.
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		action, _ := cmd.Flags().GetString("action")
+		pageSize, _ := cmd.Flags().GetInt("page-size")
+		timestamp, _ := cmd.Flags().GetInt64("timestamp")
+
+		if action == "" {
+			logger.Error("policy list --action {action_id}, action required")
+			return
+		}
+		if pageSize <= 0 {
+			logger.Error("--page-size should be greater than 0")
+			return
+		}
+
+		client, _, err := newSystemBackendClient()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		data, err := listAllPolicies(client, action, pageSize, timestamp)
+		if err != nil {
+			logger.Error("policy list fail! %s", err.Error())
+			return
+		}
+		printResult(printer.KindPolicyList, data)
+	},
+}
+
+var policySubjectsCmd = &cobra.Command{
+	Use:   "subjects [policy_id,policy_id]",
+	Short: "Query the subjects of the policies",
+	Long:  `Query the subjects of the policies, policy ids are separated by comma`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("policy subjects {policy_id,policy_id}")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		parts := strings.Split(args[0], ",")
+		policyIDs := make([]int64, 0, len(parts))
+		for _, p := range parts {
+			p = strings.TrimSpace(p)
+			if p == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(p, 10, 64)
+			if err != nil {
+				logger.Error("policy id should be an integer")
+				return
+			}
+			policyIDs = append(policyIDs, id)
+		}
+
+		client, _, err := newSystemBackendClient()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		data, err := client.PolicySubjects(policyIDs)
+		if err != nil {
+			logger.Error("policy subjects fail! %s", err.Error())
+			return
+		}
+		printResult(printer.KindPolicySubjects, data)
+	},
+}
+
+// listAllPolicies fetch all pages of the policies; the timestamp of the first page is used for the rest pages,
+// so the result is consistent even the policies changed during the fetching
+func listAllPolicies(
+	c client.IAMBackendClient,
+	action string,
+	pageSize int,
+	timestamp int64,
+) (map[string]interface{}, error) {
+	var (
+		metadata interface{}
+		count    int64
+	)
+	results := make([]interface{}, 0)
+
+	for page := 1; ; page++ {
+		body := map[string]interface{}{
+			"action_id": action,
+			"page":      page,
+			"page_size": pageSize,
+		}
+		if timestamp > 0 {
+			body["timestamp"] = timestamp
+		}
+
+		data, err := c.PolicyList(body)
+		if err != nil {
+			return nil, err
+		}
+
+		if page == 1 {
+			metadata = data["metadata"]
+			count = toInt64(data["count"])
+			if timestamp <= 0 {
+				if m, ok := metadata.(map[string]interface{}); ok {
+					timestamp = toInt64(m["timestamp"])
+				}
+			}
+		}
+
+		pageResults, _ := data["results"].([]interface{})
+		results = append(results, pageResults...)
+
+		// NOTE: the count may be missing or 0, only the short page means the end then
+		if len(pageResults) < pageSize || (count > 0 && int64(len(results)) >= count) {
+			break
+		}
+	}
+	if count <= 0 {
+		count = int64(len(results))
+	}
+
+	return map[string]interface{}{
+		"metadata": metadata,
+		"count":    count,
+		"results":  results,
+	}, nil
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case int64:
+		return n
+	case int:
+		return int64(n)
+	default:
+		return 0
+	}
+}
+
+func init() {
+	policyListCmd.Flags().String("action", "", "the action id")
+	policyListCmd.Flags().Int("page-size", defaultPolicyListPageSize, "the page size of each request")
+	policyListCmd.Flags().Int64("timestamp", 0, "the timestamp to filter expired policies (default is now)")
+
+	policyCmd.AddCommand(policyGetCmd)
+	policyCmd.AddCommand(policyListCmd)
+	policyCmd.AddCommand(policySubjectsCmd)
+
+	rootCmd.AddCommand(policyCmd)
+}
@@ -12,14 +12,16 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
 
 	"github.com/spf13/cobra"
 
-	"bk-iam-cli/pkg/client"
 	"bk-iam-cli/pkg/logger"
-	"bk-iam-cli/pkg/storage"
+	"bk-iam-cli/pkg/model"
+	"bk-iam-cli/pkg/printer"
 )
 
 // queryCmd represents the query command
@@ -27,6 +29,10 @@ var queryCmd = &cobra.Command{
 	Use:   "query [model/action/subject/policy]",
 	Short: "Query data of model/action/subject/policy",
 	Long: `Query data of model/action/subject/policy
+
+query policy {subject_type} {subject_id} {action} [--force] [--debug|--no-debug]
+  --force: bypass the cache, all data from database
+  --debug: print the debug info(the steps, the policies and expressions queried) as a trace
 `,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) == 0 {
@@ -48,21 +54,18 @@ var queryCmd = &cobra.Command{
 		// 问题: 参数怎么传?
 		//
 
-		system, err := storage.ReadUseSystem()
+		system, err := readUseSystem()
 		if err != nil {
 			logger.Error(err.Error())
 			return
 		}
 
-		credential := storage.NewCredential(backendCredentialFile)
-		host, appCode, appSecret, err := credential.Read()
+		client, _, err := newBackendClient()
 		if err != nil {
 			logger.Error(err.Error())
 			return
 		}
 
-		client := client.NewIAMBackendClient(host, "", appCode, appSecret)
-
 		switch args[0] {
 		// query model   查询系统权限模型
 		case "model":
@@ -71,7 +74,7 @@ var queryCmd = &cobra.Command{
 				logger.Error("query model fail!", err.Error())
 				return
 			}
-			logger.PrettyJson(data)
+			printResult(printer.KindModel, data)
 		// query action  查询系统action列表
 		case "action":
 			data, err := client.QueryAction(system)
@@ -79,7 +82,7 @@ var queryCmd = &cobra.Command{
 				logger.Error("query action fail!", err.Error())
 				return
 			}
-			logger.PrettyJson(data)
+			printResult(printer.KindAction, data)
 		// query subject 查询subject机器上级关系(部门/部门-组/组); 参数: type=user&id=x
 		case "subject":
 			if len(args) != 3 {
@@ -104,7 +107,7 @@ var queryCmd = &cobra.Command{
 				logger.Error("query action fail!", err.Error())
 				return
 			}
-			logger.PrettyJson(data)
+			printResult(printer.KindSubject, data)
 		// query policy  查询策略; 参数: subject_type=&subject_id=&action=; 以及&force=1&debug=1
 		case "policy":
 			if len(args) != 4 {
@@ -125,19 +128,53 @@ var queryCmd = &cobra.Command{
 				return
 			}
 
-			// TODO: debug & force 怎么搞?
-			data, err := client.QueryPolicy(system, subjectType, subjectID, action, false, true)
+			// --force: 不走缓存, 全部从数据库查询; --debug: 返回并打印每一步的调试信息
+			force := apiForceEnabled()
+			if !apiDebugEnabled() {
+				data, err := client.QueryPolicy(system, subjectType, subjectID, action, force, false)
+				if err != nil {
+					logger.Error("query policy fail! %s", err.Error())
+					return
+				}
+				printResult(printer.KindPolicy, data)
+				return
+			}
+
+			data, debug, err := client.GetPolicyWithDebug(system, subjectType, subjectID, action, force)
 			if err != nil {
-				logger.Error("query action fail!", err.Error())
+				logger.Error("query policy fail! %s", err.Error())
 				return
 			}
-			logger.PrettyJson(data)
+			printPolicyWithDebug(data, debug)
 		default:
 			logger.Error("not support yet")
 		}
 	},
 }
 
+// printPolicyWithDebug prints the expression and the debug trace, or both in one object if -o specified
+func printPolicyWithDebug(data json.RawMessage, debug *model.PolicyDebug) {
+	var expr interface{}
+	if err := json.Unmarshal(data, &expr); err != nil {
+		logger.Error("invalid policy! %s", err.Error())
+		return
+	}
+
+	if output != "" {
+		printResult(printer.KindUnknown, map[string]interface{}{
+			"expression": expr,
+			"debug":      debug,
+		})
+		return
+	}
+
+	printResult(printer.KindPolicy, expr)
+	fmt.Print(formatDebugTrace(debug))
+}
+
 func init() {
+	addForceFlag(queryCmd)
+	addDebugFlags(queryCmd)
+
 	rootCmd.AddCommand(queryCmd)
 }
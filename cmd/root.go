@@ -14,13 +14,72 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/gookit/color"
 	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/printer"
 )
 
-var cfgFile string
+var (
+	cfgFile     string
+	contextName string
+	output      string
+
+	// the dirs of the request/response recording, see transport.Record
+	recordDir string
+	replayDir string
+
+	// print the curl command of the requests, see transport.Curl
+	curlEnabled bool
+	dryRun      bool
+	showSecret  bool
+
+	// contextNames is all the values of --context, only the diff commands accept two contexts
+	contextNames []string
+
+	// exitCode is set by the commands which report the result via the exit code, e.g. expiry for nightly check
+	exitCode int
+)
+
+// contextValue is the value of --context, the last one is used if repeated, like a string flag
+type contextValue struct{}
+
+func (contextValue) String() string {
+	return contextName
+}
+
+func (contextValue) Set(s string) error {
+	contextName = s
+	contextNames = append(contextNames, s)
+	return nil
+}
+
+func (contextValue) Type() string {
+	return "string"
+}
+
+// Replace implements pflag.SliceValue, used to reset the flag in shell
+func (contextValue) Replace(s []string) error {
+	contextNames = s
+	contextName = ""
+	if len(s) > 0 {
+		contextName = s[len(s)-1]
+	}
+	return nil
+}
+
+func (contextValue) Append(s string) error {
+	return contextValue{}.Set(s)
+}
+
+func (contextValue) GetSlice() []string {
+	return contextNames
+}
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -29,6 +88,15 @@ var rootCmd = &cobra.Command{
 	Long: `A command tool for IAM debug.
 You can use it to query the system model, policy data, user data, cache as so on.
 `,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		exitCode = 0
+		// validate the output format before doing any request
+		_, err := printer.New(output)
+		return err
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		dryRunOut.finish()
+	},
 }
 
 func Execute() {
@@ -36,15 +104,67 @@ func Execute() {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// setExitCode sets the exit code of the command, the larger one is kept
+func setExitCode(code int) {
+	if code > exitCode {
+		exitCode = code
+	}
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
+	cobra.OnInitialize(initConfig, initColor)
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.bk-iam-cli.yaml)")
+	rootCmd.PersistentFlags().Var(contextValue{}, "context",
+		"the context(IAM environment) to use (default is the current context set by 'context use')")
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "",
+		"output format, one of "+printer.SupportedFormats+" (default is colorized json)")
+	rootCmd.PersistentFlags().StringVar(&recordDir, "record", "",
+		"save the request/response pairs into the dir, the secrets are redacted")
+	rootCmd.PersistentFlags().StringVar(&replayDir, "replay", "",
+		"serve the responses from the dir saved by --record, no request is sent")
+	rootCmd.PersistentFlags().BoolVar(&curlEnabled, "curl", false,
+		"print the curl command of each request to stderr, the secrets are masked unless --show-secret")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false,
+		fmt.Sprintf("print the curl command of the request to stdout instead of sending it, exit code %d; "+
+			"the commands of multiple requests show only the first one, e.g. policy list", dryRunExitNotSent))
+	rootCmd.PersistentFlags().BoolVar(&showSecret, "show-secret", false,
+		"show the app_secret in the curl command of --curl/--dry-run and 'config view/get' instead of masking it")
+	addConfigFlags(rootCmd)
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
+// initColor disables the color if stdout is not a terminal, e.g. piped to a file or another command
+func initColor() {
+	if !printer.IsTerminal(os.Stdout) {
+		color.Disable()
+	}
+}
+
+// printResult prints the data in the format specified by -o/--output
+func printResult(kind printer.Kind, data interface{}) {
+	printResultAs(output, kind, data)
+}
+
+// printResultAs prints the data in the format, e.g. the report printed as table by default
+func printResultAs(format string, kind printer.Kind, data interface{}) {
+	p, err := printer.New(format)
+	if err != nil {
+		logger.Error(err.Error())
+		return
+	}
+
+	err = p.Print(os.Stdout, kind, data)
+	if err != nil {
+		logger.Error("print fail! %s", err.Error())
+	}
+}
+
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
 	if cfgFile != "" {
@@ -63,10 +183,17 @@ func initConfig() {
 		viper.SetConfigName(".bk-iam-cli")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	// the env BK_IAM_{KEY} are bound in addConfigFlags
+
+	// NOTE: only yaml(json is valid yaml) is supported, the same as `config set`
+	viper.SetConfigType("yaml")
 
 	// If a config file is found, read it in.
+	// NOTE: the stdout is for the output of commands only
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		logger.Debug("Using config file: %s", viper.ConfigFileUsed())
+	} else {
+		// clear the config read before, e.g. the file removed in shell
+		_ = viper.ReadConfig(strings.NewReader(""))
 	}
 }
@@ -16,9 +16,8 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"bk-iam-cli/pkg/client"
 	"bk-iam-cli/pkg/logger"
-	"bk-iam-cli/pkg/storage"
+	"bk-iam-cli/pkg/printer"
 )
 
 var saasDebugCmd = &cobra.Command{
@@ -46,15 +45,12 @@ saas debug get {request_id/task_id}
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		credential := storage.NewCredential(saasCredentialFile)
-		host, appCode, appSecret, err := credential.Read()
+		client, _, err := newSaaSClient()
 		if err != nil {
 			logger.Error(err.Error())
 			return
 		}
 
-		client := client.NewIAMSaaSClient(host, appCode, appSecret)
-
 		switch args[0] {
 		case "list":
 			data, err := client.ListDebug(args[1])
@@ -66,7 +62,7 @@ saas debug get {request_id/task_id}
 			if len(data) == 0 {
 				logger.Info("no debug list found!")
 			} else {
-				logger.PrettyJson(data)
+				printResult(printer.KindDebugList, data)
 			}
 		case "get":
 			data, err := client.GetDebug(args[1])
@@ -74,7 +70,7 @@ saas debug get {request_id/task_id}
 				logger.Error("debug get fail!", err.Error())
 				return
 			}
-			logger.PrettyJson(data)
+			printResult(printer.KindDebug, data)
 		default:
 			logger.Error("not support yet")
 		}
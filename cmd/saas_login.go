@@ -28,28 +28,41 @@ var saasLoginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Login via app_code/app_secret of IAM SaaS",
 	Long: `Login via app_code/app_secret of IAM SaaS. 
-The login credentials will be encrypted and store at current dir.
-And you should login every 1 hour.
+The login credentials will be stored at the dir of current context(or the one specified by --context),
+the app_secret is protected by the backend of --credential-backend.
+The credential expires after --ttl(default 1h), and you should login again after that.
+The host and the tls settings are the same as 'login'.
 `,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 3 {
-			return errors.New("saas login http://{iam_saas_host} {app_code} {app_secret}")
+			return errors.New("saas login {iam_saas_host} {app_code} {app_secret}")
 		}
 		return nil
 	},
 	Run: func(cmd *cobra.Command, args []string) {
-		host := args[0]
 		appCode := args[1]
 		appSecret := args[2]
 
-		// 1. host is connectable : /ping
-		client := client.NewIAMSaaSClient(host, appCode, appSecret)
+		hosts, err := loginHosts(args[0])
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
 
-		err := client.Ping()
+		// 1. host is connectable : /ping
+		t, err := newTransport(storage.TLSConfig{})
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+		host, err := probeHost(hosts, func(host string) error {
+			return client.NewIAMSaaSClient(host, appCode, appSecret, client.WithSaaSTransport(t)).Ping()
+		})
 		if err != nil {
-			logger.Error("connect to host %s fail! %s\n", host, err.Error())
+			logger.Error("connect to host %s fail! %s\n", args[0], err.Error())
 			return
 		}
+		client := client.NewIAMSaaSClient(host, appCode, appSecret, client.WithSaaSTransport(t))
 
 		// 2. the app_code/app_secret is valid: /api/v1/web/systems
 		day := time.Now().Format("20210101")
@@ -60,7 +73,16 @@ And you should login every 1 hour.
 		}
 
 		// 3. create the credential
-		credential := storage.NewCredential(saasCredentialFile)
+		c, err := loginContext()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+		credential, err := newLoginCredential(cmd, c.Path(saasCredentialFile), host, t.Config())
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
 		err = credential.Write(host, appCode, appSecret)
 		if err != nil {
 			logger.Error(err.Error())
@@ -74,4 +96,6 @@ And you should login every 1 hour.
 
 func init() {
 	saasCmd.AddCommand(saasLoginCmd)
+	addCredentialBackendFlag(saasLoginCmd)
+	addCredentialTTLFlag(saasLoginCmd)
 }
@@ -14,9 +14,7 @@ package cmd
 import (
 	"github.com/spf13/cobra"
 
-	"bk-iam-cli/pkg/client"
 	"bk-iam-cli/pkg/logger"
-	"bk-iam-cli/pkg/storage"
 )
 
 var saasPingCmd = &cobra.Command{
@@ -25,15 +23,12 @@ var saasPingCmd = &cobra.Command{
 	Long:  `call /ping to check if the iam SaaS service is alive.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
-		credential := storage.NewCredential(saasCredentialFile)
-		host, appCode, appSecret, err := credential.Read()
+		client, host, err := newSaaSClient()
 		if err != nil {
 			logger.Error(err.Error())
 			return
 		}
 
-		client := client.NewIAMSaaSClient(host, appCode, appSecret)
-
 		err = client.Ping()
 		if err != nil {
 			logger.Error("connect to host %s fail! %s", host, err.Error())
@@ -0,0 +1,402 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/storage"
+)
+
+// the commands change the session state, the cached clients and completions should be reset after them
+var sessionStateCommands = []string{"login", "logout", "use", "context", "saas login"}
+
+// shellCmd represents the shell command
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Open an interactive shell",
+	Long: `Open an interactive shell, all the commands can be run without the iam-cli prefix, e.g.
+iam-cli [default] http://iam.example.com (bk_paas)> query policy user tom project_view
+
+The clients are cached between commands in the session, press tab to complete the commands/systems/actions,
+the history is stored in $HOME/.bk-iam-cli/history. Type exit or quit (or Ctrl-D) to exit.
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		historyFile, err := storage.ShellHistoryFile()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		s := &shell{
+			actions: map[string][]string{},
+		}
+
+		rl, err := readline.NewEx(&readline.Config{
+			Prompt:          s.prompt(),
+			HistoryFile:     historyFile,
+			AutoComplete:    s,
+			InterruptPrompt: "^C",
+			EOFPrompt:       "exit",
+		})
+		if err != nil {
+			logger.Error("open shell fail! %s", err.Error())
+			return
+		}
+		defer rl.Close()
+
+		enableSessionClientCache()
+
+		for {
+			line, err := rl.Readline()
+			if errors.Is(err, readline.ErrInterrupt) {
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			args, err := splitArgs(line)
+			if err != nil {
+				logger.Error(err.Error())
+				continue
+			}
+			if len(args) == 0 {
+				continue
+			}
+
+			switch args[0] {
+			case "exit", "quit":
+				return
+			case "shell":
+				logger.Warn("already in shell")
+				continue
+			case "iam-cli":
+				args = args[1:]
+			}
+
+			s.execute(args)
+			rl.SetPrompt(s.prompt())
+		}
+	},
+}
+
+type shell struct {
+	// the completions fetched from the backend, reset after the session state changed
+	systems []string
+	// key is the system
+	actions map[string][]string
+}
+
+func (s *shell) prompt() string {
+	name := "-"
+	if c, err := activeContext(); err == nil {
+		name = c.Name
+	}
+
+	host := "(not login)"
+	if h := viper.GetString(configKeyHost); h != "" {
+		host = h
+	} else if credential, err := backendCredential(); err == nil {
+		if h, _, _, err := credential.ReadInfo(); err == nil {
+			host = h
+		}
+	}
+
+	system, err := readUseSystem()
+	if err != nil {
+		system = "-"
+	}
+
+	return fmt.Sprintf("iam-cli [%s] %s (%s)> ", name, host, system)
+}
+
+func (s *shell) execute(args []string) {
+	resetFlags(rootCmd)
+
+	rootCmd.SetArgs(args)
+	// NOTE: the error has been printed by cobra
+	_ = rootCmd.Execute()
+
+	line := strings.Join(args, " ")
+	for _, c := range sessionStateCommands {
+		if line == c || strings.HasPrefix(line, c+" ") {
+			resetSessionClientCache()
+			s.systems = nil
+			s.actions = map[string][]string{}
+			return
+		}
+	}
+}
+
+// resetFlags resets all the flags to the default value, the flags are kept between the commands in shell
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			_ = sv.Replace([]string{})
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+// Do implements readline.AutoCompleter
+func (s *shell) Do(line []rune, pos int) (newLine [][]rune, length int) {
+	text := string(line[:pos])
+	words := strings.Fields(text)
+
+	current := ""
+	if len(words) > 0 && !strings.HasSuffix(text, " ") {
+		current = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	candidates := s.candidates(words, current)
+	sort.Strings(candidates)
+
+	for _, c := range candidates {
+		if strings.HasPrefix(c, current) && c != current {
+			newLine = append(newLine, []rune(c[len(current):]+" "))
+		}
+	}
+	return newLine, len([]rune(current))
+}
+
+func (s *shell) candidates(words []string, current string) []string {
+	cmd, rest, err := rootCmd.Find(words)
+	if err != nil || cmd == nil {
+		cmd, rest = rootCmd, words
+	}
+
+	// the value of the flag
+	if len(rest) > 0 && strings.HasPrefix(rest[len(rest)-1], "-") && !strings.Contains(rest[len(rest)-1], "=") {
+		if f := lookupFlag(cmd, rest[len(rest)-1]); f != nil && f.Value.Type() != "bool" {
+			return s.flagValueCandidates(f.Name)
+		}
+	}
+
+	if strings.HasPrefix(current, "-") {
+		return flagCandidates(cmd)
+	}
+
+	args := positionalArgs(cmd, rest)
+
+	candidates := make([]string, 0)
+	if len(args) == 0 {
+		for _, c := range cmd.Commands() {
+			if c.IsAvailableCommand() {
+				candidates = append(candidates, c.Name())
+			}
+		}
+		if cmd == rootCmd {
+			candidates = append(candidates, "exit", "quit")
+		}
+	}
+
+	return append(candidates, s.argCandidates(cmd, args)...)
+}
+
+// argCandidates returns the candidates of the positional argument
+func (s *shell) argCandidates(cmd *cobra.Command, args []string) []string {
+	path := strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()+" ")
+	n := len(args)
+
+	switch {
+	case n == 0 && path == "use":
+		return s.listSystems()
+	case n == 0 && path == "query":
+		return []string{"model", "action", "subject", "policy"}
+	case n == 0 && path == "cache":
+		return []string{"policy", "expression"}
+	case n == 0 && path == "saas debug":
+		return []string{"list", "get"}
+	case n == 0 && (path == "context use" || path == "context delete" || path == "context rename"):
+		names, _ := storage.ListContexts()
+		return names
+	case n == 0 && path == "check",
+		n == 1 && path == "query" && (args[0] == "subject" || args[0] == "policy"),
+		n == 1 && path == "cache" && args[0] == "policy":
+		return []string{"user", "group"}
+	case n == 2 && path == "check",
+		n == 3 && path == "query" && args[0] == "policy",
+		n == 3 && path == "cache" && args[0] == "policy":
+		return s.listActions()
+	}
+	return nil
+}
+
+func (s *shell) flagValueCandidates(name string) []string {
+	switch name {
+	case "action":
+		return s.listActions()
+	case "context":
+		names, _ := storage.ListContexts()
+		return names
+	case "output":
+		return []string{"json", "yaml", "table", "jsonpath=", "go-template="}
+	}
+	return nil
+}
+
+func (s *shell) listSystems() []string {
+	if s.systems != nil {
+		return s.systems
+	}
+
+	client, _, err := newBackendClient()
+	if err != nil {
+		return nil
+	}
+	systems, err := client.GetSystems()
+	if err != nil {
+		return nil
+	}
+
+	s.systems = make([]string, 0, len(systems))
+	for _, system := range systems {
+		s.systems = append(s.systems, system.ID)
+	}
+	return s.systems
+}
+
+func (s *shell) listActions() []string {
+	client, system, err := newSystemBackendClient()
+	if err != nil {
+		return nil
+	}
+	if actions, ok := s.actions[system]; ok {
+		return actions
+	}
+
+	actions, err := client.GetActions(system)
+	if err != nil {
+		return nil
+	}
+
+	s.actions[system] = actions.IDs()
+	return s.actions[system]
+}
+
+func lookupFlag(cmd *cobra.Command, arg string) *pflag.Flag {
+	if strings.HasPrefix(arg, "--") {
+		return cmd.Flags().Lookup(strings.TrimPrefix(arg, "--"))
+	}
+	if len(arg) == 2 {
+		return cmd.Flags().ShorthandLookup(arg[1:])
+	}
+	return nil
+}
+
+func flagCandidates(cmd *cobra.Command) []string {
+	// NOTE: the inherited flags may have been merged into cmd.Flags() already
+	seen := map[string]struct{}{}
+	candidates := make([]string, 0)
+	add := func(f *pflag.Flag) {
+		if _, ok := seen[f.Name]; ok || f.Hidden {
+			return
+		}
+		seen[f.Name] = struct{}{}
+		candidates = append(candidates, "--"+f.Name)
+	}
+	cmd.Flags().VisitAll(add)
+	cmd.InheritedFlags().VisitAll(add)
+	return candidates
+}
+
+// positionalArgs removes the flags and the values of the flags from the args
+func positionalArgs(cmd *cobra.Command, args []string) []string {
+	positional := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			positional = append(positional, arg)
+			continue
+		}
+		if strings.Contains(arg, "=") {
+			continue
+		}
+		if f := lookupFlag(cmd, arg); f != nil && f.Value.Type() != "bool" {
+			i++
+		}
+	}
+	return positional
+}
+
+// splitArgs splits the line into args like shell, supports single/double quotes and backslash escape
+func splitArgs(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		quote   rune
+		escaped bool
+		inArg   bool
+	)
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed quote %c", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+}
@@ -0,0 +1,184 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/model"
+	"bk-iam-cli/pkg/printer"
+	"bk-iam-cli/pkg/util"
+)
+
+// the keys of the errs in the response of query subject, the error is displayed on the branch
+const (
+	subjectErrDepartments      = "departments"
+	subjectErrGroups           = "groups"
+	subjectErrDepartmentGroups = "department_groups"
+)
+
+// treeCmd represents the tree command
+var treeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Show the relations as a tree",
+	Long:  `Show the relations as a tree, e.g. the departments and groups of the subject`,
+}
+
+var treeSubjectCmd = &cobra.Command{
+	Use:   "subject [subject_type] [subject_id]",
+	Short: "Show the departments and groups of the subject as a tree",
+	Long: `Show the departments and groups of the subject as a tree,
+user -> departments -> department groups, and user -> groups(joined directly).
+The expired memberships are marked [EXPIRED] and highlighted in red,
+the ones expire within --soon are marked [EXPIRING {remaining}] and highlighted in yellow,
+and the errors of the query are displayed on the failing branch.
+
+tree subject user tom
+tree subject user tom --soon 7d
+tree subject user tom --format dot | dot -Tpng -o tom.png
+tree subject user tom --format mermaid
+`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return errors.New("tree subject {subject_type} {subject_id}")
+		}
+		if args[0] != "user" && args[0] != "group" {
+			return errors.New("subject_type should be user or group")
+		}
+		if _, err := strconv.Atoi(args[1]); err != nil && args[0] == "group" {
+			return errors.New("subject_id should be an integer")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		soonFlag, _ := cmd.Flags().GetString("soon")
+
+		soon, err := util.ParseDuration(soonFlag)
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		client, _, err := newBackendClient()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		subject, err := client.GetSubject(args[0], args[1])
+		if err != nil {
+			logger.Error("query subject fail! %s", err.Error())
+			return
+		}
+
+		root := buildSubjectTree(subject, time.Now(), soon)
+		if output != "" {
+			printResult(printer.KindUnknown, root)
+			return
+		}
+		if err = printer.PrintTree(os.Stdout, root, format, printer.IsTerminal(os.Stdout)); err != nil {
+			logger.Error(err.Error())
+		}
+	},
+}
+
+// buildSubjectTree builds the tree of the subject,
+// the membership expires within soon is marked as warning,
+// the markers are in the label too, the color is gone if the output is not a terminal
+func buildSubjectTree(d *model.SubjectDetail, now time.Time, soon time.Duration) *printer.TreeNode {
+	root := &printer.TreeNode{Label: fmt.Sprintf("%s (pk=%d)", d.Subject, d.Subject.PK)}
+
+	errs := map[string]string{}
+	for key, v := range d.Errs {
+		if msg := toText(v); v != nil && msg != "" {
+			errs[key] = msg
+		}
+	}
+	addErr := func(n *printer.TreeNode, key string) {
+		if msg, ok := errs[key]; ok {
+			n.Add(fmt.Sprintf("error(%s): %s", key, msg), printer.TreeStatusError)
+			delete(errs, key)
+		}
+	}
+
+	departments := root.Add(fmt.Sprintf("departments (%d)", len(d.Departments)), printer.TreeStatusNormal)
+	addErr(departments, subjectErrDepartments)
+	addErr(departments, subjectErrDepartmentGroups)
+	for _, dept := range d.Departments {
+		label := dept.Subject.String()
+		if dept.Name != "" {
+			label += " " + dept.Name
+		}
+		node := departments.Add(fmt.Sprintf("%s (pk=%d)", label, dept.PK), printer.TreeStatusNormal)
+		addGroupNodes(node, dept.Groups, now, soon)
+	}
+
+	groups := root.Add(fmt.Sprintf("groups (%d)", len(d.Groups)), printer.TreeStatusNormal)
+	addErr(groups, subjectErrGroups)
+	addGroupNodes(groups, d.Groups, now, soon)
+
+	// the other errors, e.g. query the subject fail
+	keys := make([]string, 0, len(errs))
+	for key := range errs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		addErr(root, key)
+	}
+	return root
+}
+
+func addGroupNodes(parent *printer.TreeNode, groups []model.SubjectGroup, now time.Time, soon time.Duration) {
+	for _, g := range groups {
+		label, status := fmt.Sprintf("group pk=%d, permanent", g.PK), printer.TreeStatusNormal
+		switch {
+		case g.IsPermanent():
+		case g.IsExpired(now):
+			label = fmt.Sprintf("group pk=%d, expired at %s [EXPIRED]", g.PK, printer.FormatTimestamp(g.PolicyExpiredAt))
+			status = printer.TreeStatusExpired
+		default:
+			label = fmt.Sprintf("group pk=%d, expires at %s", g.PK, printer.FormatTimestamp(g.PolicyExpiredAt))
+			if g.ExpiredAt().Before(now.Add(soon)) {
+				label += fmt.Sprintf(" [EXPIRING %s]", formatRemaining(g.ExpiredAt().Sub(now)))
+				status = printer.TreeStatusWarning
+			}
+		}
+		parent.Add(label, status)
+	}
+}
+
+// formatRemaining formats the duration in days, e.g. 5d3h, or 2h30m0s if less than one day
+func formatRemaining(d time.Duration) string {
+	if d < 24*time.Hour {
+		return d.Truncate(time.Minute).String()
+	}
+	days := int(d / (24 * time.Hour))
+	return fmt.Sprintf("%dd%dh", days, int((d-time.Duration(days)*24*time.Hour)/time.Hour))
+}
+
+func init() {
+	treeSubjectCmd.Flags().String("format", printer.TreeFormatASCII, "the tree format, ascii, dot or mermaid")
+	treeSubjectCmd.Flags().String("soon", "30d", "highlight the memberships expire within the duration, e.g. 7d, 12h")
+
+	treeCmd.AddCommand(treeSubjectCmd)
+	rootCmd.AddCommand(treeCmd)
+}
@@ -30,7 +30,13 @@ After that, the all query commands will query the data of system bk_paas
 		// 切换到哪个系统, 例如use bk_paas, 当前session中system切换到bk_paas
 		system := args[0]
 
-		err := storage.WriteUseSystem(system)
+		c, err := activeContext()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		err = storage.WriteUseSystem(c.Path(useSystemFile), system)
 		if err != nil {
 			logger.Error("Use system fail: %s", err.Error())
 			return
@@ -14,9 +14,8 @@ package cmd
 import (
 	"github.com/spf13/cobra"
 
-	"bk-iam-cli/pkg/client"
 	"bk-iam-cli/pkg/logger"
-	"bk-iam-cli/pkg/storage"
+	"bk-iam-cli/pkg/printer"
 )
 
 // versionCmd represents the version command
@@ -25,15 +24,12 @@ var versionCmd = &cobra.Command{
 	Short: "call /version to check the version of iam backend",
 	Long:  `call /version to check the version of iam backend`,
 	Run: func(cmd *cobra.Command, args []string) {
-		credential := storage.NewCredential(backendCredentialFile)
-		host, appCode, appSecret, err := credential.Read()
+		client, host, err := newBackendClient()
 		if err != nil {
 			logger.Error(err.Error())
 			return
 		}
 
-		client := client.NewIAMBackendClient(host, "", appCode, appSecret)
-
 		version, err := client.Version()
 		if err != nil {
 			logger.Error("version check fail: %s", host, err.Error())
@@ -41,7 +37,7 @@ var versionCmd = &cobra.Command{
 		}
 
 		logger.Info("success")
-		logger.PrettyJson(version)
+		printResult(printer.KindVersion, version)
 	},
 }
 
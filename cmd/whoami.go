@@ -0,0 +1,158 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/printer"
+	"bk-iam-cli/pkg/storage"
+)
+
+// the status of the login
+const (
+	loginStatusOK          = "ok"
+	loginStatusNotLogin    = "not login"
+	loginStatusExpired     = "expired"
+	loginStatusUnreachable = "unreachable"
+
+	credentialSourceCredential = "credential"
+	credentialSourceConfig     = "config"
+)
+
+type loginStatus struct {
+	Name      string `json:"name"`
+	Host      string `json:"host"`
+	AppCode   string `json:"app_code"`
+	System    string `json:"system,omitempty"`
+	ExpiredAt int64  `json:"expired_at,omitempty"`
+	Remaining string `json:"remaining,omitempty"`
+	// credential or config(flags/env/config file)
+	Source string `json:"source"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type whoamiResult struct {
+	Context     string        `json:"context"`
+	Credentials []loginStatus `json:"credentials"`
+}
+
+// whoamiCmd represents the whoami command
+var whoamiCmd = &cobra.Command{
+	Use:     "whoami",
+	Aliases: []string{"status"},
+	Short:   "Show the login status of IAM and IAM SaaS",
+	Long: `Show the login status of IAM and IAM SaaS in current context(or the one specified by --context):
+the host, app_code, selected system and the remaining validity of the credentials,
+and call /ping of both to check the connectivity.
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := activeContext()
+		if err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		backendCred := storage.NewCredential(c.Path(backendCredentialFile))
+		backend := credentialStatus("backend", configKeyHost, backendCred, func() error {
+			client, _, err := newBackendClient()
+			if err != nil {
+				return err
+			}
+			return client.Ping()
+		})
+		backend.System, _ = readUseSystem()
+
+		saasCred := storage.NewCredential(c.Path(saasCredentialFile))
+		saas := credentialStatus("saas", configKeySaaSHost, saasCred, func() error {
+			client, _, err := newSaaSClient()
+			if err != nil {
+				return err
+			}
+			return client.Ping()
+		})
+
+		printResult(printer.KindStatus, whoamiResult{
+			Context:     c.Name,
+			Credentials: []loginStatus{backend, saas},
+		})
+	},
+}
+
+// credentialStatus returns the status of the credential, ping is only called if the credential is valid;
+// the host/app_code are resolved by resolveAuth, the same as the ones used by the other commands
+func credentialStatus(name, hostKey string, credential *storage.Credential, ping func() error) loginStatus {
+	s := loginStatus{Name: name, Source: credentialSourceCredential}
+	configured := configuredAuth(hostKey)
+	if configured.complete() {
+		s.Source = credentialSourceConfig
+	}
+
+	auth, err := resolveAuth(hostKey, func() (*storage.Credential, error) { return credential, nil })
+	s.Host, s.AppCode = auth.host, auth.appCode
+	if err == nil && s.Source == credentialSourceConfig {
+		return s.ping(ping)
+	}
+	// the credential is not used if the host is overridden
+	if err != nil && configured.host != "" {
+		return s.notLogin(err)
+	}
+
+	host, appCode, expiredAt, infoErr := credential.ReadInfo()
+	if infoErr != nil {
+		return s.notLogin(infoErr)
+	}
+	// the expired credential fails to resolve, show the stored ones
+	if s.Host == "" {
+		s.Host = host
+	}
+	if s.AppCode == "" {
+		s.AppCode = appCode
+	}
+	s.ExpiredAt = expiredAt
+
+	remaining := time.Until(time.Unix(s.ExpiredAt, 0)).Truncate(time.Second)
+	if remaining <= 0 {
+		s.Remaining = "0s"
+		s.Status = loginStatusExpired
+		return s
+	}
+	if err != nil {
+		return s.notLogin(err)
+	}
+	s.Remaining = remaining.String()
+	return s.ping(ping)
+}
+
+func (s loginStatus) notLogin(err error) loginStatus {
+	s.Status = loginStatusNotLogin
+	s.Error = err.Error()
+	return s
+}
+
+func (s loginStatus) ping(ping func() error) loginStatus {
+	s.Status = loginStatusOK
+	if err := ping(); err != nil {
+		s.Status = loginStatusUnreachable
+		s.Error = err.Error()
+	}
+	return s
+}
+
+func init() {
+	rootCmd.AddCommand(whoamiCmd)
+}
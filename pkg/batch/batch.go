@@ -0,0 +1,220 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Row is one request of the batch, e.g. `check,user,tom,project_view,project:42`
+type Row struct {
+	// the line number in the file, start from 1
+	Line        int      `json:"-"`
+	Command     string   `json:"command"`
+	SubjectType string   `json:"subject_type"`
+	SubjectID   string   `json:"subject_id"`
+	Action      string   `json:"action"`
+	Resources   []string `json:"resources,omitempty"`
+
+	// the row is invalid, will be reported as a failure without executing
+	Err error `json:"-"`
+}
+
+// ReadRows reads the rows from csv or jsonl, the format is detected by the first non-blank char(`{` for jsonl).
+// csv: command,subject_type,subject_id,action[,resource...], the header and the `#` comments are skipped
+// jsonl: {"command": "check", "subject_type": "user", "subject_id": "tom", "action": "project_view",
+// "resources": ["project:42"]}
+// the malformed row does not fail the whole reading, the error is kept in Row.Err
+func ReadRows(r io.Reader) ([]Row, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, errors.New("no rows")
+	}
+	if trimmed[0] == '{' {
+		return readJSONLRows(data)
+	}
+	return readCSVRows(data)
+}
+
+func readJSONLRows(data []byte) ([]Row, error) {
+	var rows []Row
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		row := Row{}
+		if err := json.Unmarshal([]byte(text), &row); err != nil {
+			row.Err = fmt.Errorf("invalid json, %w", err)
+		}
+		row.Line = line
+		rows = append(rows, row.validate())
+	}
+	return rows, scanner.Err()
+}
+
+func readCSVRows(data []byte) ([]Row, error) {
+	var rows []Row
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			rows = append(rows, Row{Line: parseErr.StartLine, Err: fmt.Errorf("invalid csv, %w", err)})
+			continue
+		}
+		// the header
+		if len(rows) == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "command") {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := Row{Line: line}
+		fields := []*string{&row.Command, &row.SubjectType, &row.SubjectID, &row.Action}
+		for i, f := range fields {
+			if i < len(record) {
+				*f = strings.TrimSpace(record[i])
+			}
+		}
+		for _, r := range record[min(len(record), len(fields)):] {
+			if r = strings.TrimSpace(r); r != "" {
+				row.Resources = append(row.Resources, r)
+			}
+		}
+		rows = append(rows, row.validate())
+	}
+	return rows, nil
+}
+
+func (r Row) validate() Row {
+	if r.Err != nil {
+		return r
+	}
+	if r.Command == "" || r.SubjectType == "" || r.SubjectID == "" {
+		r.Err = errors.New("command, subject_type and subject_id are required")
+	}
+	return r
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Result is the result of one row
+type Result struct {
+	Row      Row
+	Data     interface{}
+	Err      error
+	Duration time.Duration
+}
+
+// Runner executes the rows by a bounded worker pool
+type Runner struct {
+	// the number of workers, 1 if not positive
+	Concurrency int
+	// the max rows per second of all workers, no limit if not positive;
+	// NOTE: a row may send more than one request, e.g. check may query the subject after the policy
+	Rate float64
+}
+
+// Run executes fn for each row, the failure of a row does not abort the others,
+// the results are in the same order as the rows
+func (r Runner) Run(rows []Row, fn func(row Row) (interface{}, error)) []Result {
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var limit <-chan time.Time
+	if r.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / r.Rate))
+		defer ticker.Stop()
+		limit = ticker.C
+	}
+
+	results := make([]Result, len(rows))
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				results[idx] = r.execute(rows[idx], limit, fn)
+			}
+		}()
+	}
+
+	for idx := range rows {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+func (r Runner) execute(row Row, limit <-chan time.Time, fn func(row Row) (interface{}, error)) (result Result) {
+	result.Row = row
+	if row.Err != nil {
+		result.Err = row.Err
+		return
+	}
+	if limit != nil {
+		<-limit
+	}
+
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+		// one panic row should not break the batch
+		if p := recover(); p != nil {
+			result.Err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	result.Data, result.Err = fn(row)
+	return
+}
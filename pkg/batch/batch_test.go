@@ -0,0 +1,138 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package batch
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadRows(t *testing.T) {
+	csvRows, err := ReadRows(strings.NewReader(`command,subject_type,subject_id,action,resource
+# skipped
+check,user,tom,project_view,project:42, biz:1
+policy,user
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(csvRows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(csvRows))
+	}
+	row := csvRows[0]
+	if row.Line != 3 || row.Command != "check" || row.SubjectID != "tom" ||
+		strings.Join(row.Resources, " ") != "project:42 biz:1" || row.Err != nil {
+		t.Errorf("unexpected row %+v", row)
+	}
+	if csvRows[1].Err == nil {
+		t.Errorf("row without subject_id should be invalid")
+	}
+
+	jsonRows, err := ReadRows(strings.NewReader(`
+{"command": "policy", "subject_type": "user", "subject_id": "tom", "action": "project_view"}
+{"command": "policy",
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jsonRows) != 2 || jsonRows[0].Line != 2 || jsonRows[0].Action != "project_view" ||
+		jsonRows[1].Err == nil {
+		t.Errorf("unexpected rows %+v", jsonRows)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	rows := make([]Row, 20)
+	for i := range rows {
+		rows[i] = Row{Line: i + 1, Command: "policy", SubjectType: "user", SubjectID: "tom"}
+	}
+	rows[3].Err = errors.New("invalid row")
+
+	var running, maxRunning int32
+	results := Runner{Concurrency: 3}.Run(rows, func(row Row) (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+
+		switch row.Line {
+		case 5:
+			return nil, errors.New("fail")
+		case 6:
+			panic("boom")
+		}
+		return row.Line, nil
+	})
+
+	if maxRunning > 3 {
+		t.Errorf("got %d concurrent workers, want at most 3", maxRunning)
+	}
+	for i, r := range results {
+		if r.Row.Line != i+1 {
+			t.Fatalf("results not in order, got line %d at %d", r.Row.Line, i)
+		}
+	}
+	s := Summarize(results)
+	if s.Total != 20 || s.Success != 17 || s.Failure != 3 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if results[5].Err == nil || !strings.Contains(results[5].Err.Error(), "boom") {
+		t.Errorf("the panic should be captured, got %v", results[5].Err)
+	}
+
+	// the rate limit
+	start := time.Now()
+	Runner{Concurrency: 4, Rate: 100}.Run(rows[:5], func(row Row) (interface{}, error) { return nil, nil })
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("5 rows at 100/s should take at least 40ms, got %s", elapsed)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	results := []Result{
+		{Row: Row{Line: 1, Command: "policy", SubjectType: "user", SubjectID: "tom", Action: "view"}, Data: "a.id eq 1"},
+		{Row: Row{Line: 2, Command: "check"}, Data: map[string]bool{"allowed": true}},
+		{Row: Row{Line: 3}, Err: errors.New("fail")},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Write(buf, FormatJSONL, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"result":"a.id eq 1"`) ||
+		!strings.Contains(lines[2], `"ok":false,"error":"fail"`) {
+		t.Errorf("unexpected jsonl %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := Write(buf, FormatCSV, results); err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || lines[2] != `2,check,,,,,true,"{""allowed"":true}",,0` {
+		t.Errorf("unexpected csv %s", buf.String())
+	}
+
+	if err := Write(buf, "xml", results); err == nil {
+		t.Error("xml should be unsupported")
+	}
+}
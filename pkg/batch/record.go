@@ -0,0 +1,150 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// the output formats
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+)
+
+// Record is the output of one row
+type Record struct {
+	Line        int         `json:"line"`
+	Command     string      `json:"command"`
+	SubjectType string      `json:"subject_type"`
+	SubjectID   string      `json:"subject_id"`
+	Action      string      `json:"action"`
+	Resources   []string    `json:"resources,omitempty"`
+	OK          bool        `json:"ok"`
+	Result      interface{} `json:"result,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	DurationMS  int64       `json:"duration_ms"`
+}
+
+// NewRecord converts the result to the record
+func NewRecord(r Result) Record {
+	record := Record{
+		Line:        r.Row.Line,
+		Command:     r.Row.Command,
+		SubjectType: r.Row.SubjectType,
+		SubjectID:   r.Row.SubjectID,
+		Action:      r.Row.Action,
+		Resources:   r.Row.Resources,
+		OK:          r.Err == nil,
+		Result:      r.Data,
+		DurationMS:  r.Duration.Milliseconds(),
+	}
+	if r.Err != nil {
+		record.Error = r.Err.Error()
+	}
+	return record
+}
+
+// Summary is the count of the successes and failures
+type Summary struct {
+	Total   int
+	Success int
+	Failure int
+}
+
+// Summarize counts the results
+func Summarize(results []Result) Summary {
+	s := Summary{Total: len(results)}
+	for _, r := range results {
+		if r.Err == nil {
+			s.Success++
+		} else {
+			s.Failure++
+		}
+	}
+	return s
+}
+
+// Write writes the results as jsonl or csv
+func Write(w io.Writer, format string, results []Result) error {
+	switch format {
+	case FormatJSONL, "":
+		return writeJSONL(w, results)
+	case FormatCSV:
+		return writeCSV(w, results)
+	default:
+		return fmt.Errorf("unsupported format `%s`, should be jsonl or csv", format)
+	}
+}
+
+func writeJSONL(w io.Writer, results []Result) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	for _, r := range results {
+		if err := encoder.Encode(NewRecord(r)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var csvHeader = []string{
+	"line", "command", "subject_type", "subject_id", "action", "resources", "ok", "result", "error", "duration_ms",
+}
+
+func writeCSV(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range results {
+		record := NewRecord(r)
+		err := writer.Write([]string{
+			strconv.Itoa(record.Line),
+			record.Command,
+			record.SubjectType,
+			record.SubjectID,
+			record.Action,
+			strings.Join(record.Resources, " "),
+			strconv.FormatBool(record.OK),
+			csvValue(record.Result),
+			record.Error,
+			strconv.FormatInt(record.DurationMS, 10),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvValue returns the string as it is, others as compact json
+func csvValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	default:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return fmt.Sprint(x)
+		}
+		return string(b)
+	}
+}
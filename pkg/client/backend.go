@@ -13,18 +13,16 @@ package client
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/TencentBlueKing/gopkg/conv"
-	"github.com/parnurzeal/gorequest"
 
-	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/client/transport"
+	"bk-iam-cli/pkg/expression"
+	"bk-iam-cli/pkg/model"
 	"bk-iam-cli/pkg/util"
 )
 
@@ -32,6 +30,9 @@ const (
 	bkIAMVersion = "1"
 )
 
+// the codes of the IAM response to retry, the backend is overloaded or unavailable for a while
+var retryCodes = []int{1901429, 1901503}
+
 type Method string
 
 var (
@@ -43,6 +44,8 @@ type IAMBackendResponse struct {
 	Code    int             `json:"code"`
 	Message string          `json:"message"`
 	Data    json.RawMessage `json:"data"`
+	// NOTE: only the debug api with debug=true will return the debug info
+	Debug json.RawMessage `json:"debug,omitempty"`
 }
 
 func (r *IAMBackendResponse) Error() error {
@@ -69,6 +72,9 @@ type IAMBackendClient interface {
 	QueryAction(system string) (map[string]interface{}, error)
 	QuerySubject(_type string, id string) (map[string]interface{}, error)
 	QueryPolicy(system, subjectType, subjectID, action string, force bool, debug bool) (map[string]interface{}, error)
+	QueryPolicyWithDebug(
+		system, subjectType, subjectID, action string, force bool,
+	) (data map[string]interface{}, debug map[string]interface{}, err error)
 
 	QueryCachePolicy(system, subjectType, subjectID, action string) (map[string]interface{}, error)
 	QueryCacheExpression(pks []int) (map[string]interface{}, error)
@@ -76,6 +82,23 @@ type IAMBackendClient interface {
 	PolicyGet(policyID int64) (data map[string]interface{}, err error)
 	PolicyList(body interface{}) (data map[string]interface{}, err error)
 	PolicySubjects(policyIDs []int64) (data []map[string]interface{}, err error)
+
+	// the typed version of the apis above
+
+	GetVersion() (*model.VersionInfo, error)
+	GetSystems() ([]model.System, error)
+	GetModel(system string) (*model.SystemModel, error)
+	GetActions(system string) (*model.ActionList, error)
+	GetSubject(_type string, id string) (*model.SubjectDetail, error)
+	GetPolicyExpression(system, subjectType, subjectID, action string, force bool) (*expression.Expression, error)
+	GetPolicyWithDebug(
+		system, subjectType, subjectID, action string, force bool,
+	) (json.RawMessage, *model.PolicyDebug, error)
+	GetCachePolicy(system, subjectType, subjectID, action string) (*model.CachePolicy, error)
+	GetCacheExpression(pks []int) (*model.CacheExpression, error)
+	GetSystemPolicy(policyID int64) (*model.Policy, error)
+	ListSystemPolicies(body interface{}) (*model.PolicyList, error)
+	GetSystemPolicySubjects(policyIDs []int64) ([]model.PolicySubject, error)
 }
 
 type iamBackendClient struct {
@@ -87,21 +110,76 @@ type iamBackendClient struct {
 
 	isApiDebugEnabled bool
 	isApiForceEnabled bool
+
+	transport *transport.Client
+	// the transport with the auth headers, for the apis except the public ones, e.g. /ping
+	authTransport *transport.Client
+}
+
+// Option is the option of the IAM backend client
+type Option func(c *iamBackendClient)
+
+// WithAPIDebug adds ?debug=true in url of all requests, for debug api/policy, show the details
+func WithAPIDebug(enabled bool) Option {
+	return func(c *iamBackendClient) {
+		c.isApiDebugEnabled = enabled
+	}
+}
+
+// WithAPIForce adds ?force=true in url of all requests, for api/policy run without cache(all data from database)
+func WithAPIForce(enabled bool) Option {
+	return func(c *iamBackendClient) {
+		c.isApiForceEnabled = enabled
+	}
+}
+
+// WithTransport sends the requests over the transport, e.g. with the proxy or ca file, default is transport.Default()
+func WithTransport(t *transport.Client) Option {
+	return func(c *iamBackendClient) {
+		c.transport = t
+	}
 }
 
-func NewIAMBackendClient(host string, system string, appCode string, appSecret string) IAMBackendClient {
-	return &iamBackendClient{
+func NewIAMBackendClient(
+	host string, system string, appCode string, appSecret string, opts ...Option,
+) IAMBackendClient {
+	c := &iamBackendClient{
 		Host: host,
 
 		System:    system,
 		appCode:   appCode,
 		appSecret: appSecret,
 
-		// will add ?debug=true in url, for debug api/policy, show the details
-		isApiDebugEnabled: os.Getenv("IAM_API_DEBUG") == "true" || os.Getenv("BKAPP_IAM_API_DEBUG") == "true",
-		// will add ?force=true in url, for api/policy run without cache(all data from database)
-		isApiForceEnabled: os.Getenv("IAM_API_FORCE") == "true" || os.Getenv("BKAPP_IAM_API_FORCE") == "true",
+		transport: transport.Default(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	query := map[string]string{}
+	if c.isApiDebugEnabled {
+		query["debug"] = "true"
 	}
+	if c.isApiForceEnabled {
+		query["force"] = "true"
+	}
+
+	// NOTE: the public apis(ping/healthz/version) are not retried, they report the current state
+	retry := c.transport.Config().RetryPolicy(retryCodes...)
+	c.authTransport = c.transport.With(
+		transport.Header(map[string]string{
+			"X-BK-APP-CODE":    c.appCode,
+			"X-BK-APP-SECRET":  c.appSecret,
+			"X-Bk-IAM-Version": bkIAMVersion,
+		}),
+		transport.Query(query),
+		transport.Logging(),
+		transport.Retry(retry),
+		transport.CircuitBreaker(transport.DefaultBreakerThreshold, transport.DefaultBreakerCooldown, retry.Failed),
+		transport.Metrics("IAMBackend", observeMetric),
+	)
+	c.transport = c.transport.With(transport.Metrics("IAMBackend", observeMetric))
+	return c
 }
 
 func (c *iamBackendClient) call(
@@ -111,66 +189,12 @@ func (c *iamBackendClient) call(
 	timeout int64,
 	responseData interface{},
 ) error {
-	callTimeout := time.Duration(timeout) * time.Second
-	if timeout == 0 {
-		callTimeout = defaultTimeout
-	}
-
-	headers := map[string]string{
-		"X-BK-APP-CODE":    c.appCode,
-		"X-BK-APP-SECRET":  c.appSecret,
-		"X-Bk-IAM-Version": bkIAMVersion,
-	}
-
-	url := fmt.Sprintf("%s%s", c.Host, path)
-	result := IAMBackendResponse{}
-	start := time.Now()
-	callbackFunc := NewMetricCallback("IAMBackend", start)
-
-	// request := gorequest.New().Timeout(callTimeout).Post(url).Type("json")
-	request := gorequest.New().Timeout(callTimeout).Type("json")
-	switch method {
-	case POST:
-		request = request.Post(url).Send(data)
-	case GET:
-		request = request.Get(url).Query(data)
-	}
-
-	if c.isApiDebugEnabled {
-		request.QueryData.Add("debug", "true")
-	}
-	if c.isApiForceEnabled {
-		request.QueryData.Add("force", "true")
-	}
-
-	// set headers
-	for key, value := range headers {
-		request.Header.Set(key, value)
-	}
-
-	// do request
-	resp, _, errs := request.
-		EndStruct(&result, callbackFunc)
-
-	duration := time.Since(start)
-
-	logFailHTTPRequest(request, resp, errs, &result)
-
-	logger.Debug("do http request: method=`%s`, url=`%s`, data=`%s`", method, url, data)
-	logger.Debug("http request result: %v", result.String())
-	logger.Debug("http request took %v ms", float64(duration/time.Millisecond))
-
-	if len(errs) != 0 {
-		return fmt.Errorf("gorequest errors=`%s`", errs)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("gorequest statusCode is %d not 200", resp.StatusCode)
-	}
-	if result.Code != 0 {
-		return errors.New(result.Message)
+	result, err := c.do(method, path, data, timeout)
+	if err != nil {
+		return err
 	}
 
-	err := json.Unmarshal(result.Data, responseData)
+	err = json.Unmarshal(result.Data, responseData)
 	if err != nil {
 		return fmt.Errorf("http request response body data not valid: %w, data=`%v`", err, result.Data)
 	}
@@ -178,6 +202,16 @@ func (c *iamBackendClient) call(
 	return nil
 }
 
+// do sends the request and returns the whole response, the code of response is checked
+func (c *iamBackendClient) do(
+	method Method,
+	path string,
+	data interface{},
+	timeout int64,
+) (*IAMBackendResponse, error) {
+	return call(c.authTransport, method, fmt.Sprintf("%s%s", c.Host, path), data, timeout)
+}
+
 func (c *iamBackendClient) callWithReturnMapData(
 	method Method,
 	path string,
@@ -207,85 +241,122 @@ func (c *iamBackendClient) callWithReturnSliceMapData(
 }
 
 func (c *iamBackendClient) Ping() (err error) {
-	url := fmt.Sprintf("%s%s", c.Host, "/ping")
-
-	resp, _, errs := gorequest.New().Timeout(5 * time.Second).Get(url).EndBytes()
-	if len(errs) != 0 {
-		return fmt.Errorf("ping fail! errs=%v", errs)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("ping fail! status_code=%d", resp.StatusCode)
+	if _, err = get(c.transport, c.Host+"/ping", 5*time.Second); err != nil {
+		return fmt.Errorf("ping fail! %w", err)
 	}
 	return nil
 }
 
 func (c *iamBackendClient) Healthz() (err error) {
-	url := fmt.Sprintf("%s%s", c.Host, "/healthz")
+	if _, err = get(c.transport, c.Host+"/healthz", 10*time.Second); err != nil {
+		return fmt.Errorf("healthz fail! %w", err)
+	}
+	return nil
+}
 
-	resp, body, errs := gorequest.New().Timeout(10 * time.Second).Get(url).End()
-	if len(errs) != 0 {
-		return fmt.Errorf("healthz fail! errs=%v", errs)
+func (c *iamBackendClient) version(v interface{}) error {
+	body, err := get(c.transport, c.Host+"/version", 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("version fail! %w", err)
 	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("healthz fail! status_code=%d, body=%s", resp.StatusCode, body)
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return fmt.Errorf("unmarshal version data fail! %w", err)
 	}
 	return nil
 }
 
 func (c *iamBackendClient) Version() (version map[string]interface{}, err error) {
-	url := fmt.Sprintf("%s%s", c.Host, "/version")
-
-	resp, body, errs := gorequest.New().Timeout(10 * time.Second).Get(url).EndBytes()
-	if len(errs) != 0 {
-		err = fmt.Errorf("version fail! errs=%v", errs)
-		return
-	}
-	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("version fail! status_code=%d, body=%s", resp.StatusCode, body)
-		return
-	}
+	err = c.version(&version)
+	return
+}
 
-	err = json.Unmarshal(body, &version)
+func (c *iamBackendClient) GetVersion() (*model.VersionInfo, error) {
+	var version model.VersionInfo
+	err := c.version(&version)
 	if err != nil {
-		err = fmt.Errorf("unmarshal version data fail! %w", err)
-		return
+		return nil, err
 	}
-	return
+	return &version, nil
 }
 
-func (c *iamBackendClient) QueryModel(system string) (map[string]interface{}, error) {
+func (c *iamBackendClient) queryModel(system string, v interface{}) error {
 	path := "/api/v1/debug/query/model"
 	body := map[string]interface{}{
 		"system": system,
 	}
-	data, err := c.callWithReturnMapData(GET, path, body, 10)
+	return c.call(GET, path, body, 10, v)
+}
+
+func (c *iamBackendClient) QueryModel(system string) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	err := c.queryModel(system, &data)
 	return data, err
 }
 
-func (c *iamBackendClient) QueryAction(system string) (map[string]interface{}, error) {
+func (c *iamBackendClient) GetModel(system string) (*model.SystemModel, error) {
+	var data model.SystemModel
+	err := c.queryModel(system, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (c *iamBackendClient) queryAction(system string, v interface{}) error {
 	path := "/api/v1/debug/query/action"
 	body := map[string]interface{}{
 		"system": system,
 	}
-	data, err := c.callWithReturnMapData(GET, path, body, 10)
+	return c.call(GET, path, body, 10, v)
+}
+
+func (c *iamBackendClient) QueryAction(system string) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	err := c.queryAction(system, &data)
 	return data, err
 }
 
-func (c *iamBackendClient) QuerySubject(_type string, id string) (map[string]interface{}, error) {
+func (c *iamBackendClient) GetActions(system string) (*model.ActionList, error) {
+	var data model.ActionList
+	err := c.queryAction(system, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (c *iamBackendClient) querySubject(_type string, id string, v interface{}) error {
 	path := "/api/v1/debug/query/subject"
 	body := map[string]interface{}{
 		"type": _type,
 		"id":   id,
 	}
-	data, err := c.callWithReturnMapData(GET, path, body, 10)
+	return c.call(GET, path, body, 10, v)
+}
+
+func (c *iamBackendClient) QuerySubject(_type string, id string) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	err := c.querySubject(_type, id, &data)
 	return data, err
 }
 
-func (c *iamBackendClient) QueryPolicy(
+func (c *iamBackendClient) GetSubject(_type string, id string) (*model.SubjectDetail, error) {
+	var data model.SubjectDetail
+	err := c.querySubject(_type, id, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (c *iamBackendClient) queryPolicy(
 	system, subjectType, subjectID, action string,
 	force bool,
 	debug bool,
-) (map[string]interface{}, error) {
+	v interface{},
+) error {
 	path := "/api/v1/debug/query/policy"
 	body := map[string]interface{}{
 		"system":       system,
@@ -300,13 +371,102 @@ func (c *iamBackendClient) QueryPolicy(
 		body["debug"] = true
 	}
 
-	data, err := c.callWithReturnMapData(GET, path, body, 20)
-	return data, err
+	return c.call(GET, path, body, 20, v)
 }
 
-func (c *iamBackendClient) QueryCachePolicy(
+func (c *iamBackendClient) QueryPolicy(
 	system, subjectType, subjectID, action string,
+	force bool,
+	debug bool,
 ) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	err := c.queryPolicy(system, subjectType, subjectID, action, force, debug, &data)
+	return data, err
+}
+
+// GetPolicyExpression queries the policy, returns the parsed expression, the empty OR means no permission
+func (c *iamBackendClient) GetPolicyExpression(
+	system, subjectType, subjectID, action string,
+	force bool,
+) (*expression.Expression, error) {
+	var data json.RawMessage
+	err := c.queryPolicy(system, subjectType, subjectID, action, force, false, &data)
+	if err != nil {
+		return nil, err
+	}
+	return expression.Parse(data)
+}
+
+func (c *iamBackendClient) queryPolicyWithDebug(
+	system, subjectType, subjectID, action string,
+	force bool,
+) (*IAMBackendResponse, error) {
+	path := "/api/v1/debug/query/policy"
+	body := map[string]interface{}{
+		"system":       system,
+		"subject_type": subjectType,
+		"subject_id":   subjectID,
+		"action":       action,
+		"debug":        true,
+	}
+	if force {
+		body["force"] = true
+	}
+
+	return c.do(GET, path, body, 20)
+}
+
+// QueryPolicyWithDebug queries the policy with debug=true, returns the expression and the debug info
+func (c *iamBackendClient) QueryPolicyWithDebug(
+	system, subjectType, subjectID, action string,
+	force bool,
+) (data map[string]interface{}, debug map[string]interface{}, err error) {
+	result, err := c.queryPolicyWithDebug(system, subjectType, subjectID, action, force)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(result.Data, &data)
+	if err != nil {
+		err = fmt.Errorf("http request response body data not valid: %w, data=`%v`", err, result.Data)
+		return
+	}
+
+	debug = map[string]interface{}{}
+	if len(result.Debug) > 0 {
+		err = json.Unmarshal(result.Debug, &debug)
+		if err != nil {
+			err = fmt.Errorf("http request response body debug not valid: %w, debug=`%v`", err, result.Debug)
+			return
+		}
+	}
+	return data, debug, nil
+}
+
+// GetPolicyWithDebug queries the policy with debug=true, returns the raw expression and the typed debug info
+func (c *iamBackendClient) GetPolicyWithDebug(
+	system, subjectType, subjectID, action string,
+	force bool,
+) (json.RawMessage, *model.PolicyDebug, error) {
+	result, err := c.queryPolicyWithDebug(system, subjectType, subjectID, action, force)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	debug := &model.PolicyDebug{}
+	if len(result.Debug) > 0 {
+		err = json.Unmarshal(result.Debug, debug)
+		if err != nil {
+			return nil, nil, fmt.Errorf("http request response body debug not valid: %w, debug=`%v`", err, result.Debug)
+		}
+	}
+	return result.Data, debug, nil
+}
+
+func (c *iamBackendClient) queryCachePolicy(
+	system, subjectType, subjectID, action string,
+	v interface{},
+) error {
 	// NOTE: action can be empty
 	path := "/api/v1/debug/cache/policy"
 	body := map[string]interface{}{
@@ -319,11 +479,29 @@ func (c *iamBackendClient) QueryCachePolicy(
 		body["action"] = action
 	}
 
-	data, err := c.callWithReturnMapData(GET, path, body, 10)
+	return c.call(GET, path, body, 10, v)
+}
+
+func (c *iamBackendClient) QueryCachePolicy(
+	system, subjectType, subjectID, action string,
+) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	err := c.queryCachePolicy(system, subjectType, subjectID, action, &data)
 	return data, err
 }
 
-func (c *iamBackendClient) QueryCacheExpression(pks []int) (map[string]interface{}, error) {
+func (c *iamBackendClient) GetCachePolicy(
+	system, subjectType, subjectID, action string,
+) (*model.CachePolicy, error) {
+	var data model.CachePolicy
+	err := c.queryCachePolicy(system, subjectType, subjectID, action, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (c *iamBackendClient) queryCacheExpression(pks []int, v interface{}) error {
 	pkList := make([]string, 0, len(pks))
 	for _, pk := range pks {
 		pkList = append(pkList, strconv.Itoa(pk))
@@ -334,10 +512,24 @@ func (c *iamBackendClient) QueryCacheExpression(pks []int) (map[string]interface
 		"pks": strings.Join(pkList, ","),
 	}
 
-	data, err := c.callWithReturnMapData(GET, path, body, 10)
+	return c.call(GET, path, body, 10, v)
+}
+
+func (c *iamBackendClient) QueryCacheExpression(pks []int) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+	err := c.queryCacheExpression(pks, &data)
 	return data, err
 }
 
+func (c *iamBackendClient) GetCacheExpression(pks []int) (*model.CacheExpression, error) {
+	var data model.CacheExpression
+	err := c.queryCacheExpression(pks, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
 // query system's policies, just for the system which need to use the policies to do something
 
 func (c *iamBackendClient) PolicyGet(policyID int64) (data map[string]interface{}, err error) {
@@ -346,12 +538,32 @@ func (c *iamBackendClient) PolicyGet(policyID int64) (data map[string]interface{
 	return
 }
 
+func (c *iamBackendClient) GetSystemPolicy(policyID int64) (*model.Policy, error) {
+	var data model.Policy
+	path := fmt.Sprintf("/api/v1/systems/%s/policies/%d", c.System, policyID)
+	err := c.call(GET, path, map[string]interface{}{}, 10, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
 func (c *iamBackendClient) PolicyList(body interface{}) (data map[string]interface{}, err error) {
 	path := fmt.Sprintf("/api/v1/systems/%s/policies", c.System)
 	data, err = c.callWithReturnMapData(GET, path, body, 10)
 	return
 }
 
+func (c *iamBackendClient) ListSystemPolicies(body interface{}) (*model.PolicyList, error) {
+	var data model.PolicyList
+	path := fmt.Sprintf("/api/v1/systems/%s/policies", c.System)
+	err := c.call(GET, path, body, 10, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
 func (c *iamBackendClient) PolicySubjects(policyIDs []int64) (data []map[string]interface{}, err error) {
 	path := fmt.Sprintf("/api/v1/systems/%s/policies/-/subjects", c.System)
 
@@ -362,6 +574,20 @@ func (c *iamBackendClient) PolicySubjects(policyIDs []int64) (data []map[string]
 	return
 }
 
+func (c *iamBackendClient) GetSystemPolicySubjects(policyIDs []int64) ([]model.PolicySubject, error) {
+	var data []model.PolicySubject
+	path := fmt.Sprintf("/api/v1/systems/%s/policies/-/subjects", c.System)
+
+	body := map[string]interface{}{
+		"ids": util.Int64ArrayToString(policyIDs, ","),
+	}
+	err := c.call(GET, path, body, 10, &data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 func (c *iamBackendClient) ListSystems() (data []map[string]interface{}, err error) {
 	path := "/api/v1/web/systems"
 
@@ -371,3 +597,17 @@ func (c *iamBackendClient) ListSystems() (data []map[string]interface{}, err err
 	data, err = c.callWithReturnSliceMapData(GET, path, body, 10)
 	return
 }
+
+func (c *iamBackendClient) GetSystems() ([]model.System, error) {
+	var data []model.System
+	path := "/api/v1/web/systems"
+
+	body := map[string]interface{}{
+		"fields": "",
+	}
+	err := c.call(GET, path, body, 10, &data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
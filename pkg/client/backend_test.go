@@ -0,0 +1,301 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"bk-iam-cli/pkg/expression"
+	"bk-iam-cli/pkg/mockserver"
+	"bk-iam-cli/pkg/model"
+)
+
+func newMockServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	handler, err := mockserver.New(mockserver.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestBackendClient(t *testing.T) IAMBackendClient {
+	server := newMockServer(t)
+	return NewIAMBackendClient(server.URL, "bk_sops", mockserver.DefaultAppCode, mockserver.DefaultAppSecret)
+}
+
+func TestGetVersion(t *testing.T) {
+	c := newTestBackendClient(t)
+
+	version, err := c.GetVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &model.VersionInfo{
+		Version:   "1.10.4",
+		Commit:    "9b1e3f1c2a",
+		BuildTime: "2022-03-10_08:12:41",
+		GoVersion: "go1.17.6",
+	}
+	if !reflect.DeepEqual(version, want) {
+		t.Errorf("got %+v, want %+v", version, want)
+	}
+}
+
+func TestGetSystems(t *testing.T) {
+	c := newTestBackendClient(t)
+
+	systems, err := c.GetSystems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(systems) != 2 {
+		t.Fatalf("got %d systems, want 2", len(systems))
+	}
+	if systems[0].ID != "bk_sops" || systems[0].ProviderConfig == nil ||
+		systems[0].ProviderConfig.Healthz != "/healthz" {
+		t.Errorf("unexpected system %+v", systems[0])
+	}
+	if systems[1].Clients != "bk_cmdb,bk_cmdb_web" {
+		t.Errorf("got clients %s", systems[1].Clients)
+	}
+}
+
+func TestGetModel(t *testing.T) {
+	c := newTestBackendClient(t)
+
+	m, err := c.GetModel("bk_sops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.System.ID != "bk_sops" || len(m.Actions) != 2 || len(m.ResourceTypes) != 2 || len(m.InstanceSelections) != 2 {
+		t.Fatalf("unexpected model %+v", m)
+	}
+
+	flowView := m.Actions[1]
+	if flowView.ID != "flow_view" || !reflect.DeepEqual(flowView.RelatedActions, []string{"project_view"}) {
+		t.Errorf("unexpected action %+v", flowView)
+	}
+	wantRRT := []model.RelatedResourceType{{
+		System:             "bk_sops",
+		ID:                 "flow",
+		SelectionMode:      "instance",
+		InstanceSelections: []model.ResourceTypeRef{{System: "bk_sops", ID: "flow"}},
+	}}
+	if !reflect.DeepEqual(flowView.RelatedResourceTypes, wantRRT) {
+		t.Errorf("got related resource types %+v, want %+v", flowView.RelatedResourceTypes, wantRRT)
+	}
+
+	if parents := m.ResourceTypes[1].Parents; len(parents) != 1 || parents[0].ID != "project" {
+		t.Errorf("unexpected parents %+v", parents)
+	}
+	if chain := m.InstanceSelections[1].ResourceTypeChain; len(chain) != 2 || chain[1].ID != "flow" {
+		t.Errorf("unexpected resource type chain %+v", chain)
+	}
+}
+
+func TestGetActions(t *testing.T) {
+	c := newTestBackendClient(t)
+
+	actions, err := c.GetActions("bk_sops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actions.IDs(), []string{"project_view", "flow_view"}) {
+		t.Errorf("got ids %v", actions.IDs())
+	}
+	if pk := actions.PK("flow_view"); pk != 3 {
+		t.Errorf("got pk %d, want 3", pk)
+	}
+	if pk := actions.PK("not_exists"); pk != 0 {
+		t.Errorf("got pk %d, want 0", pk)
+	}
+}
+
+func TestGetSubject(t *testing.T) {
+	c := newTestBackendClient(t)
+
+	subject, err := c.GetSubject("user", "tom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSubject := model.Subject{PK: 93162, Type: "user", ID: "tom"}
+	if subject.Subject != wantSubject {
+		t.Errorf("got subject %+v, want %+v", subject.Subject, wantSubject)
+	}
+
+	groups := subject.AllGroups()
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+	if groups[0].PK != 168966 || groups[0].Department != nil || !groups[0].IsPermanent() {
+		t.Errorf("unexpected direct group %+v", groups[0])
+	}
+	if groups[1].PK != 159041 || groups[1].Department == nil || groups[1].Department.Name != "部门1" ||
+		groups[1].PolicyExpiredAt != 1649591084 {
+		t.Errorf("unexpected department group %+v", groups[1])
+	}
+}
+
+func TestGetPolicyExpression(t *testing.T) {
+	c := newTestBackendClient(t)
+
+	expr, err := c.GetPolicyExpression("bk_sops", "user", "tom", "project_view", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr.Op != expression.OpOr || len(expr.Content) != 2 {
+		t.Fatalf("unexpected expression %s", expr)
+	}
+
+	allowed, _ := expression.Eval(expr, expression.Resource{"project.id": "42"})
+	if !allowed {
+		t.Errorf("project 42 should be allowed by %s", expr)
+	}
+}
+
+func TestGetCachePolicy(t *testing.T) {
+	c := newTestBackendClient(t)
+
+	cache, err := c.GetCachePolicy("bk_sops", "user", "tom", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantActions := []model.CacheAction{
+		{System: "bk_sops", ID: "common_flow_create", PK: 18},
+		{System: "bk_sops", ID: "project_view", PK: 2},
+	}
+	if cache.SubjectPK != 86769 || !reflect.DeepEqual(cache.Actions, wantActions) {
+		t.Errorf("unexpected cache policy %+v", cache)
+	}
+}
+
+func TestGetCachePolicyOfAction(t *testing.T) {
+	c := newTestBackendClient(t)
+
+	cache, err := c.GetCachePolicy("bk_sops", "user", "tom", "project_view")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPolicies := []model.CachedPolicy{
+		{PK: 1001, SubjectPK: 86769, ExpressionPK: 11332, ExpiredAt: 4102444800},
+		{PK: 1002, SubjectPK: 159041, ExpressionPK: 11333, ExpiredAt: 1649591084},
+	}
+	if cache.ActionPK != 2 || !reflect.DeepEqual(cache.Policies, wantPolicies) {
+		t.Fatalf("unexpected cache policy %+v", cache)
+	}
+
+	expr := cache.ExpressionOf(cache.Policies[0])
+	if expr == nil || expr.PK != 11332 {
+		t.Fatalf("got expression %+v", expr)
+	}
+	parsed, err := expr.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allowed, _ := expression.Eval(parsed, expression.Resource{"project.id": "14"}); !allowed {
+		t.Errorf("project 14 should be allowed by %s", parsed)
+	}
+
+	// the empty expression means any
+	parsed, err = cache.ExpressionOf(cache.Policies[1]).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Op != expression.OpAny {
+		t.Errorf("got %s, want any", parsed)
+	}
+}
+
+func TestGetCacheExpression(t *testing.T) {
+	c := newTestBackendClient(t)
+
+	cache, err := c.GetCacheExpression([]int{11332, 11334})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cache.NoCachePKs, []int64{11334}) || len(cache.Expressions) != 1 ||
+		cache.Expressions[0].Signature != "7a2d0f6e0e1b4c52c9b1b5f4d1c2b0d1" || cache.Err != nil {
+		t.Errorf("unexpected cache expression %+v", cache)
+	}
+}
+
+func TestSystemPolicies(t *testing.T) {
+	c := newTestBackendClient(t)
+
+	policy, err := c.GetSystemPolicy(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.ID != 1 || policy.Action == nil || policy.Action.ID != "project_view" ||
+		policy.Subject.String() != "user:tom" || policy.ExpiredAt != model.PermanentExpiredAt {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+	expr, err := policy.ParseExpression()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr.Op != expression.OpIn || expr.Field != "project.id" {
+		t.Errorf("unexpected expression %s", expr)
+	}
+
+	policies, err := c.ListSystemPolicies(map[string]interface{}{"action_id": "project_view"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policies.Count != 2 || policies.Metadata.Timestamp != 1642493707 || policies.Metadata.Action.ID != "project_view" ||
+		len(policies.Results) != 2 || policies.Results[1].Subject.Name != "运维组" {
+		t.Errorf("unexpected policies %+v", policies)
+	}
+
+	subjects, err := c.GetSystemPolicySubjects([]int64{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.PolicySubject{
+		{ID: 1, Subject: model.Subject{Type: "user", ID: "tom", Name: "tom"}},
+		{ID: 2, Subject: model.Subject{Type: "group", ID: "7", Name: "运维组"}},
+	}
+	if !reflect.DeepEqual(subjects, want) {
+		t.Errorf("got %+v, want %+v", subjects, want)
+	}
+}
+
+func TestTypedAndMapDataConsistent(t *testing.T) {
+	c := newTestBackendClient(t)
+
+	data, err := c.QuerySubject("user", "tom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data["departments"]; !ok {
+		t.Errorf("the map data should not be changed, got %v", data)
+	}
+}
+
+func TestInvalidAppSecret(t *testing.T) {
+	server := newMockServer(t)
+	c := NewIAMBackendClient(server.URL, "bk_sops", mockserver.DefaultAppCode, "wrong")
+
+	// ping is public
+	if err := c.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetSystems(); err == nil {
+		t.Error("get systems with the wrong app secret should fail")
+	}
+}
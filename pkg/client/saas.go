@@ -13,14 +13,11 @@ package client
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
-	"github.com/parnurzeal/gorequest"
-
-	"bk-iam-cli/pkg/logger"
+	"bk-iam-cli/pkg/client/transport"
+	"bk-iam-cli/pkg/model"
 )
 
 type IAMSaaSClient interface {
@@ -28,6 +25,11 @@ type IAMSaaSClient interface {
 
 	ListDebug(ymd string) (data []map[string]interface{}, err error)
 	GetDebug(request_id string) (data map[string]interface{}, err error)
+
+	// the typed version of the apis above
+
+	ListDebugEntries(ymd string) ([]model.DebugEntry, error)
+	GetDebugEntry(requestID string) (*model.DebugEntry, error)
 }
 
 type iamSaaSClient struct {
@@ -35,15 +37,38 @@ type iamSaaSClient struct {
 
 	appCode   string
 	appSecret string
+
+	transport *transport.Client
+	// the transport with the basic auth, for the apis except /ping
+	authTransport *transport.Client
+}
+
+// SaaSOption is the option of the IAM SaaS client
+type SaaSOption func(c *iamSaaSClient)
+
+// WithSaaSTransport sends the requests over the transport, default is transport.Default()
+func WithSaaSTransport(t *transport.Client) SaaSOption {
+	return func(c *iamSaaSClient) {
+		c.transport = t
+	}
 }
 
-func NewIAMSaaSClient(host string, appCode string, appSecret string) IAMSaaSClient {
-	return &iamSaaSClient{
+func NewIAMSaaSClient(host string, appCode string, appSecret string, opts ...SaaSOption) IAMSaaSClient {
+	c := &iamSaaSClient{
 		Host: host,
 
 		appCode:   appCode,
 		appSecret: appSecret,
+
+		transport: transport.Default(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	c.transport = c.transport.With(transport.Metrics("IAMSaaS", observeMetric))
+	c.authTransport = c.transport.With(transport.BasicAuth(c.appCode, c.appSecret), transport.Logging())
+	return c
 }
 
 func (c *iamSaaSClient) call(
@@ -53,48 +78,12 @@ func (c *iamSaaSClient) call(
 	timeout int64,
 	responseData interface{},
 ) error {
-	callTimeout := time.Duration(timeout) * time.Second
-	if timeout == 0 {
-		callTimeout = defaultTimeout
-	}
-
-	url := fmt.Sprintf("%s%s", c.Host, path)
-	result := IAMBackendResponse{}
-	start := time.Now()
-	callbackFunc := NewMetricCallback("IAMBackend", start)
-
-	// request := gorequest.New().Timeout(callTimeout).Post(url).Type("json")
-	request := gorequest.New().Timeout(callTimeout).Type("json").SetBasicAuth(c.appCode, c.appSecret)
-	switch method {
-	case POST:
-		request = request.Post(url).Send(data)
-	case GET:
-		request = request.Get(url).Query(data)
-	}
-
-	// do request
-	resp, _, errs := request.
-		EndStruct(&result, callbackFunc)
-
-	duration := time.Since(start)
-
-	logFailHTTPRequest(request, resp, errs, &result)
-
-	logger.Debug("do http request: method=`%s`, url=`%s`, data=`%s`", method, url, data)
-	logger.Debug("http request result: %v", result.String())
-	logger.Debug("http request took %v ms", float64(duration/time.Millisecond))
-
-	if len(errs) != 0 {
-		return fmt.Errorf("gorequest errors=`%s`", errs)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("gorequest statusCode is %d not 200", resp.StatusCode)
-	}
-	if result.Code != 0 {
-		return errors.New(result.Message)
+	result, err := call(c.authTransport, method, fmt.Sprintf("%s%s", c.Host, path), data, timeout)
+	if err != nil {
+		return err
 	}
 
-	err := json.Unmarshal(result.Data, responseData)
+	err = json.Unmarshal(result.Data, responseData)
 	if err != nil {
 		return fmt.Errorf("http request response body data not valid: %w, data=`%v`", err, result.Data)
 	}
@@ -131,14 +120,8 @@ func (c *iamSaaSClient) callWithReturnMapData(
 }
 
 func (c *iamSaaSClient) Ping() (err error) {
-	url := fmt.Sprintf("%s%s", c.Host, "/ping")
-
-	resp, _, errs := gorequest.New().Timeout(5 * time.Second).Get(url).EndBytes()
-	if len(errs) != 0 {
-		return fmt.Errorf("ping fail! errs=%v", errs)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("ping fail! status_code=%d", resp.StatusCode)
+	if _, err = get(c.transport, c.Host+"/ping", 5*time.Second); err != nil {
+		return fmt.Errorf("ping fail! %w", err)
 	}
 	return nil
 }
@@ -158,3 +141,27 @@ func (c *iamSaaSClient) GetDebug(request_id string) (data map[string]interface{}
 	data, err = c.callWithReturnMapData(GET, path, map[string]interface{}{}, 10)
 	return
 }
+
+func (c *iamSaaSClient) ListDebugEntries(ymd string) ([]model.DebugEntry, error) {
+	var data []model.DebugEntry
+	path := "/api/v1/debug/"
+
+	body := map[string]interface{}{
+		"day": ymd,
+	}
+	err := c.call(GET, path, body, 10, &data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *iamSaaSClient) GetDebugEntry(requestID string) (*model.DebugEntry, error) {
+	var data model.DebugEntry
+	path := fmt.Sprintf("/api/v1/debug/%s/", requestID)
+	err := c.call(GET, path, map[string]interface{}{}, 10, &data)
+	if err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
@@ -0,0 +1,45 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package client
+
+import (
+	"testing"
+
+	"bk-iam-cli/pkg/mockserver"
+)
+
+func TestDebugEntries(t *testing.T) {
+	server := newMockServer(t)
+	c := NewIAMSaaSClient(server.URL, mockserver.DefaultAppCode, mockserver.DefaultAppSecret)
+
+	entries, err := c.ListDebugEntries("20220310")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Type != "api" || entries[0].HasError() {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+	if entries[1].Type != "task" || !entries[1].HasError() {
+		t.Errorf("unexpected entry %+v", entries[1])
+	}
+
+	entry, err := c.GetDebugEntry(entries[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Path != "/api/v1/open/application/" || len(entry.Stack) != 1 {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+}
@@ -0,0 +1,199 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package transport
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotRecorded is returned by the replay if the request not found in the recording
+var ErrNotRecorded = errors.New("not recorded")
+
+// recordMu serializes the writes of the recording, e.g. the concurrent requests of batch
+var recordMu sync.Mutex
+
+// Interaction is a request/response pair saved by Record, one file per request in the dir
+type Interaction struct {
+	Request    RecordedRequest  `json:"request"`
+	Response   RecordedResponse `json:"response"`
+	RecordedAt time.Time        `json:"recorded_at"`
+	Duration   string           `json:"duration"`
+}
+
+// RecordedRequest is the request with the secrets in header redacted, the same as the curl dump
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	recordedBody
+}
+
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	recordedBody
+}
+
+// recordedBody keeps the json body readable in the file(indented), the others as string
+type recordedBody struct {
+	Body    json.RawMessage `json:"body,omitempty"`
+	RawBody string          `json:"raw_body,omitempty"`
+}
+
+func newRecordedBody(body []byte) recordedBody {
+	if len(body) > 0 && json.Valid(body) {
+		return recordedBody{Body: body}
+	}
+	return recordedBody{RawBody: string(body)}
+}
+
+func (b recordedBody) bytes() []byte {
+	if len(b.Body) > 0 {
+		return b.Body
+	}
+	return []byte(b.RawBody)
+}
+
+// interactionFile returns the file of the request in the dir, the same request is saved to the same file;
+// the host is not part of the key, so the recording can be replayed with any host, e.g. the test server
+func interactionFile(dir string, req *http.Request, body []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %s?%s\n", req.Method, req.URL.Path, req.URL.Query().Encode())
+	h.Write(body)
+
+	name := strings.Trim(strings.ReplaceAll(req.URL.Path, "/", "_"), "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return filepath.Join(dir, fmt.Sprintf("%s-%s-%s.json",
+		strings.ToLower(req.Method), name, hex.EncodeToString(h.Sum(nil))[:10]))
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, err
+}
+
+// Record saves each request/response pair into the dir, should be the innermost middleware,
+// so the request is the one sent, e.g. with the auth headers
+func Record(dir string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			reqBody, err := readRequestBody(req)
+			if err != nil {
+				return nil, fmt.Errorf("read request body fail! %w", err)
+			}
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			// the failed request without response is not recorded
+			if err != nil {
+				return resp, err
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("read response body fail! %w", err)
+			}
+			resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			header := req.Header.Clone()
+			redactHeader(header)
+			i := Interaction{
+				Request: RecordedRequest{
+					Method:       req.Method,
+					URL:          req.URL.String(),
+					Header:       header,
+					recordedBody: newRecordedBody(reqBody),
+				},
+				Response: RecordedResponse{
+					Status:       resp.StatusCode,
+					Header:       resp.Header,
+					recordedBody: newRecordedBody(body),
+				},
+				RecordedAt: start,
+				Duration:   time.Since(start).String(),
+			}
+
+			recordMu.Lock()
+			defer recordMu.Unlock()
+			if err = writeInteraction(interactionFile(dir, req, reqBody), i); err != nil {
+				return nil, fmt.Errorf("record the request fail! %w", err)
+			}
+			return resp, nil
+		})
+	}
+}
+
+func writeInteraction(file string, i Interaction) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	dat, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+	// the response may be sensitive, e.g. the policies
+	return ioutil.WriteFile(file, dat, 0o600)
+}
+
+// Replay returns the round tripper serves the responses from the recording of Record, no request is sent
+func Replay(dir string) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, err := readRequestBody(req)
+		if err != nil {
+			return nil, fmt.Errorf("read request body fail! %w", err)
+		}
+
+		file := interactionFile(dir, req, body)
+		dat, err := ioutil.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("%w: %s %s in %s", ErrNotRecorded, req.Method, req.URL.RequestURI(), dir)
+			}
+			return nil, fmt.Errorf("read recording fail! %w", err)
+		}
+		var i Interaction
+		if err = json.Unmarshal(dat, &i); err != nil {
+			return nil, fmt.Errorf("invalid recording %s! %w", file, err)
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", i.Response.Status, http.StatusText(i.Response.Status)),
+			StatusCode:    i.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        i.Response.Header,
+			Body:          ioutil.NopCloser(bytes.NewReader(i.Response.bytes())),
+			ContentLength: int64(len(i.Response.bytes())),
+			Request:       req,
+		}, nil
+	})
+}
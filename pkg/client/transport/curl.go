@@ -0,0 +1,85 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"moul.io/http2curl"
+)
+
+// ErrDryRun is returned by the dry run without sending the request
+var ErrDryRun = errors.New("dry run, the request is not sent")
+
+// CurlCommand returns a string representing the runnable `curl' command version of the request,
+// the secrets in headers are masked, the body of the request is kept for sending
+func CurlCommand(req *http.Request) (string, error) {
+	return curlCommand(req, false)
+}
+
+func curlCommand(req *http.Request, showSecret bool) (string, error) {
+	dump := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		dump.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if !showSecret {
+		redactHeader(dump.Header)
+	}
+
+	cmd, err := http2curl.GetCurlCommand(dump)
+	if err != nil {
+		return "", err
+	}
+	return cmd.String(), nil
+}
+
+// redactHeader masks the secrets in the header, e.g. of the curl dump and the recording
+func redactHeader(header http.Header) {
+	// 脱敏, 去掉-H 中 Authorization
+	header.Del("Authorization")
+	if header.Get("X-Bk-App-Secret") != "" {
+		header.Set("X-Bk-App-Secret", "*****")
+	}
+}
+
+// Curl prints the curl command of each request into w, the secrets are masked unless showSecret,
+// should be the innermost middleware, so the command is the one sent, e.g. with the auth headers
+func Curl(w io.Writer, showSecret bool) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			cmd, err := curlCommand(req, showSecret)
+			if err != nil {
+				return nil, fmt.Errorf("request AsCurlCommand fail! %w", err)
+			}
+			fmt.Fprintln(w, cmd)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// DryRun returns the round tripper prints the curl command of the request into w instead of sending it
+func DryRun(w io.Writer, showSecret bool) http.RoundTripper {
+	return Curl(w, showSecret)(RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, ErrDryRun
+	}))
+}
@@ -0,0 +1,181 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"bk-iam-cli/pkg/logger"
+)
+
+// Middleware wraps the round tripper, e.g. add the auth headers, log the request or retry on failure
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is the func as http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wraps the base with the middlewares, the first one is the outermost, e.g.
+// Chain(t, auth, logging) runs auth -> logging -> t, so the logging sees the auth headers
+func Chain(base http.RoundTripper, middlewares ...Middleware) http.RoundTripper {
+	rt := base
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+	return rt
+}
+
+// Header sets the headers of all requests, e.g. X-BK-APP-CODE/X-BK-APP-SECRET of the backend
+func Header(headers map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			// the round tripper should not modify the request
+			req = req.Clone(req.Context())
+			for key, value := range headers {
+				req.Header.Set(key, value)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// BasicAuth sets the basic auth of all requests, e.g. the app_code/app_secret of the SaaS
+func BasicAuth(username, password string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.SetBasicAuth(username, password)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Query adds the query params to all requests, e.g. debug=true
+func Query(params map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			query := req.URL.Query()
+			for key, value := range params {
+				query.Set(key, value)
+			}
+			req.URL.RawQuery = query.Encode()
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// Logging logs the request as curl command, at error level if the request fail,
+// including the IAM response with code != 0, otherwise at debug level
+func Logging() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			dump, err := CurlCommand(req)
+			if err != nil {
+				logger.Error("request AsCurlCommand fail! %s", err.Error())
+			}
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			duration := time.Since(start)
+
+			// the response is nil if the request fail, e.g. connection refused
+			status := -1
+			requestID := ""
+			message := "-"
+			if resp != nil {
+				status = resp.StatusCode
+				requestID = resp.Header.Get("X-Request-Id")
+				if code, msg := peekResponseCode(resp); code != 0 {
+					message = fmt.Sprintf("response error[code=`%d`,  message=`%s`]", code, msg)
+				}
+			}
+
+			switch {
+			case errors.Is(err, ErrDryRun):
+				// the curl command is printed already
+			case err != nil || status != http.StatusOK || message != "-":
+				logger.Error("[http request fail] %s! status=`%d`, err=`%v`, request_id=`%s`, request=`%s`",
+					message, status, err, requestID, dump)
+			default:
+				logger.Debug("[http request] status=`%d`, request_id=`%s`, request=`%s`", status, requestID, dump)
+			}
+			logger.Debug("http request took %v ms", float64(duration/time.Millisecond))
+			return resp, err
+		})
+	}
+}
+
+// peekResponseCode returns the code and message of the IAM response, the body is kept for reading
+func peekResponseCode(resp *http.Response) (int, string) {
+	if resp.Body == nil {
+		return 0, ""
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return 0, ""
+	}
+
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &result) != nil {
+		return 0, ""
+	}
+	return result.Code, result.Message
+}
+
+// Metric is the result of a request, observed by the Metrics middleware
+type Metric struct {
+	Component string
+	Method    string
+	Path      string
+	// -1 if the request fail without response
+	Status   int
+	Duration time.Duration
+}
+
+// Metrics observes the metric of all requests, e.g. export to prometheus
+func Metrics(component string, observe func(m Metric)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+
+			status := -1
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			observe(Metric{
+				Component: component,
+				Method:    req.Method,
+				Path:      req.URL.Path,
+				Status:    status,
+				Duration:  time.Since(start),
+			})
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"bk-iam-cli/pkg/logger"
+)
+
+const (
+	DefaultRetries      = 2
+	DefaultRetryMinWait = 200 * time.Millisecond
+	DefaultRetryMaxWait = 5 * time.Second
+
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 10 * time.Second
+)
+
+// ErrCircuitOpen is returned without sending the request if the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open, too many failures")
+
+// RetryPolicy is the policy of the Retry middleware, only the idempotent requests(GET/HEAD/OPTIONS) are retried,
+// on the connection errors, 5xx/429 or the IAM response with the codes
+type RetryPolicy struct {
+	// Retries is the max retries after the first attempt, 0 disables the retry
+	Retries int
+	// the wait before the nth retry is MinWait * 2^(n-1) with jitter, at most MaxWait
+	MinWait time.Duration
+	MaxWait time.Duration
+	// Codes are the codes of the IAM response to retry, e.g. too many requests
+	Codes []int
+}
+
+// backoff returns the wait before the retry(from 1), the full jitter in [wait/2, wait]
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	wait := p.MinWait
+	for i := 1; i < retry && wait < p.MaxWait; i++ {
+		wait *= 2
+	}
+	if wait > p.MaxWait {
+		wait = p.MaxWait
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// retryable returns the reason if the result of the request should be retried, or empty
+func (p RetryPolicy) retryable(resp *http.Response, err error) string {
+	switch {
+	case errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrNotRecorded), errors.Is(err, ErrDryRun):
+		return ""
+	case err != nil:
+		return err.Error()
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Sprintf("status %d", resp.StatusCode)
+	}
+
+	if len(p.Codes) > 0 {
+		code, message := peekResponseCode(resp)
+		for _, c := range p.Codes {
+			if code == c {
+				return fmt.Sprintf("code %d, %s", code, message)
+			}
+		}
+	}
+	return ""
+}
+
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// Retry retries the failed idempotent requests with exponential backoff and jitter
+func Retry(p RetryPolicy) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if p.Retries <= 0 || !idempotent(req.Method) {
+				return next.RoundTrip(req)
+			}
+
+			for retry := 1; ; retry++ {
+				resp, err := next.RoundTrip(req)
+				reason := p.retryable(resp, err)
+				// not retry if canceled by the caller, e.g. ctrl-c
+				if reason == "" || retry > p.Retries || req.Context().Err() != nil {
+					return resp, err
+				}
+
+				requestID := ""
+				if resp != nil {
+					requestID = resp.Header.Get("X-Request-Id")
+					resp.Body.Close()
+				}
+				wait := p.backoff(retry)
+				logger.Debug("retry %d/%d of %s %s after %s: %s, request_id=`%s`",
+					retry, p.Retries, req.Method, req.URL.Path, wait, reason, requestID)
+
+				select {
+				case <-time.After(wait):
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+			}
+		})
+	}
+}
+
+// CircuitBreaker fails fast with ErrCircuitOpen after the threshold consecutive failures of a host,
+// a trial request is allowed after the cooldown, the circuit is closed again if it succeeds;
+// the state is shared by the requests through the returned middleware, e.g. the concurrent ones of batch
+func CircuitBreaker(
+	threshold int,
+	cooldown time.Duration,
+	failed func(resp *http.Response, err error) bool,
+) Middleware {
+	var (
+		mu       sync.Mutex
+		failures = map[string]int{}
+		openedAt = map[string]time.Time{}
+	)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if threshold <= 0 {
+				return next.RoundTrip(req)
+			}
+			host := req.URL.Host
+
+			mu.Lock()
+			if opened, ok := openedAt[host]; ok {
+				if time.Since(opened) < cooldown {
+					mu.Unlock()
+					return nil, fmt.Errorf("%w, retry %s after %s",
+						ErrCircuitOpen, host, (cooldown - time.Since(opened)).Truncate(time.Second))
+				}
+				// half open, only one trial until the result known
+				openedAt[host] = time.Now()
+			}
+			mu.Unlock()
+
+			resp, err := next.RoundTrip(req)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if failed(resp, err) {
+				failures[host]++
+				if failures[host] >= threshold {
+					if _, ok := openedAt[host]; !ok {
+						logger.Debug("circuit breaker of %s opened after %d failures", host, failures[host])
+					}
+					openedAt[host] = time.Now()
+				}
+			} else {
+				delete(failures, host)
+				delete(openedAt, host)
+			}
+			return resp, err
+		})
+	}
+}
+
+// Failed returns true if the result should be retried by the policy, e.g. as the failure of the circuit breaker
+func (p RetryPolicy) Failed(resp *http.Response, err error) bool {
+	return p.retryable(resp, err) != ""
+}
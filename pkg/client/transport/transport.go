@@ -0,0 +1,332 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+// Package transport is the http layer shared by the IAM clients, the requests are sent over a shared
+// net/http transport(the connections are reused) through a chain of middlewares, e.g. auth, logging and metrics
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// Config is the config of the transport, usually set by the global flags, e.g. --proxy
+type Config struct {
+	// Proxy is the proxy url, e.g. http://127.0.0.1:3128, the env HTTP_PROXY/HTTPS_PROXY/NO_PROXY is used if empty
+	Proxy string
+	// CAFile is the pem bundle to verify the https IAM deployments, besides the system ones
+	CAFile string
+	// InsecureSkipVerify skips the verification of the https server certificate, for test only
+	InsecureSkipVerify bool
+	// CertFile and KeyFile are the pem client certificate and key, for the gateway requires mTLS
+	CertFile string
+	KeyFile  string
+	// Timeout overrides the timeout of all requests if not 0, e.g. a slow policy query
+	Timeout time.Duration
+
+	// Retries is the max retries of the failed idempotent requests, 0 disables the retry
+	Retries int
+	// RetryMaxWait is the max wait between the retries
+	RetryMaxWait time.Duration
+
+	// Record is the dir to save the request/response pairs, see Record
+	Record string
+	// Replay is the dir of the recording to serve the responses from, no request is sent, see Replay
+	Replay string
+
+	// Curl prints the curl command of each request into stderr, the output of the command is kept in stdout
+	Curl bool
+	// DryRun prints the curl command of the request into DryRunOutput(stdout if nil) instead of sending it
+	DryRun       bool
+	DryRunOutput io.Writer
+	// ShowSecret shows the secrets in the curl command of Curl/DryRun, e.g. to copy and run somewhere else
+	ShowSecret bool
+}
+
+// RetryPolicy returns the retry policy of the config, with the codes of the response to retry
+func (c Config) RetryPolicy(codes ...int) RetryPolicy {
+	maxWait := c.RetryMaxWait
+	if maxWait <= 0 {
+		maxWait = DefaultRetryMaxWait
+	}
+	return RetryPolicy{Retries: c.Retries, MinWait: DefaultRetryMinWait, MaxWait: maxWait, Codes: codes}
+}
+
+var (
+	transportsMu sync.Mutex
+	// the transports are shared by config, so the clients with the same config reuse the connections
+	transports = map[Config]*http.Transport{}
+)
+
+// NewTransport returns the shared net/http transport of the config, only the proxy and tls settings are used
+func NewTransport(cfg Config) (*http.Transport, error) {
+	cfg = Config{
+		Proxy:              cfg.Proxy,
+		CAFile:             cfg.CAFile,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		CertFile:           cfg.CertFile,
+		KeyFile:            cfg.KeyFile,
+	}
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if t, ok := transports[cfg]; ok {
+		return t, nil
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if cfg.Proxy != "" {
+		u, err := url.Parse(cfg.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy `%s`, should be like http://127.0.0.1:3128", cfg.Proxy)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if cfg.CAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file fail! %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate found in ca file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("the client certificate and key should be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate fail! %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	t := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	transports[cfg] = t
+	return t, nil
+}
+
+// Client sends the requests through the middlewares, the clients derived by With share the transport
+type Client struct {
+	config      Config
+	base        http.RoundTripper
+	middlewares []Middleware
+}
+
+// New returns the client over the shared transport of the config
+func New(cfg Config, middlewares ...Middleware) (*Client, error) {
+	t, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{config: cfg, base: t, middlewares: middlewares}, nil
+}
+
+// NewWithRoundTripper returns the client over the round tripper, e.g. a fake one in test
+func NewWithRoundTripper(cfg Config, base http.RoundTripper, middlewares ...Middleware) *Client {
+	return &Client{config: cfg, base: base, middlewares: middlewares}
+}
+
+var (
+	defaultOnce   sync.Once
+	defaultClient *Client
+)
+
+// Default returns the client over the transport with the default config(proxy from env, system CAs, default retries)
+func Default() *Client {
+	defaultOnce.Do(func() {
+		// NOTE: never fail without the proxy and ca file
+		defaultClient, _ = New(Config{Retries: DefaultRetries, RetryMaxWait: DefaultRetryMaxWait})
+	})
+	return defaultClient
+}
+
+// With returns a copy of the client with more middlewares appended, the transport is shared
+func (c *Client) With(middlewares ...Middleware) *Client {
+	mws := make([]Middleware, 0, len(c.middlewares)+len(middlewares))
+	mws = append(append(mws, c.middlewares...), middlewares...)
+	return &Client{config: c.config, base: c.base, middlewares: mws}
+}
+
+// Config returns the config of the client
+func (c *Client) Config() Config {
+	return c.config
+}
+
+// Do sends the request with the timeout(overridden by the config) and returns the response with the whole body read,
+// the timeout is of each attempt, e.g. a retried request may take longer
+func (c *Client) Do(req *http.Request, timeout time.Duration) (*http.Response, []byte, error) {
+	if c.config.Timeout > 0 {
+		timeout = c.config.Timeout
+	}
+
+	base := c.base
+	switch {
+	case c.config.DryRun:
+		w := c.config.DryRunOutput
+		if w == nil {
+			w = os.Stdout
+		}
+		base = DryRun(w, c.config.ShowSecret)
+	case c.config.Replay != "":
+		base = Replay(c.config.Replay)
+	}
+	mws := make([]Middleware, 0, len(c.middlewares)+3)
+	mws = append(append(mws, c.middlewares...), attemptTimeout(timeout))
+	if c.config.Curl && !c.config.DryRun {
+		mws = append(mws, Curl(os.Stderr, c.config.ShowSecret))
+	}
+	if c.config.Record != "" {
+		mws = append(mws, Record(c.config.Record))
+	}
+
+	resp, err := Chain(base, mws...).RoundTrip(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, fmt.Errorf("read response body fail! %w", err)
+	}
+	return resp, body, nil
+}
+
+// attemptTimeout is the innermost middleware, cancels the request if the response not finished in time
+func attemptTimeout(timeout time.Duration) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if timeout <= 0 {
+				return next.RoundTrip(req)
+			}
+
+			ctx, cancel := context.WithTimeout(req.Context(), timeout)
+			resp, err := next.RoundTrip(req.WithContext(ctx))
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			// the body should be readable until closed
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		})
+	}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// NewRequest returns the request with the data as query string of GET, or the json body of the others
+func NewRequest(method, rawURL string, data interface{}) (*http.Request, error) {
+	if method == http.MethodGet {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url `%s`! %w", rawURL, err)
+		}
+		query, err := EncodeQuery(u.Query(), data)
+		if err != nil {
+			return nil, err
+		}
+		u.RawQuery = query.Encode()
+		return http.NewRequest(method, u.String(), nil)
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request body fail! %w", err)
+	}
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// EncodeQuery adds the fields of data(a map or struct) into the query, the lists are added as repeated keys
+func EncodeQuery(query url.Values, data interface{}) (url.Values, error) {
+	if data == nil {
+		return query, nil
+	}
+
+	// the struct is converted to map by the json tags
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("marshal query data fail! %w", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.UseNumber()
+		if err = decoder.Decode(&fields); err != nil {
+			return nil, fmt.Errorf("query data should be a map or struct! %w", err)
+		}
+	}
+
+	for key, value := range fields {
+		switch v := value.(type) {
+		case nil:
+			continue
+		case []interface{}:
+			for _, e := range v {
+				query.Add(key, fmt.Sprint(e))
+			}
+		case []string:
+			for _, e := range v {
+				query.Add(key, e)
+			}
+		default:
+			query.Add(key, fmt.Sprint(v))
+		}
+	}
+	return query, nil
+}
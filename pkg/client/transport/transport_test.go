@@ -0,0 +1,328 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+":"+req.Header.Get("X-Token"))
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		order = append(order, "base:"+req.Header.Get("X-Token"))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"code": 0}`))}, nil
+	})
+
+	c := NewWithRoundTripper(Config{}, base, mark("first"), Header(map[string]string{"X-Token": "t"}))
+	c = c.With(mark("last"))
+
+	req, _ := http.NewRequest(http.MethodGet, "http://iam/ping", nil)
+	_, body, err := c.Do(req, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"code": 0}` {
+		t.Errorf("got body %s", body)
+	}
+	if strings.Join(order, ",") != "first:,last:t,base:t" {
+		t.Errorf("got order %v", order)
+	}
+	if req.Header.Get("X-Token") != "" {
+		t.Error("the request should not be modified by the middleware")
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	req, err := NewRequest(http.MethodGet, "http://iam/api?a=1", map[string]interface{}{
+		"system": "bk_sops",
+		"page":   2,
+		"force":  true,
+		"ids":    []string{"1", "2"},
+		"none":   nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := url.Values{"a": {"1"}, "system": {"bk_sops"}, "page": {"2"}, "force": {"true"}, "ids": {"1", "2"}}
+	if got := req.URL.Query(); got.Encode() != want.Encode() {
+		t.Errorf("got query %s, want %s", got.Encode(), want.Encode())
+	}
+
+	req, err = NewRequest(http.MethodPost, "http://iam/api", struct {
+		ID int `json:"id"`
+	}{ID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	if string(body) != `{"id":1}` || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("got body %s, header %v", body, req.Header)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		_, _ = w.Write([]byte("pong"))
+	}))
+	defer proxy.Close()
+
+	c, err := New(Config{Proxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://iam.example.com/ping", nil)
+	_, body, err := c.Do(req, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxied != "http://iam.example.com/ping" || string(body) != "pong" {
+		t.Errorf("the request should be sent via the proxy, got %s %s", proxied, body)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	if _, err := New(Config{Proxy: "127.0.0.1"}); err == nil {
+		t.Error("the proxy without scheme should be invalid")
+	}
+
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	if err := ioutil.WriteFile(file, []byte("not a pem"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(Config{CAFile: file}); err == nil {
+		t.Error("the ca file without certificate should be invalid")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	// the timeout of the config overrides the one of the request
+	c, err := New(Config{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, _, err = c.Do(req, time.Minute); err == nil {
+		t.Error("the request should timeout")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	attempts := 0
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		body := `{"code": 0}`
+		switch attempts {
+		case 1:
+			return nil, errors.New("connection reset")
+		case 2:
+			body = `{"code": 1901429, "message": "too many requests"}`
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"X-Request-Id": {"r1"}},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+	policy := RetryPolicy{Retries: 2, MinWait: time.Millisecond, MaxWait: 2 * time.Millisecond, Codes: []int{1901429}}
+
+	c := NewWithRoundTripper(Config{}, base, Retry(policy))
+	req, _ := http.NewRequest(http.MethodGet, "http://iam/api", nil)
+	_, body, err := c.Do(req, time.Second)
+	if err != nil || attempts != 3 || string(body) != `{"code": 0}` {
+		t.Errorf("got %s %v after %d attempts", body, err, attempts)
+	}
+
+	// not idempotent
+	attempts = 0
+	req, _ = http.NewRequest(http.MethodPost, "http://iam/api", nil)
+	if _, _, err = c.Do(req, time.Second); err == nil || attempts != 1 {
+		t.Errorf("post should not be retried, got %v after %d attempts", err, attempts)
+	}
+
+	for retry, longest := 1, time.Duration(0); retry <= 10; retry++ {
+		wait := RetryPolicy{MinWait: 100 * time.Millisecond, MaxWait: time.Second}.backoff(retry)
+		if wait > time.Second || wait < longest/2 {
+			t.Errorf("backoff of retry %d: %s", retry, wait)
+		}
+		if wait > longest {
+			longest = wait
+		}
+	}
+}
+
+func TestRetryNotIdempotent(t *testing.T) {
+	attempts := 0
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"code": 1901429}`)),
+		}, nil
+	})
+
+	// the same chain as the clients, with the retries of the flags
+	cfg := Config{Retries: 3, RetryMaxWait: time.Millisecond}
+	retry := cfg.RetryPolicy(1901429)
+	retry.MinWait = time.Millisecond
+	do := func(method string) *http.Response {
+		c := NewWithRoundTripper(cfg, base,
+			Retry(retry), CircuitBreaker(DefaultBreakerThreshold, DefaultBreakerCooldown, retry.Failed))
+		req, _ := http.NewRequest(method, "http://iam/api", strings.NewReader(`{}`))
+		attempts = 0
+		resp, _, err := c.Do(req, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if resp := do(method); resp.StatusCode != http.StatusServiceUnavailable || attempts != 1 {
+			t.Errorf("%s should never be retried, got %d after %d attempts", method, resp.StatusCode, attempts)
+		}
+	}
+	if resp := do(http.MethodGet); resp.StatusCode != http.StatusServiceUnavailable || attempts != 4 {
+		t.Errorf("get should be retried 3 times, got %d after %d attempts", resp.StatusCode, attempts)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	fail := true
+	attempts := 0
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	cooldown := 50 * time.Millisecond
+	c := NewWithRoundTripper(Config{}, base, CircuitBreaker(2, cooldown, RetryPolicy{}.Failed))
+	do := func() error {
+		req, _ := http.NewRequest(http.MethodGet, "http://iam/api", nil)
+		_, _, err := c.Do(req, time.Second)
+		return err
+	}
+
+	_ = do()
+	_ = do()
+	if err := do(); !errors.Is(err, ErrCircuitOpen) || attempts != 2 {
+		t.Fatalf("should fail fast after 2 failures, got %v after %d attempts", err, attempts)
+	}
+
+	time.Sleep(cooldown)
+	fail = false
+	if err := do(); err != nil || attempts != 3 {
+		t.Errorf("the trial should be sent after the cooldown, got %v after %d attempts", err, attempts)
+	}
+	if err := do(); err != nil || attempts != 4 {
+		t.Errorf("the circuit should be closed, got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"code": 0, "data": "` + r.URL.Query().Get("system") + `"}`))
+	}))
+	dir := t.TempDir()
+
+	c, err := New(Config{Record: dir}, Header(map[string]string{"X-Bk-App-Secret": "s3cret"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := NewRequest(http.MethodGet, server.URL+"/api/v1/systems", map[string]string{"system": "bk_sops"})
+	if _, body, err := c.Do(req, time.Second); err != nil || string(body) != `{"code": 0, "data": "bk_sops"}` {
+		t.Fatalf("got %s %v", body, err)
+	}
+	server.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("got recording %v", files)
+	}
+	dat, _ := ioutil.ReadFile(files[0])
+	if strings.Contains(string(dat), "s3cret") || !strings.Contains(string(dat), `"data": "bk_sops"`) {
+		t.Errorf("unexpected recording %s", dat)
+	}
+
+	// no request sent, the host is not part of the key
+	c, _ = New(Config{Replay: dir})
+	req, _ = NewRequest(http.MethodGet, "http://iam.example.com/api/v1/systems", map[string]string{"system": "bk_sops"})
+	resp, body, err := c.Do(req, time.Second)
+	var data struct {
+		Data string `json:"data"`
+	}
+	if err != nil || resp.StatusCode != http.StatusOK || json.Unmarshal(body, &data) != nil || data.Data != "bk_sops" {
+		t.Errorf("got %s %v", body, err)
+	}
+
+	req, _ = NewRequest(http.MethodGet, "http://iam.example.com/api/v1/systems", map[string]string{"system": "bk_cmdb"})
+	if _, _, err = c.Do(req, time.Second); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("should fail if not recorded, got %v", err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	var out strings.Builder
+	c := NewWithRoundTripper(Config{}, DryRun(&out, false),
+		Header(map[string]string{"X-Bk-App-Secret": "s3cret"}), Retry(RetryPolicy{Retries: 2}))
+	req, _ := http.NewRequest(http.MethodGet, "http://iam/api", nil)
+	if _, _, err := c.Do(req, time.Second); !errors.Is(err, ErrDryRun) {
+		t.Errorf("got err %v, want ErrDryRun", err)
+	}
+	// not retried
+	if got := out.String(); strings.Count(got, "curl ") != 1 || strings.Contains(got, "s3cret") {
+		t.Errorf("got curl %s", got)
+	}
+
+	out.Reset()
+	c = NewWithRoundTripper(Config{}, DryRun(&out, true), BasicAuth("bk_iam", "s3cret"))
+	_, _, _ = c.Do(req, time.Second)
+	if !strings.Contains(out.String(), "Authorization: Basic") {
+		t.Errorf("the secret should be shown, got %s", out.String())
+	}
+	// by the config, the base is never called
+	out.Reset()
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		t.Error("the request should not be sent")
+		return nil, errors.New("sent")
+	})
+	c = NewWithRoundTripper(Config{DryRun: true, DryRunOutput: &out}, base)
+	if _, _, err := c.Do(req, time.Second); !errors.Is(err, ErrDryRun) || !strings.Contains(out.String(), "curl ") {
+		t.Errorf("got err %v, curl %s", err, out.String())
+	}
+}
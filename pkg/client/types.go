@@ -12,30 +12,85 @@
 package client
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 	"time"
 
-	"github.com/parnurzeal/gorequest"
+	"bk-iam-cli/pkg/client/transport"
+	"bk-iam-cli/pkg/logger"
 )
 
 const (
 	defaultTimeout = 5 * time.Second
 )
 
-type responseBody interface {
-	Error() error
+// callTimeout returns the timeout in seconds as duration, the default one if 0
+func callTimeout(timeout int64) time.Duration {
+	if timeout == 0 {
+		return defaultTimeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// observeMetric is the observer of the transport.Metrics middleware
+func observeMetric(m transport.Metric) {
+	// metric.ComponentRequestDuration.With(prometheus.Labels{
+	//	"method":    m.Method,
+	//	"path":      m.Path,
+	//	"status":    strconv.Itoa(m.Status),
+	//	"component": m.Component,
+	// }).Observe(float64(m.Duration / time.Millisecond))
 }
 
-type CallbackFunc func(response gorequest.Response, v interface{}, body []byte, errs []error)
+// call sends the request to the IAM api and returns the whole response, the code of response is checked
+func call(
+	c *transport.Client,
+	method Method,
+	url string,
+	data interface{},
+	timeout int64,
+) (*IAMBackendResponse, error) {
+	req, err := transport.NewRequest(string(method), url, data)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("do http request: method=`%s`, url=`%s`, data=`%s`", method, url, data)
+	resp, body, err := c.Do(req, callTimeout(timeout))
+	if err != nil {
+		return nil, fmt.Errorf("http request fail! %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http request statusCode is %d not 200", resp.StatusCode)
+	}
+
+	result := IAMBackendResponse{}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("http request response body not valid: %w, body=`%s`", err, body)
+	}
+	logger.Debug("http request result: %v", result.String())
 
-func NewMetricCallback(system string, start time.Time) CallbackFunc {
-	return func(response gorequest.Response, v interface{}, body []byte, errs []error) {
-		// duration := time.Since(start)
+	if result.Code != 0 {
+		return nil, errors.New(result.Message)
+	}
+	return &result, nil
+}
 
-		// metric.ComponentRequestDuration.With(prometheus.Labels{
-		//	"method":    response.Request.Method,
-		//	"path":      response.Request.URL.Path,
-		//	"status":    strconv.Itoa(response.StatusCode),
-		//	"component": system,
-		// }).Observe(float64(duration / time.Millisecond))
+// get sends the GET request to the public api, e.g. /ping, and returns the body if status is 200
+func get(c *transport.Client, url string, timeout time.Duration) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, body, err := c.Do(req, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("errs=%w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return body, fmt.Errorf("status_code=%d, body=%s", resp.StatusCode, body)
 	}
+	return body, nil
 }
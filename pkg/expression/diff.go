@@ -0,0 +1,129 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package expression
+
+import (
+	"sort"
+)
+
+// Diff is the semantic difference of two expressions, each item is a grant(an operand of the top-level OR),
+// the `in`/`eq` grants are split into values, e.g. `project.id in [1, 2]` vs `project.id in [2, 3]`:
+// common `project.id in [2]`, only left `project.id in [1]`, only right `project.id in [3]`
+type Diff struct {
+	Common    []*Expression `json:"common"`
+	OnlyLeft  []*Expression `json:"only_left"`
+	OnlyRight []*Expression `json:"only_right"`
+}
+
+// Equal returns true if the two expressions grant the same permissions
+func (d *Diff) Equal() bool {
+	return len(d.OnlyLeft) == 0 && len(d.OnlyRight) == 0
+}
+
+// grant is the smallest unit to compare, the value is only set for the split `in`
+type grant struct {
+	expr  *Expression
+	field string
+	value interface{}
+	key   string
+}
+
+// DiffExpressions compares the two expressions after normalized
+func DiffExpressions(left, right *Expression) *Diff {
+	lg := grants(Normalize(left))
+	rg := grants(Normalize(right))
+
+	inRight := map[string]bool{}
+	for _, g := range rg {
+		inRight[g.key] = true
+	}
+	inLeft := map[string]bool{}
+	for _, g := range lg {
+		inLeft[g.key] = true
+	}
+
+	var common, onlyLeft, onlyRight []grant
+	for _, g := range lg {
+		if inRight[g.key] {
+			common = append(common, g)
+		} else {
+			onlyLeft = append(onlyLeft, g)
+		}
+	}
+	for _, g := range rg {
+		if !inLeft[g.key] {
+			onlyRight = append(onlyRight, g)
+		}
+	}
+
+	return &Diff{
+		Common:    mergeGrants(common),
+		OnlyLeft:  mergeGrants(onlyLeft),
+		OnlyRight: mergeGrants(onlyRight),
+	}
+}
+
+// grants splits the normalized expression into the grants
+func grants(e *Expression) []grant {
+	operands := []*Expression{e}
+	if e.IsLogical() && e.Op == OpOr {
+		operands = e.Content
+	}
+
+	result := make([]grant, 0, len(operands))
+	for _, o := range operands {
+		switch o.Op {
+		case OpEq:
+			result = append(result, valueGrant(o.Field, o.Value))
+		case OpIn:
+			values, _ := o.Value.([]interface{})
+			for _, v := range values {
+				result = append(result, valueGrant(o.Field, v))
+			}
+		default:
+			result = append(result, grant{expr: o, key: o.String()})
+		}
+	}
+	return result
+}
+
+func valueGrant(field string, value interface{}) grant {
+	return grant{field: field, value: value, key: field + " in " + formatValue(value)}
+}
+
+// mergeGrants merges the split values of the same field into one `in`, and sorts the grants
+func mergeGrants(gs []grant) []*Expression {
+	result := []*Expression{}
+	fieldValues := map[string][]interface{}{}
+	for _, g := range gs {
+		if g.expr != nil {
+			result = append(result, g.expr)
+			continue
+		}
+		if _, ok := fieldValues[g.field]; !ok {
+			result = append(result, &Expression{Op: OpIn, Field: g.field})
+		}
+		fieldValues[g.field] = append(fieldValues[g.field], g.value)
+	}
+
+	for _, e := range result {
+		if values, ok := fieldValues[e.Field]; ok && e.Op == OpIn && e.Value == nil {
+			sortValues(values)
+			e.Value = values
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+	return result
+}
@@ -0,0 +1,115 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package expression
+
+import (
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) *Expression {
+	t.Helper()
+	e, err := Parse([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func texts(es []*Expression) []string {
+	result := make([]string, 0, len(es))
+	for _, e := range es {
+		result = append(result, e.String())
+	}
+	return result
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		expr string
+		want string
+	}{
+		{
+			`{"op": "in", "field": "project.id", "value": ["14", "8", "14", "100"]}`,
+			`project.id in [8, 14, 100]`,
+		},
+		{
+			`{"op": "OR", "content": [
+				{"op": "eq", "field": "b.id", "value": "1"},
+				{"op": "OR", "content": [{"op": "eq", "field": "a.id", "value": "1"}]},
+				{"op": "eq", "field": "b.id", "value": "1"}
+			]}`,
+			`a.id eq 1 OR b.id eq 1`,
+		},
+		{
+			`{"op": "AND", "content": [{"op": "any", "field": "host.id"}, {"op": "eq", "field": "host.id", "value": "1"}]}`,
+			`host.id eq 1`,
+		},
+		{
+			`{"op": "OR", "content": [{"op": "any", "field": "host.id"}, {"op": "eq", "field": "host.id", "value": "1"}]}`,
+			`host.id any`,
+		},
+		{
+			`{}`,
+			``,
+		},
+	}
+
+	for _, c := range cases {
+		if got := Normalize(mustParse(t, c.expr)).String(); got != c.want {
+			t.Errorf("Normalize(%s) = %s, want %s", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestDiffExpressions(t *testing.T) {
+	left := mustParse(t, `{"op": "OR", "content": [
+		{"op": "in", "field": "project.id", "value": ["1", "2"]},
+		{"op": "starts_with", "field": "host._bk_iam_path_", "value": "/biz,1/"}
+	]}`)
+	right := mustParse(t, `{"op": "OR", "content": [
+		{"op": "eq", "field": "project.id", "value": "3"},
+		{"op": "in", "field": "project.id", "value": ["2"]}
+	]}`)
+
+	d := DiffExpressions(left, right)
+	if d.Equal() {
+		t.Fatal("should not be equal")
+	}
+
+	check := func(name string, got []*Expression, want ...string) {
+		t.Helper()
+		s := texts(got)
+		if len(s) != len(want) {
+			t.Errorf("%s = %v, want %v", name, s, want)
+			return
+		}
+		for i := range want {
+			if s[i] != want[i] {
+				t.Errorf("%s = %v, want %v", name, s, want)
+				return
+			}
+		}
+	}
+	check("common", d.Common, "project.id in [2]")
+	check("only left", d.OnlyLeft, "host._bk_iam_path_ starts_with /biz,1/", "project.id in [1]")
+	check("only right", d.OnlyRight, "project.id in [3]")
+
+	// the order and duplicates make no difference
+	d = DiffExpressions(
+		mustParse(t, `{"op": "in", "field": "project.id", "value": ["2", "1", "1"]}`),
+		mustParse(t, `{"op": "OR", "content": [{"op": "eq", "field": "project.id", "value": "1"},
+			{"op": "in", "field": "project.id", "value": ["2"]}]}`),
+	)
+	if !d.Equal() {
+		t.Errorf("should be equal, got %+v", d)
+	}
+}
@@ -0,0 +1,305 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Resource is the attributes of the resources, the key is `{resource_type}.{attribute}`, e.g.
+// {"project.id": "1", "host._bk_iam_path_": ["/biz,1/set,2/", "/biz,1/set,3/"]}
+// the value of a multi-value attribute is a list
+type Resource map[string]interface{}
+
+// Set sets the attribute, the attribute becomes a list if set multiple times
+func (r Resource) Set(key string, value interface{}) {
+	old, ok := r[key]
+	if !ok {
+		r[key] = value
+		return
+	}
+
+	if l, ok := old.([]interface{}); ok {
+		r[key] = append(l, value)
+		return
+	}
+	r[key] = []interface{}{old, value}
+}
+
+// Trace is the evaluation result of an expression and its sub-expressions
+type Trace struct {
+	Expression string   `json:"expression"`
+	Op         string   `json:"op"`
+	Matched    bool     `json:"matched"`
+	Reason     string   `json:"reason,omitempty"`
+	Children   []*Trace `json:"children,omitempty"`
+}
+
+// String returns the readable tree of the trace, e.g.
+//
+//	✓ OR
+//	  ✗ project.id in [1, 2]  (project.id=3)
+//	  ✓ project.id eq 3  (project.id=3)
+func (t *Trace) String() string {
+	var sb strings.Builder
+	t.write(&sb, 0)
+	return sb.String()
+}
+
+func (t *Trace) write(sb *strings.Builder, depth int) {
+	mark := "✗"
+	if t.Matched {
+		mark = "✓"
+	}
+
+	sb.WriteString(strings.Repeat("  ", depth))
+	sb.WriteString(mark)
+	sb.WriteString(" ")
+	if len(t.Children) > 0 || t.Op == OpAnd || t.Op == OpOr {
+		sb.WriteString(t.Op)
+	} else {
+		sb.WriteString(t.Expression)
+	}
+	if t.Reason != "" {
+		sb.WriteString("  (" + t.Reason + ")")
+	}
+	sb.WriteString("\n")
+
+	for _, c := range t.Children {
+		c.write(sb, depth+1)
+	}
+}
+
+// MatchedLeaves returns the matched leaf expressions, which grant the permission
+func (t *Trace) MatchedLeaves() []*Trace {
+	if !t.Matched {
+		return nil
+	}
+	if len(t.Children) == 0 {
+		return []*Trace{t}
+	}
+
+	leaves := make([]*Trace, 0)
+	for _, c := range t.Children {
+		leaves = append(leaves, c.MatchedLeaves()...)
+	}
+	return leaves
+}
+
+// Eval evaluates the expression against the resource, returns the result and the trace
+func Eval(e *Expression, r Resource) (bool, *Trace) {
+	t := eval(e, r)
+	return t.Matched, t
+}
+
+func eval(e *Expression, r Resource) *Trace {
+	t := &Trace{
+		Expression: e.String(),
+		Op:         e.Op,
+	}
+
+	if e.IsLogical() {
+		t.Op = strings.ToUpper(e.Op)
+		t.Children = make([]*Trace, 0, len(e.Content))
+
+		isAnd := t.Op == OpAnd
+		// NOTE: AND with empty content is true, OR with empty content(no policy) is false
+		t.Matched = isAnd
+		for _, c := range e.Content {
+			ct := eval(c, r)
+			t.Children = append(t.Children, ct)
+			if isAnd && !ct.Matched {
+				t.Matched = false
+			}
+			if !isAnd && ct.Matched {
+				t.Matched = true
+			}
+		}
+		if len(e.Content) == 0 && !isAnd {
+			t.Reason = "no policy"
+		}
+		return t
+	}
+
+	if e.Op == OpAny {
+		t.Matched = true
+		return t
+	}
+
+	value, ok := r[e.Field]
+	if !ok {
+		t.Reason = fmt.Sprintf("attribute %s missing", e.Field)
+		return t
+	}
+	t.Reason = fmt.Sprintf("%s=%s", e.Field, formatValue(value))
+
+	matched, err := evalOperator(e.Op, value, e.Value)
+	if err != nil {
+		t.Reason = fmt.Sprintf("%s, %s", t.Reason, err.Error())
+		return t
+	}
+	t.Matched = matched
+	return t
+}
+
+type operatorFunc func(attr, value interface{}) (bool, error)
+
+var operators = map[string]struct {
+	// the positive function, for the negative operators(not_xxx), it's the function of the positive one
+	fn       operatorFunc
+	negative bool
+	// the attribute itself is a list, e.g. contains
+	listAttr bool
+}{
+	OpEq:             {fn: equal},
+	OpNotEq:          {fn: equal, negative: true},
+	OpIn:             {fn: in},
+	OpNotIn:          {fn: in, negative: true},
+	OpContains:       {fn: contains, listAttr: true},
+	OpNotContains:    {fn: contains, negative: true, listAttr: true},
+	OpStartsWith:     {fn: stringFunc(strings.HasPrefix)},
+	OpNotStartsWith:  {fn: stringFunc(strings.HasPrefix), negative: true},
+	OpEndsWith:       {fn: stringFunc(strings.HasSuffix)},
+	OpNotEndsWith:    {fn: stringFunc(strings.HasSuffix), negative: true},
+	OpStringContains: {fn: stringFunc(strings.Contains)},
+	OpLt:             {fn: numericFunc(func(a, b float64) bool { return a < b })},
+	OpLte:            {fn: numericFunc(func(a, b float64) bool { return a <= b })},
+	OpGt:             {fn: numericFunc(func(a, b float64) bool { return a > b })},
+	OpGte:            {fn: numericFunc(func(a, b float64) bool { return a >= b })},
+	OpAny:            {fn: func(attr, value interface{}) (bool, error) { return true, nil }},
+}
+
+// evalOperator evaluates the operator; if the attribute has multiple values,
+// the positive operator matches if any value matches, the negative operator matches if no value matches
+func evalOperator(op string, attr, value interface{}) (bool, error) {
+	o, ok := operators[op]
+	if !ok {
+		return false, fmt.Errorf("unsupported operator `%s`", op)
+	}
+
+	if o.listAttr {
+		matched, err := o.fn(attr, value)
+		if err != nil {
+			return false, err
+		}
+		return matched != o.negative, nil
+	}
+
+	attrs, ok := attr.([]interface{})
+	if !ok {
+		attrs = []interface{}{attr}
+	}
+
+	for _, a := range attrs {
+		matched, err := o.fn(a, value)
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return !o.negative, nil
+		}
+	}
+	return o.negative, nil
+}
+
+// equal compares as numbers only if both are json numbers, otherwise the strings exactly,
+// e.g. "1" eq 1 is true, but "01" eq "1" and "1e2" eq "100" are false, the string ids never lose precision
+func equal(attr, value interface{}) (bool, error) {
+	if isNumber(attr) && isNumber(value) {
+		a, _ := toFloat(attr)
+		b, _ := toFloat(value)
+		return a == b, nil
+	}
+	return toString(attr) == toString(value), nil
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case float64, int, int64:
+		return true
+	}
+	return false
+}
+
+func in(attr, value interface{}) (bool, error) {
+	values, ok := value.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("the value of in should be a list")
+	}
+	for _, v := range values {
+		if matched, _ := equal(attr, v); matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func contains(attr, value interface{}) (bool, error) {
+	attrs, ok := attr.([]interface{})
+	if !ok {
+		attrs = []interface{}{attr}
+	}
+	for _, a := range attrs {
+		if matched, _ := equal(a, value); matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func stringFunc(fn func(s, sub string) bool) operatorFunc {
+	return func(attr, value interface{}) (bool, error) {
+		return fn(toString(attr), toString(value)), nil
+	}
+}
+
+func numericFunc(fn func(a, b float64) bool) operatorFunc {
+	return func(attr, value interface{}) (bool, error) {
+		a, err := toFloat(attr)
+		if err != nil {
+			return false, fmt.Errorf("attribute %v is not a number", attr)
+		}
+		b, err := toFloat(value)
+		if err != nil {
+			return false, fmt.Errorf("value %v is not a number", value)
+		}
+		return fn(a, b), nil
+	}
+}
+
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case int:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case string:
+		return strconv.ParseFloat(x, 64)
+	default:
+		return 0, fmt.Errorf("not a number")
+	}
+}
@@ -0,0 +1,150 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package expression
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"translated", `{"op": "in", "field": "project.id", "value": ["1", "2"]}`, "project.id in [1, 2]"},
+		{"debug", `{"expression": {"op": "eq", "field": "project.id", "value": "1"}}`, "project.id eq 1"},
+		{"cache", `{"expressions": [{"pk": 1, "expression": "{\"op\": \"eq\", \"field\": \"a.id\", \"value\": \"1\"}"},
+			{"pk": 2, "Expression": {"op": "eq", "field": "b.id", "value": "2"}}]}`, "a.id eq 1 OR b.id eq 2"},
+		{"empty", `{}`, ""},
+		{"any without field", `{"op": "any"}`, "any"},
+		{"database", `[{"system": "bk_sops", "type": "project", "expression": {"StringEquals": {"id": ["1"]}}}]`,
+			"project.id eq 1"},
+		{"database multi values", `[{"type": "project", "expression": {"StringEquals": {"id": ["1", "2"]}}}]`,
+			"project.id in [1, 2]"},
+		{"database prefix", `[{"type": "host", "expression": {"StringPrefix": {"_bk_iam_path_": ["/a/", "/b/"]}}}]`,
+			"host._bk_iam_path_ starts_with /a/ OR host._bk_iam_path_ starts_with /b/"},
+		{"database and", `[{"type": "host", "expression": {"AND": {"content": [
+			{"NumericGt": {"level": [1]}}, {"Any": {"id": []}}]}}}]`, "host.level gt 1 AND host.id any"},
+		{"database types", `[{"type": "a", "expression": {"Bool": {"on": [true]}}},
+			{"type": "b", "expression": {"StringContains": {"name": ["x"]}}}]`, "a.on eq true AND b.name string_contains x"},
+	}
+	for _, c := range cases {
+		e, err := Parse([]byte(c.data))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if e.String() != c.want {
+			t.Errorf("%s: got `%s`, want `%s`", c.name, e.String(), c.want)
+		}
+	}
+
+	for _, data := range []string{
+		`not json`,
+		`1`,
+		`{"a": 1}`,
+		`{"op": "unknown", "field": "a.id"}`,
+		`{"op": "eq", "value": "1"}`,
+		`[{"type": "a", "expression": {"NotSupported": {"id": ["1"]}}}]`,
+		`[{"type": "a"}]`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Parse(%s) should fail", data)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	cases := []struct {
+		expr     string
+		resource Resource
+		want     bool
+	}{
+		{`{"op": "eq", "field": "a.id", "value": "1"}`, Resource{"a.id": "1"}, true},
+		{`{"op": "eq", "field": "a.id", "value": "1"}`, Resource{"a.id": "2"}, false},
+		{`{"op": "not_eq", "field": "a.id", "value": "1"}`, Resource{"a.id": "2"}, true},
+		{`{"op": "in", "field": "a.id", "value": ["1", "2"]}`, Resource{"a.id": "2"}, true},
+		{`{"op": "in", "field": "a.id", "value": ["1", "2"]}`, Resource{"a.id": "3"}, false},
+		{`{"op": "not_in", "field": "a.id", "value": ["1", "2"]}`, Resource{"a.id": "3"}, true},
+		{`{"op": "contains", "field": "a.tags", "value": "x"}`, Resource{"a.tags": []interface{}{"y", "x"}}, true},
+		{`{"op": "not_contains", "field": "a.tags", "value": "x"}`, Resource{"a.tags": []interface{}{"y"}}, true},
+		{`{"op": "starts_with", "field": "a.path", "value": "/biz,1/"}`, Resource{"a.path": "/biz,1/set,2/"}, true},
+		{`{"op": "not_starts_with", "field": "a.path", "value": "/biz,1/"}`, Resource{"a.path": "/biz,2/"}, true},
+		{`{"op": "ends_with", "field": "a.name", "value": ".log"}`, Resource{"a.name": "x.log"}, true},
+		{`{"op": "not_ends_with", "field": "a.name", "value": ".log"}`, Resource{"a.name": "x.log"}, false},
+		{`{"op": "string_contains", "field": "a.name", "value": "ab"}`, Resource{"a.name": "cabd"}, true},
+		{`{"op": "lt", "field": "a.level", "value": 3}`, Resource{"a.level": "2"}, true},
+		{`{"op": "lte", "field": "a.level", "value": 3}`, Resource{"a.level": 3.0}, true},
+		{`{"op": "gt", "field": "a.level", "value": 3}`, Resource{"a.level": "3"}, false},
+		{`{"op": "gte", "field": "a.level", "value": 3}`, Resource{"a.level": "3"}, true},
+		{`{"op": "gt", "field": "a.level", "value": 3}`, Resource{"a.level": "high"}, false},
+		{`{"op": "any", "field": "a.id"}`, Resource{}, true},
+		{`{"op": "eq", "field": "a.id", "value": "1"}`, Resource{}, false},
+
+		// the multi-value attribute: the positive operator matches any, the negative one matches none
+		{`{"op": "eq", "field": "a.path", "value": "/1/"}`, Resource{"a.path": []interface{}{"/2/", "/1/"}}, true},
+		{`{"op": "not_eq", "field": "a.path", "value": "/1/"}`, Resource{"a.path": []interface{}{"/2/", "/1/"}}, false},
+		{`{"op": "starts_with", "field": "a.path", "value": "/1"}`, Resource{"a.path": []interface{}{"/2/", "/1/"}}, true},
+		{`{"op": "not_in", "field": "a.id", "value": ["1"]}`, Resource{"a.id": []interface{}{"2", "3"}}, true},
+
+		// AND is all, OR is any
+		{`{"op": "AND", "content": [{"op": "eq", "field": "a.id", "value": "1"},
+			{"op": "eq", "field": "b.id", "value": "2"}]}`, Resource{"a.id": "1", "b.id": "2"}, true},
+		{`{"op": "AND", "content": [{"op": "eq", "field": "a.id", "value": "1"},
+			{"op": "eq", "field": "b.id", "value": "2"}]}`, Resource{"a.id": "1", "b.id": "3"}, false},
+		{`{"op": "OR", "content": [{"op": "eq", "field": "a.id", "value": "1"},
+			{"op": "eq", "field": "b.id", "value": "2"}]}`, Resource{"a.id": "0", "b.id": "2"}, true},
+		{`{"op": "AND", "content": []}`, Resource{}, true},
+		{`{"op": "OR", "content": []}`, Resource{}, false},
+
+		// the numbers are compared as numbers only if both are json numbers, the strings exactly
+		{`{"op": "eq", "field": "a.id", "value": 1}`, Resource{"a.id": "1"}, true},
+		{`{"op": "eq", "field": "a.id", "value": 1}`, Resource{"a.id": 1.0}, true},
+		{`{"op": "eq", "field": "a.id", "value": "1"}`, Resource{"a.id": "01"}, false},
+		{`{"op": "eq", "field": "a.id", "value": "100"}`, Resource{"a.id": "1e2"}, false},
+		{`{"op": "eq", "field": "a.id", "value": 100}`, Resource{"a.id": "1e2"}, false},
+		{`{"op": "in", "field": "a.id", "value": ["1", "2"]}`, Resource{"a.id": "1.0"}, false},
+		{`{"op": "eq", "field": "a.id", "value": "9007199254740993"}`, Resource{"a.id": "9007199254740992"}, false},
+		{`{"op": "contains", "field": "a.ids", "value": "1"}`, Resource{"a.ids": []interface{}{"01"}}, false},
+		{`{"op": "eq", "field": "a.on", "value": true}`, Resource{"a.on": "true"}, true},
+	}
+	for _, c := range cases {
+		e := mustParse(t, c.expr)
+		if got, trace := Eval(e, c.resource); got != c.want {
+			t.Errorf("Eval(%s, %v) = %t, want %t\n%s", c.expr, c.resource, got, c.want, trace)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	e := mustParse(t, `{"op": "OR", "content": [
+		{"op": "in", "field": "project.id", "value": ["1", "2"]},
+		{"op": "eq", "field": "project.id", "value": "3"},
+		{"op": "gt", "field": "project.level", "value": 1}
+	]}`)
+	matched, trace := Eval(e, Resource{"project.id": "3", "project.level": "x"})
+	if !matched {
+		t.Fatal("should match")
+	}
+
+	want := "✓ OR\n" +
+		"  ✗ project.id in [1, 2]  (project.id=3)\n" +
+		"  ✓ project.id eq 3  (project.id=3)\n" +
+		"  ✗ project.level gt 1  (project.level=x, attribute x is not a number)\n"
+	if trace.String() != want {
+		t.Errorf("got trace\n%s\nwant\n%s", trace, want)
+	}
+	if leaves := trace.MatchedLeaves(); len(leaves) != 1 || leaves[0].Expression != "project.id eq 3" {
+		t.Errorf("unexpected matched leaves %d", len(leaves))
+	}
+}
@@ -0,0 +1,339 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package expression
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// the operators of the IAM backend expression
+const (
+	OpAnd = "AND"
+	OpOr  = "OR"
+
+	OpEq             = "eq"
+	OpNotEq          = "not_eq"
+	OpIn             = "in"
+	OpNotIn          = "not_in"
+	OpContains       = "contains"
+	OpNotContains    = "not_contains"
+	OpStartsWith     = "starts_with"
+	OpNotStartsWith  = "not_starts_with"
+	OpEndsWith       = "ends_with"
+	OpNotEndsWith    = "not_ends_with"
+	OpLt             = "lt"
+	OpLte            = "lte"
+	OpGt             = "gt"
+	OpGte            = "gte"
+	OpAny            = "any"
+	OpStringContains = "string_contains"
+)
+
+// Expression is the expression returned by IAM backend(the `query policy` result), e.g.
+// {"op": "OR", "content": [{"field": "project.id", "op": "in", "value": ["1", "2"]}]}
+type Expression struct {
+	Op      string        `json:"op"`
+	Field   string        `json:"field,omitempty"`
+	Value   interface{}   `json:"value,omitempty"`
+	Content []*Expression `json:"content,omitempty"`
+}
+
+// IsLogical returns true if the op is AND/OR
+func (e *Expression) IsLogical() bool {
+	op := strings.ToUpper(e.Op)
+	return op == OpAnd || op == OpOr
+}
+
+// String returns the readable text of the expression, e.g. `project.id in [1, 2]`
+func (e *Expression) String() string {
+	if e.IsLogical() {
+		parts := make([]string, 0, len(e.Content))
+		for _, c := range e.Content {
+			s := c.String()
+			if c.IsLogical() && len(c.Content) > 1 {
+				s = "(" + s + ")"
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, " "+strings.ToUpper(e.Op)+" ")
+	}
+	if e.Op == OpAny {
+		if e.Field == "" {
+			return "any"
+		}
+		return fmt.Sprintf("%s any", e.Field)
+	}
+	return fmt.Sprintf("%s %s %s", e.Field, e.Op, formatValue(e.Value))
+}
+
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case []interface{}:
+		parts := make([]string, 0, len(x))
+		for _, i := range x {
+			parts = append(parts, formatValue(i))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case string:
+		return x
+	case nil:
+		return "null"
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+// Parse parses the expression, supports:
+//  1. the output of `query policy`: {"op": "in", "field": "project.id", "value": [...]}
+//  2. the output of `query policy` with debug: {"expression": {...}, ...}
+//  3. the output of `cache expression`: {"expressions": [{"pk": 1, "expression": "[...]"}]}, all expressions are OR-ed
+//  4. the expression stored in cache/database:
+//     [{"system": "bk_sops", "type": "project", "expression": {"StringEquals": {"id": ["1"]}}}]
+func Parse(data []byte) (*Expression, error) {
+	var raw interface{}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	return FromInterface(raw)
+}
+
+// FromInterface parses the expression from the decoded json, the formats supported are the same as Parse
+func FromInterface(raw interface{}) (*Expression, error) {
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		if _, ok := v["op"]; ok {
+			return fromTranslated(v)
+		}
+		if expressions, ok := v["expressions"]; ok {
+			return fromCacheExpressions(expressions)
+		}
+		if expression, ok := v["expression"]; ok {
+			return FromInterface(expression)
+		}
+		// the empty expression, means no permission
+		if len(v) == 0 {
+			return &Expression{Op: OpOr, Content: []*Expression{}}, nil
+		}
+		return nil, fmt.Errorf("unknown expression format, should contain op/expression/expressions")
+	case []interface{}:
+		return fromResourceExpressions(v)
+	case string:
+		return Parse([]byte(v))
+	default:
+		return nil, fmt.Errorf("unknown expression format %T", raw)
+	}
+}
+
+func fromTranslated(m map[string]interface{}) (*Expression, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var e Expression
+	err = json.Unmarshal(b, &e)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+	return &e, e.validate()
+}
+
+func (e *Expression) validate() error {
+	if e.IsLogical() {
+		for _, c := range e.Content {
+			if err := c.validate(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, ok := operators[e.Op]; !ok {
+		return fmt.Errorf("unsupported operator `%s`", e.Op)
+	}
+	// NOTE: the any without field means the action is not related to any resource type
+	if e.Field == "" && e.Op != OpAny {
+		return fmt.Errorf("field required of operator `%s`", e.Op)
+	}
+	return nil
+}
+
+func fromCacheExpressions(raw interface{}) (*Expression, error) {
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expressions should be a list")
+	}
+
+	or := &Expression{Op: OpOr, Content: make([]*Expression, 0, len(list))}
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expression item should be an object")
+		}
+
+		// NOTE: the key may be `expression` or `Expression`
+		var exprRaw interface{}
+		for k, v := range m {
+			if strings.EqualFold(k, "expression") {
+				exprRaw = v
+			}
+		}
+		if exprRaw == nil {
+			return nil, fmt.Errorf("expression item without expression")
+		}
+
+		e, err := FromInterface(exprRaw)
+		if err != nil {
+			return nil, err
+		}
+		or.Content = append(or.Content, e)
+	}
+	return or, nil
+}
+
+// the operators of the expression stored in cache/database
+var conditionOperators = map[string]struct {
+	single string
+	multi  string
+}{
+	"StringEquals":   {OpEq, OpIn},
+	"StringPrefix":   {OpStartsWith, OpStartsWith},
+	"StringContains": {OpStringContains, OpStringContains},
+	"NumericEquals":  {OpEq, OpIn},
+	"NumericGt":      {OpGt, OpGt},
+	"NumericGte":     {OpGte, OpGte},
+	"NumericLt":      {OpLt, OpLt},
+	"NumericLte":     {OpLte, OpLte},
+	"Bool":           {OpEq, OpEq},
+}
+
+// fromResourceExpressions converts the expression stored in cache/database,
+// the resource expressions of different resource types are AND-ed
+func fromResourceExpressions(list []interface{}) (*Expression, error) {
+	and := &Expression{Op: OpAnd, Content: make([]*Expression, 0, len(list))}
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("resource expression should be an object")
+		}
+
+		_type, _ := m["type"].(string)
+		condition, ok := m["expression"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("resource expression of type `%s` without expression", _type)
+		}
+
+		e, err := fromCondition(_type, condition)
+		if err != nil {
+			return nil, err
+		}
+		and.Content = append(and.Content, e)
+	}
+
+	if len(and.Content) == 1 {
+		return and.Content[0], nil
+	}
+	return and, nil
+}
+
+// fromCondition converts the condition, e.g. {"OR": {"content": [{"StringEquals": {"id": ["1"]}}]}}
+func fromCondition(_type string, condition map[string]interface{}) (*Expression, error) {
+	if len(condition) != 1 {
+		return nil, fmt.Errorf("invalid condition, should have only one operator: %v", condition)
+	}
+
+	var (
+		op   string
+		body interface{}
+	)
+	// NOTE: only one key
+	for k, v := range condition {
+		op, body = k, v
+	}
+
+	value, ok := body.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid condition of operator `%s`", op)
+	}
+
+	upperOp := strings.ToUpper(op)
+	if upperOp != OpAnd && upperOp != OpOr {
+		return fromFieldCondition(_type, op, value)
+	}
+
+	content, _ := value["content"].([]interface{})
+	e := &Expression{Op: upperOp, Content: make([]*Expression, 0, len(content))}
+	for _, c := range content {
+		cm, ok := c.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid condition content of operator `%s`", op)
+		}
+		ce, err := fromCondition(_type, cm)
+		if err != nil {
+			return nil, err
+		}
+		e.Content = append(e.Content, ce)
+	}
+	return e, nil
+}
+
+// fromFieldCondition converts the condition of the fields, e.g. {"StringEquals": {"id": ["1", "2"]}}
+func fromFieldCondition(_type, op string, value map[string]interface{}) (*Expression, error) {
+	fields := make([]string, 0, len(value))
+	for f := range value {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	exprs := make([]*Expression, 0, len(fields))
+	for _, f := range fields {
+		field := f
+		if _type != "" {
+			field = _type + "." + f
+		}
+		values, _ := value[f].([]interface{})
+
+		if op == "Any" {
+			exprs = append(exprs, &Expression{Op: OpAny, Field: field, Value: values})
+			continue
+		}
+
+		mapping, ok := conditionOperators[op]
+		if !ok {
+			return nil, fmt.Errorf("unsupported condition operator `%s`", op)
+		}
+
+		switch {
+		case len(values) == 1:
+			exprs = append(exprs, &Expression{Op: mapping.single, Field: field, Value: values[0]})
+		case mapping.multi == OpIn:
+			exprs = append(exprs, &Expression{Op: OpIn, Field: field, Value: values})
+		default:
+			// the operators not support multiple values are OR-ed, e.g. StringPrefix
+			or := &Expression{Op: OpOr, Content: make([]*Expression, 0, len(values))}
+			for _, v := range values {
+				or.Content = append(or.Content, &Expression{Op: mapping.multi, Field: field, Value: v})
+			}
+			exprs = append(exprs, or)
+		}
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return &Expression{Op: OpAnd, Content: exprs}, nil
+}
@@ -0,0 +1,135 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package expression
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the ops whose value is a list, the values are deduplicated and sorted
+var listOps = map[string]bool{
+	OpIn:          true,
+	OpNotIn:       true,
+	OpContains:    true,
+	OpNotContains: true,
+}
+
+// Normalize returns the normalized copy of the expression, the semantically equal expressions are normalized to
+// the same one:
+//   - the nested AND/OR of the same op are flattened, the operands are deduplicated and sorted
+//   - AND/OR with only one operand is replaced by the operand
+//   - OR with `any` is `any`, `any` in AND is dropped
+//   - the values of in/not_in/contains/not_contains are deduplicated and sorted
+func Normalize(e *Expression) *Expression {
+	if e == nil {
+		return &Expression{Op: OpOr, Content: []*Expression{}}
+	}
+	if !e.IsLogical() {
+		return normalizeCondition(e)
+	}
+
+	op := strings.ToUpper(e.Op)
+	seen := map[string]bool{}
+	content := make([]*Expression, 0, len(e.Content))
+
+	var add func(c *Expression)
+	add = func(c *Expression) {
+		// flatten the operands of the same op
+		if c.IsLogical() && strings.ToUpper(c.Op) == op {
+			for _, cc := range c.Content {
+				add(Normalize(cc))
+			}
+			return
+		}
+
+		key := c.String()
+		if !seen[key] {
+			seen[key] = true
+			content = append(content, c)
+		}
+	}
+	for _, c := range e.Content {
+		add(Normalize(c))
+	}
+
+	var anys []*Expression
+	conditions := make([]*Expression, 0, len(content))
+	for _, c := range content {
+		if c.Op == OpAny {
+			anys = append(anys, c)
+		} else {
+			conditions = append(conditions, c)
+		}
+	}
+	switch {
+	case op == OpOr && len(anys) > 0:
+		// NOTE: keep all the `any` of different resource types
+		content = anys
+	case op == OpAnd && len(anys) > 0 && len(conditions) > 0:
+		content = conditions
+	}
+
+	sort.SliceStable(content, func(i, j int) bool {
+		return content[i].String() < content[j].String()
+	})
+
+	// the empty OR is kept, means no permission
+	if len(content) == 1 {
+		return content[0]
+	}
+	return &Expression{Op: op, Content: content}
+}
+
+func normalizeCondition(e *Expression) *Expression {
+	n := &Expression{Op: strings.ToLower(e.Op), Field: e.Field, Value: e.Value}
+	if !listOps[n.Op] {
+		return n
+	}
+
+	values, ok := e.Value.([]interface{})
+	if e.Value == nil {
+		values, ok = []interface{}{}, true
+	}
+	if !ok {
+		// a single value, e.g. {"op": "in", "value": "1"}
+		n.Value = []interface{}{e.Value}
+		return n
+	}
+
+	seen := map[string]bool{}
+	deduped := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		key := formatValue(v)
+		if !seen[key] {
+			seen[key] = true
+			deduped = append(deduped, v)
+		}
+	}
+	sortValues(deduped)
+	n.Value = deduped
+	return n
+}
+
+// sortValues sorts the values, numerically if both are numbers, e.g. the ids ["8", "14", "100"]
+func sortValues(values []interface{}) {
+	sort.SliceStable(values, func(i, j int) bool {
+		a, b := formatValue(values[i]), formatValue(values[j])
+		fa, errA := strconv.ParseFloat(a, 64)
+		fb, errB := strconv.ParseFloat(b, 64)
+		if errA == nil && errB == nil {
+			return fa < fb
+		}
+		return a < b
+	})
+}
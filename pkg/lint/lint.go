@@ -0,0 +1,153 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+// Package lint validates the permission model of the system with a set of rules,
+// the rules are pluggable, add a new one by Register in the init of a file in this package, e.g.
+//
+//	func init() {
+//		Register(NewRule("action-id-prefix", SeverityWarning, "the action id should start with the system id",
+//			func(m *model.SystemModel, r *Reporter) {
+//				for _, a := range m.Actions {
+//					if !strings.HasPrefix(a.ID, m.System.ID) {
+//						r.Report(actionPath(a.ID, "id"), "`%s` should start with `%s`", a.ID, m.System.ID)
+//					}
+//				}
+//			}))
+//	}
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"bk-iam-cli/pkg/model"
+)
+
+// Severity is the level of the finding, the model with error findings should not be registered
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+var severityOrder = map[Severity]int{SeverityError: 0, SeverityWarning: 1, SeverityInfo: 2}
+
+// Finding is a mistake found by the rule
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	// where the mistake is, e.g. actions[flow_view].related_actions
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Rule checks the model and reports the findings
+type Rule interface {
+	Name() string
+	Severity() Severity
+	Description() string
+	Check(m *model.SystemModel, r *Reporter)
+}
+
+// Reporter collects the findings of a rule
+type Reporter struct {
+	rule     string
+	severity Severity
+	findings []Finding
+}
+
+// Report adds a finding with the severity of the rule
+func (r *Reporter) Report(path, format string, args ...interface{}) {
+	r.ReportWithSeverity(r.severity, path, format, args...)
+}
+
+// ReportWithSeverity adds a finding with the severity, e.g. a rule reports both errors and warnings
+func (r *Reporter) ReportWithSeverity(severity Severity, path, format string, args ...interface{}) {
+	r.findings = append(r.findings, Finding{
+		Rule:     r.rule,
+		Severity: severity,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+type funcRule struct {
+	name        string
+	severity    Severity
+	description string
+	check       func(m *model.SystemModel, r *Reporter)
+}
+
+func (f funcRule) Name() string        { return f.name }
+func (f funcRule) Severity() Severity  { return f.severity }
+func (f funcRule) Description() string { return f.description }
+
+func (f funcRule) Check(m *model.SystemModel, r *Reporter) {
+	f.check(m, r)
+}
+
+// NewRule returns a rule from the check func
+func NewRule(name string, severity Severity, description string, check func(m *model.SystemModel, r *Reporter)) Rule {
+	return funcRule{name: name, severity: severity, description: description, check: check}
+}
+
+var registry []Rule
+
+// Register adds the rule to the default rule set, panic if the name is duplicated
+func Register(rule Rule) {
+	for _, r := range registry {
+		if r.Name() == rule.Name() {
+			panic(fmt.Sprintf("lint rule %s registered twice", rule.Name()))
+		}
+	}
+	registry = append(registry, rule)
+}
+
+// Rules returns all the registered rules
+func Rules() []Rule {
+	rules := make([]Rule, len(registry))
+	copy(rules, registry)
+	return rules
+}
+
+// Lint runs the rules over the model, the findings are sorted by severity, rule and path
+func Lint(m *model.SystemModel, rules []Rule) []Finding {
+	findings := []Finding{}
+	for _, rule := range rules {
+		r := &Reporter{rule: rule.Name(), severity: rule.Severity()}
+		rule.Check(m, r)
+		findings = append(findings, r.findings...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return severityOrder[a.Severity] < severityOrder[b.Severity]
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Path < b.Path
+	})
+	return findings
+}
+
+// HasErrors returns true if any finding is error
+func HasErrors(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
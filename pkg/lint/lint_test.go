@@ -0,0 +1,93 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"bk-iam-cli/pkg/model"
+)
+
+const brokenModel = `{
+  "system": {"id": "demo"},
+  "actions": [
+    {"id": "host_view", "name": "view", "name_en": "view",
+     "related_resource_types": [
+       {"system_id": "demo", "id": "host", "related_instance_selections": [{"id": "host_list"}, {"id": "unknown"}]},
+       {"system_id": "demo", "id": "switch"},
+       {"system_id": "bk_cmdb", "id": "biz"}
+     ],
+     "related_actions": ["host_edit", "host_delete"]},
+    {"id": "host_edit", "name": "edit", "name_en": "edit", "related_actions": ["host_view", "host_edit"]}
+  ],
+  "resource_types": [
+    {"id": "host", "name": "host", "name_en": "host", "parents": [{"id": "module"}]},
+    {"id": "set", "name": "set", "name_en": ""}
+  ],
+  "instance_selections": [
+    {"id": "host_list", "name": "host", "name_en": "host", "resource_type_chain": [{"id": "set"}, {"id": "host"}]},
+    {"id": "empty", "name": "empty", "name_en": "empty"}
+  ]
+}`
+
+func TestLint(t *testing.T) {
+	m := &model.SystemModel{}
+	if err := json.Unmarshal([]byte(brokenModel), m); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, f := range Lint(m, Rules()) {
+		got[fmt.Sprintf("%s %s %s: %s", f.Severity, f.Rule, f.Path, f.Message)] = true
+	}
+	want := []string{
+		"error action-resource-type-exists actions[host_view].related_resource_types: unknown resource type `switch`",
+		"error action-instance-selection-exists actions[host_view].related_instance_selections: " +
+			"unknown instance selection `unknown` of resource type `host`",
+		"error related-action-exists actions[host_view].related_actions: unknown action `host_delete`",
+		"error related-action-exists actions[host_edit].related_actions: the action relates to itself",
+		"warning related-action-cycle actions[host_view].related_actions: cycle host_view -> host_edit -> host_view",
+		"error resource-type-parent-exists resource_types[host].parents: unknown parent `module`",
+		"error instance-selection-chain instance_selections[empty].resource_type_chain: empty resource type chain",
+		"warning instance-selection-chain instance_selections[host_list].resource_type_chain: " +
+			"`set` is not a parent of `host`",
+		"warning required-fields resource_types[set].name_en: empty name_en",
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("finding not found: %s", w)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d findings, want %d: %v", len(got), len(want), got)
+	}
+}
+
+func TestCustomRule(t *testing.T) {
+	rule := NewRule("action-id-prefix", SeverityInfo, "the action id should start with the system id",
+		func(m *model.SystemModel, r *Reporter) {
+			for _, a := range m.Actions {
+				r.Report(actionPath(a.ID, "id"), "`%s` should start with `%s_`", a.ID, m.System.ID)
+			}
+		})
+
+	m := &model.SystemModel{System: model.System{ID: "demo"}, Actions: []model.Action{{ID: "view"}}}
+	findings := Lint(m, []Rule{rule})
+	if len(findings) != 1 || findings[0].Severity != SeverityInfo || findings[0].Rule != "action-id-prefix" {
+		t.Errorf("unexpected findings %+v", findings)
+	}
+	if HasErrors(findings) {
+		t.Error("info should not be error")
+	}
+}
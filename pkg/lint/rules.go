@@ -0,0 +1,287 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"bk-iam-cli/pkg/model"
+)
+
+// the built-in rules
+func init() {
+	Register(NewRule("duplicate-id", SeverityError,
+		"the ids of the actions, resource types and instance selections should be unique", checkDuplicateID))
+	Register(NewRule("required-fields", SeverityWarning,
+		"the id(error), name and name_en should not be empty", checkRequiredFields))
+	Register(NewRule("action-resource-type-exists", SeverityError,
+		"the related resource types of the action should exist", checkActionResourceTypeExists))
+	Register(NewRule("action-instance-selection-exists", SeverityError,
+		"the related instance selections of the action should exist", checkActionInstanceSelectionExists))
+	Register(NewRule("action-instance-selection-match", SeverityWarning,
+		"the resource type chain of the instance selection should end with the related resource type",
+		checkActionInstanceSelectionMatch))
+	Register(NewRule("related-action-exists", SeverityError,
+		"the related actions should exist and should not be the action itself", checkRelatedActionExists))
+	Register(NewRule("related-action-cycle", SeverityWarning,
+		"the related actions should not depend on each other in a cycle", checkRelatedActionCycle))
+	Register(NewRule("resource-type-parent-exists", SeverityError,
+		"the parents of the resource type should exist", checkResourceTypeParentExists))
+	Register(NewRule("instance-selection-chain", SeverityError,
+		"the resource type chain should not be empty, and the resource types in it should exist(error) "+
+			"and be the parent of the next one(warning)", checkInstanceSelectionChain))
+}
+
+func actionPath(id, field string) string {
+	return fmt.Sprintf("actions[%s].%s", id, field)
+}
+
+func resourceTypePath(id, field string) string {
+	return fmt.Sprintf("resource_types[%s].%s", id, field)
+}
+
+func instanceSelectionPath(id, field string) string {
+	return fmt.Sprintf("instance_selections[%s].%s", id, field)
+}
+
+// local returns true if the ref belongs to the system, the refs of the other systems are not checked
+func local(m *model.SystemModel, system string) bool {
+	return system == "" || system == m.System.ID
+}
+
+func resourceTypeIDs(m *model.SystemModel) map[string]model.ResourceType {
+	ids := make(map[string]model.ResourceType, len(m.ResourceTypes))
+	for _, rt := range m.ResourceTypes {
+		ids[rt.ID] = rt
+	}
+	return ids
+}
+
+func instanceSelectionIDs(m *model.SystemModel) map[string]model.InstanceSelection {
+	ids := make(map[string]model.InstanceSelection, len(m.InstanceSelections))
+	for _, is := range m.InstanceSelections {
+		ids[is.ID] = is
+	}
+	return ids
+}
+
+func checkDuplicateID(m *model.SystemModel, r *Reporter) {
+	check := func(kind string, ids []string) {
+		seen := map[string]bool{}
+		for _, id := range ids {
+			if seen[id] {
+				r.Report(fmt.Sprintf("%s[%s]", kind, id), "duplicate id `%s`", id)
+			}
+			seen[id] = true
+		}
+	}
+
+	ids := make([]string, 0, len(m.Actions))
+	for _, a := range m.Actions {
+		ids = append(ids, a.ID)
+	}
+	check("actions", ids)
+
+	ids = ids[:0]
+	for _, rt := range m.ResourceTypes {
+		ids = append(ids, rt.ID)
+	}
+	check("resource_types", ids)
+
+	ids = ids[:0]
+	for _, is := range m.InstanceSelections {
+		ids = append(ids, is.ID)
+	}
+	check("instance_selections", ids)
+}
+
+func checkRequiredFields(m *model.SystemModel, r *Reporter) {
+	check := func(path func(id, field string) string, id, name, nameEn string) {
+		if id == "" {
+			r.ReportWithSeverity(SeverityError, path(id, "id"), "empty id")
+		}
+		if name == "" {
+			r.Report(path(id, "name"), "empty name")
+		}
+		if nameEn == "" {
+			r.Report(path(id, "name_en"), "empty name_en")
+		}
+	}
+
+	for _, a := range m.Actions {
+		check(actionPath, a.ID, a.Name, a.NameEn)
+	}
+	for _, rt := range m.ResourceTypes {
+		check(resourceTypePath, rt.ID, rt.Name, rt.NameEn)
+	}
+	for _, is := range m.InstanceSelections {
+		check(instanceSelectionPath, is.ID, is.Name, is.NameEn)
+	}
+}
+
+func checkActionResourceTypeExists(m *model.SystemModel, r *Reporter) {
+	resourceTypes := resourceTypeIDs(m)
+	for _, a := range m.Actions {
+		for _, rrt := range a.RelatedResourceTypes {
+			if _, ok := resourceTypes[rrt.ID]; !ok && local(m, rrt.System) {
+				r.Report(actionPath(a.ID, "related_resource_types"), "unknown resource type `%s`", rrt.ID)
+			}
+		}
+	}
+}
+
+func checkActionInstanceSelectionExists(m *model.SystemModel, r *Reporter) {
+	selections := instanceSelectionIDs(m)
+	for _, a := range m.Actions {
+		for _, rrt := range a.RelatedResourceTypes {
+			for _, ref := range rrt.InstanceSelections {
+				if _, ok := selections[ref.ID]; !ok && local(m, ref.System) {
+					r.Report(actionPath(a.ID, "related_instance_selections"),
+						"unknown instance selection `%s` of resource type `%s`", ref.ID, rrt.ID)
+				}
+			}
+		}
+	}
+}
+
+func checkActionInstanceSelectionMatch(m *model.SystemModel, r *Reporter) {
+	selections := instanceSelectionIDs(m)
+	for _, a := range m.Actions {
+		for _, rrt := range a.RelatedResourceTypes {
+			for _, ref := range rrt.InstanceSelections {
+				is, ok := selections[ref.ID]
+				if !ok || !local(m, ref.System) || len(is.ResourceTypeChain) == 0 {
+					continue
+				}
+				last := is.ResourceTypeChain[len(is.ResourceTypeChain)-1]
+				if last.ID != rrt.ID || (last.System != "" && rrt.System != "" && last.System != rrt.System) {
+					r.Report(actionPath(a.ID, "related_instance_selections"),
+						"the chain of instance selection `%s` ends with `%s`, not the resource type `%s`",
+						is.ID, last.ID, rrt.ID)
+				}
+			}
+		}
+	}
+}
+
+func checkRelatedActionExists(m *model.SystemModel, r *Reporter) {
+	actions := make(map[string]bool, len(m.Actions))
+	for _, a := range m.Actions {
+		actions[a.ID] = true
+	}
+	for _, a := range m.Actions {
+		for _, id := range a.RelatedActions {
+			switch {
+			case id == a.ID:
+				r.Report(actionPath(a.ID, "related_actions"), "the action relates to itself")
+			case !actions[id]:
+				r.Report(actionPath(a.ID, "related_actions"), "unknown action `%s`", id)
+			}
+		}
+	}
+}
+
+func checkRelatedActionCycle(m *model.SystemModel, r *Reporter) {
+	related := make(map[string][]string, len(m.Actions))
+	for _, a := range m.Actions {
+		related[a.ID] = a.RelatedActions
+	}
+
+	// dfs, report each cycle once from the first action of it
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, next := range related[id] {
+			if next == id {
+				// reported by related-action-exists
+				continue
+			}
+			switch state[next] {
+			case visiting:
+				start := 0
+				for i, s := range stack {
+					if s == next {
+						start = i
+					}
+				}
+				cycle := append(append([]string{}, stack[start:]...), next)
+				r.Report(actionPath(next, "related_actions"), "cycle %s", strings.Join(cycle, " -> "))
+			case 0:
+				if _, ok := related[next]; ok {
+					visit(next)
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = visited
+	}
+	for _, a := range m.Actions {
+		if state[a.ID] == 0 {
+			visit(a.ID)
+		}
+	}
+}
+
+func checkResourceTypeParentExists(m *model.SystemModel, r *Reporter) {
+	resourceTypes := resourceTypeIDs(m)
+	for _, rt := range m.ResourceTypes {
+		for _, p := range rt.Parents {
+			if _, ok := resourceTypes[p.ID]; !ok && local(m, p.System) {
+				r.Report(resourceTypePath(rt.ID, "parents"), "unknown parent `%s`", p.ID)
+			}
+		}
+	}
+}
+
+func checkInstanceSelectionChain(m *model.SystemModel, r *Reporter) {
+	resourceTypes := resourceTypeIDs(m)
+	for _, is := range m.InstanceSelections {
+		path := instanceSelectionPath(is.ID, "resource_type_chain")
+		if len(is.ResourceTypeChain) == 0 {
+			r.Report(path, "empty resource type chain")
+			continue
+		}
+
+		for i, ref := range is.ResourceTypeChain {
+			if !local(m, ref.System) {
+				continue
+			}
+			rt, ok := resourceTypes[ref.ID]
+			if !ok {
+				r.Report(path, "unknown resource type `%s`", ref.ID)
+				continue
+			}
+			if i == 0 || len(rt.Parents) == 0 {
+				continue
+			}
+
+			prev := is.ResourceTypeChain[i-1]
+			isParent := false
+			for _, p := range rt.Parents {
+				if p.ID == prev.ID && (p.System == "" || prev.System == "" || p.System == prev.System) {
+					isParent = true
+				}
+			}
+			if !isParent {
+				r.ReportWithSeverity(SeverityWarning, path, "`%s` is not a parent of `%s`", prev.ID, ref.ID)
+			}
+		}
+	}
+}
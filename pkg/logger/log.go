@@ -14,26 +14,52 @@ package logger
 import (
 	"fmt"
 	"os"
+	"sync/atomic"
 
 	"github.com/TylerBrock/colorjson"
 	"github.com/gookit/color"
 )
 
+// muted drops the logs, e.g. the error of the command after --dry-run printed the request instead of sending it
+var muted int32
+
+// SetMuted mutes or unmutes the logs
+func SetMuted(m bool) {
+	var v int32
+	if m {
+		v = 1
+	}
+	atomic.StoreInt32(&muted, v)
+}
+
+func isMuted() bool {
+	return atomic.LoadInt32(&muted) == 1
+}
+
 func Debug(format string, args ...interface{}) {
-	if os.Getenv("DEBUG") == "true" {
+	if os.Getenv("DEBUG") == "true" && !isMuted() {
 		color.Debug.Tips(format, args...)
 	}
 }
 
 func Info(format string, args ...interface{}) {
+	if isMuted() {
+		return
+	}
 	color.Info.Tips(format, args...)
 }
 
 func Warn(format string, args ...interface{}) {
+	if isMuted() {
+		return
+	}
 	color.Warn.Tips(format, args...)
 }
 
 func Error(format string, args ...interface{}) {
+	if isMuted() {
+		return
+	}
 	color.Error.Tips(format, args...)
 }
 
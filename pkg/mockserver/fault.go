@@ -0,0 +1,85 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package mockserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fault is the error/latency injected to the requests of the path
+type Fault struct {
+	// Path is the prefix of the request path, * matches all
+	Path string
+
+	// Status is the http status code of the response, e.g. 500
+	Status int
+	// Code is the code in the response body with http status 200, e.g. 1901002
+	Code int
+	// Latency is added before the response
+	Latency time.Duration
+	// Rate is the probability of the error, 0 means always
+	Rate float64
+}
+
+func (f *Fault) match(path string) bool {
+	return f.Path == "*" || strings.HasPrefix(path, f.Path)
+}
+
+// ParseFault parses the fault from `{path}={key}:{value}[,{key}:{value}]`, the keys are status/code/latency/rate, e.g.
+//
+//	/api/v1/debug/query/policy=status:500,rate:0.5
+//	*=latency:200ms
+func ParseFault(s string) (Fault, error) {
+	invalid := fmt.Errorf("invalid fault `%s`, should be {path}={key}:{value}[,{key}:{value}], "+
+		"the keys are status/code/latency/rate", s)
+
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+		return Fault{}, invalid
+	}
+
+	f := Fault{Path: kv[0]}
+	for _, item := range strings.Split(kv[1], ",") {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 {
+			return Fault{}, invalid
+		}
+
+		var err error
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		switch key {
+		case "status":
+			f.Status, err = strconv.Atoi(value)
+			if err == nil && (f.Status < 100 || f.Status > 599) {
+				err = fmt.Errorf("status %d out of range", f.Status)
+			}
+		case "code":
+			f.Code, err = strconv.Atoi(value)
+		case "latency":
+			f.Latency, err = time.ParseDuration(value)
+		case "rate":
+			f.Rate, err = strconv.ParseFloat(value, 64)
+			if err == nil && (f.Rate < 0 || f.Rate > 1) {
+				err = fmt.Errorf("rate %v should be in [0, 1]", f.Rate)
+			}
+		default:
+			return Fault{}, invalid
+		}
+		if err != nil {
+			return Fault{}, fmt.Errorf("invalid fault `%s`, %s: %w", s, key, err)
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,277 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+// Package mockserver is an offline IAM backend and SaaS server, the responses are from the fixture files.
+//
+// The fixture of a request is looked up from the most specific name to the generic one, e.g.
+// GET /api/v1/debug/query/policy?subject_type=user&subject_id=tom&action=project_view tries
+// query_policy.user.tom.project_view.json, query_policy.user.tom.json, then query_policy.json.
+// The fixture is the whole response body, e.g. {"code": 0, "message": "ok", "data": {...}}.
+package mockserver
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+//go:embed fixtures/*.json
+var embedFixtures embed.FS
+
+const (
+	// DefaultAppCode and DefaultAppSecret are the credential accepted by default
+	DefaultAppCode   = "bk_iam_cli"
+	DefaultAppSecret = "mock-secret"
+
+	codeUnauthorized = 1901401
+	codeNotFound     = 1901404
+	codeInjected     = 1901500
+)
+
+// Options is the options of the mock server
+type Options struct {
+	// FixturesDir is the dir of the fixture files, the embedded fixtures are used if empty
+	FixturesDir string
+
+	// the credential, the backend validates the X-BK-APP-CODE/X-BK-APP-SECRET headers, the SaaS validates basic auth
+	AppCode   string
+	AppSecret string
+
+	// Latency is added to all the requests
+	Latency time.Duration
+	// Faults are injected to the matched requests
+	Faults []Fault
+}
+
+// Server is the mock server, implements http.Handler
+type Server struct {
+	fixtures fs.FS
+
+	appCode   string
+	appSecret string
+
+	latency time.Duration
+	faults  []Fault
+}
+
+// New creates the mock server
+func New(opts Options) (*Server, error) {
+	var fixtures fs.FS
+	if opts.FixturesDir == "" {
+		sub, err := fs.Sub(embedFixtures, "fixtures")
+		if err != nil {
+			return nil, err
+		}
+		fixtures = sub
+	} else {
+		info, err := os.Stat(opts.FixturesDir)
+		if err != nil {
+			return nil, fmt.Errorf("read fixtures dir fail! %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("fixtures `%s` is not a dir", opts.FixturesDir)
+		}
+		fixtures = os.DirFS(opts.FixturesDir)
+	}
+
+	s := &Server{
+		fixtures:  fixtures,
+		appCode:   opts.AppCode,
+		appSecret: opts.AppSecret,
+		latency:   opts.Latency,
+		faults:    opts.Faults,
+	}
+	if s.appCode == "" {
+		s.appCode, s.appSecret = DefaultAppCode, DefaultAppSecret
+	}
+	return s, nil
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// the same as the IAM backend, for tracing the request
+	w.Header().Set("X-Request-Id", fmt.Sprintf("%016x", rand.Int63()))
+
+	latency := s.latency
+	for _, f := range s.faults {
+		if !f.match(r.URL.Path) {
+			continue
+		}
+		latency += f.Latency
+		if f.Status == 0 && f.Code == 0 {
+			continue
+		}
+		if f.Rate > 0 && rand.Float64() >= f.Rate {
+			continue
+		}
+
+		time.Sleep(latency)
+		if f.Status != 0 {
+			writeError(w, f.Status, codeInjected, fmt.Sprintf("mock error injected, status=%d", f.Status))
+		} else {
+			writeError(w, http.StatusOK, f.Code, fmt.Sprintf("mock error injected, code=%d", f.Code))
+		}
+		return
+	}
+	time.Sleep(latency)
+
+	route, candidates, ok := s.route(r)
+	if !ok {
+		writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("path %s not found", r.URL.Path))
+		return
+	}
+
+	switch route {
+	case routePublic:
+	case routeBackend:
+		appCode, appSecret := r.Header.Get("X-BK-APP-CODE"), r.Header.Get("X-BK-APP-SECRET")
+		if appCode != s.appCode || appSecret != s.appSecret {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized,
+				"unauthorized: app code or app secret wrong, check the X-BK-APP-CODE/X-BK-APP-SECRET headers")
+			return
+		}
+	case routeSaaS:
+		appCode, appSecret, ok := r.BasicAuth()
+		if !ok || appCode != s.appCode || appSecret != s.appSecret {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized,
+				"unauthorized: app code or app secret wrong, check the basic auth")
+			return
+		}
+	}
+
+	// the built-in responses
+	if len(candidates) == 0 {
+		switch r.URL.Path {
+		case "/ping":
+			_, _ = w.Write([]byte("pong"))
+		case "/healthz":
+			_, _ = w.Write([]byte("ok"))
+		}
+		return
+	}
+
+	for _, name := range candidates {
+		body, err := fs.ReadFile(s.fixtures, name)
+		if err != nil {
+			continue
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+		return
+	}
+
+	writeError(w, http.StatusNotFound, codeNotFound,
+		fmt.Sprintf("fixture not found, tried %s", strings.Join(candidates, ", ")))
+}
+
+type routeType int
+
+const (
+	routePublic routeType = iota
+	routeBackend
+	routeSaaS
+)
+
+// route returns the type of the route and the candidate fixture files of the request
+func (s *Server) route(r *http.Request) (routeType, []string, bool) {
+	p := r.URL.Path
+	q := r.URL.Query()
+
+	switch {
+	case p == "/ping" || p == "/healthz":
+		return routePublic, nil, true
+	case p == "/version":
+		return routePublic, []string{"version.json"}, true
+	case p == "/api/v1/web/systems":
+		return routeBackend, []string{"systems.json"}, true
+
+	case p == "/api/v1/debug/query/model":
+		return routeBackend, candidates("query_model", q.Get("system")), true
+	case p == "/api/v1/debug/query/action":
+		return routeBackend, candidates("query_action", q.Get("system")), true
+	case p == "/api/v1/debug/query/subject":
+		return routeBackend, candidates("query_subject", q.Get("type"), q.Get("id")), true
+	case p == "/api/v1/debug/query/policy":
+		return routeBackend, candidates("query_policy", q.Get("subject_type"), q.Get("subject_id"),
+			q.Get("action")), true
+
+	case p == "/api/v1/debug/cache/policy":
+		// NOTE: the response of the specific action is different from the one of all actions
+		if q.Get("action") != "" {
+			return routeBackend, candidates("cache_policy_action", q.Get("subject_type"), q.Get("subject_id"),
+				q.Get("action")), true
+		}
+		return routeBackend, candidates("cache_policy", q.Get("subject_type"), q.Get("subject_id")), true
+	case p == "/api/v1/debug/cache/expression":
+		return routeBackend, candidates("cache_expression", q.Get("pks")), true
+
+	case strings.HasPrefix(p, "/api/v1/systems/"):
+		// /api/v1/systems/{system}/policies[/{id}|/-/subjects]
+		parts := strings.Split(strings.Trim(p, "/"), "/")
+		if len(parts) < 5 || parts[4] != "policies" {
+			return 0, nil, false
+		}
+		system := parts[3]
+		switch {
+		case len(parts) == 5:
+			return routeBackend, candidates("system_policies", system, q.Get("action_id")), true
+		case len(parts) == 7 && parts[5] == "-" && parts[6] == "subjects":
+			return routeBackend, candidates("system_policy_subjects", system), true
+		case len(parts) == 6:
+			return routeBackend, candidates("system_policy", system, parts[5]), true
+		}
+		return 0, nil, false
+
+	case p == "/api/v1/debug/":
+		return routeSaaS, candidates("saas_debug_list", q.Get("day")), true
+	case strings.HasPrefix(p, "/api/v1/debug/") && strings.HasSuffix(p, "/"):
+		// /api/v1/debug/{request_id}/
+		parts := strings.Split(strings.Trim(p, "/"), "/")
+		if len(parts) != 4 || parts[3] == "query" || parts[3] == "cache" {
+			return 0, nil, false
+		}
+		return routeSaaS, candidates("saas_debug", parts[3]), true
+	}
+	return 0, nil, false
+}
+
+// candidates returns the fixture names from the most specific to the generic one,
+// e.g. name.a.b.json, name.a.json, name.json, the empty parts are skipped
+func candidates(name string, parts ...string) []string {
+	for i, p := range parts {
+		if p == "" {
+			parts = parts[:i]
+			break
+		}
+	}
+
+	names := make([]string, 0, len(parts)+1)
+	for i := len(parts); i > 0; i-- {
+		names = append(names, fmt.Sprintf("%s.%s.json", name, strings.Join(parts[:i], ".")))
+	}
+	return append(names, name+".json")
+}
+
+func writeError(w http.ResponseWriter, status int, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":    code,
+		"message": message,
+		"data":    map[string]interface{}{},
+	})
+}
@@ -0,0 +1,205 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package mockserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func serve(t *testing.T, s *Server, r *http.Request) (int, map[string]interface{}) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+
+	body := map[string]interface{}{}
+	if w.Header().Get("Content-Type") == "application/json" {
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid json response: %s", w.Body.String())
+		}
+	}
+	return w.Code, body
+}
+
+func backendRequest(url string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	r.Header.Set("X-BK-APP-CODE", DefaultAppCode)
+	r.Header.Set("X-BK-APP-SECRET", DefaultAppSecret)
+	return r
+}
+
+func TestCandidates(t *testing.T) {
+	got := candidates("query_policy", "user", "tom", "project_view")
+	want := []string{
+		"query_policy.user.tom.project_view.json",
+		"query_policy.user.tom.json",
+		"query_policy.user.json",
+		"query_policy.json",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got = candidates("cache_policy", "user", "", "project_view")
+	want = []string{"cache_policy.user.json", "cache_policy.json"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFixtureLookup(t *testing.T) {
+	s, err := New(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the specific fixture
+	code, body := serve(t, s, backendRequest(
+		"/api/v1/debug/query/policy?system=bk_sops&subject_type=user&subject_id=jerry&action=project_view"))
+	if code != http.StatusOK || !reflect.DeepEqual(body["data"], map[string]interface{}{}) {
+		t.Errorf("got %d %v, want the policy of jerry", code, body)
+	}
+
+	// fallback to the generic fixture
+	code, body = serve(t, s, backendRequest(
+		"/api/v1/debug/query/policy?system=bk_sops&subject_type=user&subject_id=tom&action=project_view"))
+	if code != http.StatusOK || body["data"].(map[string]interface{})["op"] != "OR" {
+		t.Errorf("got %d %v, want the generic policy", code, body)
+	}
+
+	// the system policies
+	code, _ = serve(t, s, backendRequest("/api/v1/systems/bk_sops/policies/-/subjects?ids=1,2"))
+	if code != http.StatusOK {
+		t.Errorf("got %d, want 200", code)
+	}
+
+	code, body = serve(t, s, backendRequest("/api/v1/not/exists"))
+	if code != http.StatusNotFound || body["code"] != float64(codeNotFound) {
+		t.Errorf("got %d %v, want 404", code, body)
+	}
+}
+
+func TestFixturesDir(t *testing.T) {
+	dir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(dir, "query_subject.group.7.json"),
+		[]byte(`{"code": 0, "message": "ok", "data": {"subject": {"type": "group", "id": "7"}}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(Options{FixturesDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := serve(t, s, backendRequest("/api/v1/debug/query/subject?type=group&id=7"))
+	if code != http.StatusOK {
+		t.Errorf("got %d, want 200", code)
+	}
+	// the embedded fixtures are not used
+	code, _ = serve(t, s, backendRequest("/api/v1/debug/query/subject?type=user&id=tom"))
+	if code != http.StatusNotFound {
+		t.Errorf("got %d, want 404", code)
+	}
+
+	if _, err := New(Options{FixturesDir: filepath.Join(dir, "not_exists")}); err == nil {
+		t.Error("the fixtures dir not exists, should fail")
+	}
+}
+
+func TestAuth(t *testing.T) {
+	s, err := New(Options{AppCode: "app", AppSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// public
+	if code, _ := serve(t, s, httptest.NewRequest(http.MethodGet, "/ping", nil)); code != http.StatusOK {
+		t.Errorf("ping got %d, want 200", code)
+	}
+
+	// backend
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/web/systems", nil)
+	if code, _ := serve(t, s, r); code != http.StatusUnauthorized {
+		t.Errorf("without headers got %d, want 401", code)
+	}
+	r.Header.Set("X-BK-APP-CODE", "app")
+	r.Header.Set("X-BK-APP-SECRET", "secret")
+	if code, _ := serve(t, s, r); code != http.StatusOK {
+		t.Errorf("with headers got %d, want 200", code)
+	}
+
+	// saas
+	r = httptest.NewRequest(http.MethodGet, "/api/v1/debug/?day=20220310", nil)
+	r.Header.Set("X-BK-APP-CODE", "app")
+	r.Header.Set("X-BK-APP-SECRET", "secret")
+	if code, _ := serve(t, s, r); code != http.StatusUnauthorized {
+		t.Errorf("saas without basic auth got %d, want 401", code)
+	}
+	r.SetBasicAuth("app", "secret")
+	if code, _ := serve(t, s, r); code != http.StatusOK {
+		t.Errorf("saas with basic auth got %d, want 200", code)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	s, err := New(Options{
+		Faults: []Fault{
+			{Path: "/api/v1/debug/query/policy", Status: http.StatusInternalServerError},
+			{Path: "/api/v1/debug/query/subject", Code: 1901002},
+			{Path: "/api/v1/web/systems", Latency: 50 * time.Millisecond},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _ := serve(t, s, backendRequest("/api/v1/debug/query/policy?subject_type=user&subject_id=tom"))
+	if code != http.StatusInternalServerError {
+		t.Errorf("got %d, want 500", code)
+	}
+
+	code, body := serve(t, s, backendRequest("/api/v1/debug/query/subject?type=user&id=tom"))
+	if code != http.StatusOK || body["code"] != float64(1901002) {
+		t.Errorf("got %d %v, want code 1901002", code, body)
+	}
+
+	start := time.Now()
+	code, _ = serve(t, s, backendRequest("/api/v1/web/systems"))
+	if code != http.StatusOK || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("got %d in %s, want 200 after 50ms", code, time.Since(start))
+	}
+}
+
+func TestParseFault(t *testing.T) {
+	f, err := ParseFault("/api/v1/debug/query/policy=status:500,rate:0.5,latency:1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Fault{Path: "/api/v1/debug/query/policy", Status: 500, Rate: 0.5, Latency: time.Second}
+	if f != want {
+		t.Errorf("got %+v, want %+v", f, want)
+	}
+
+	for _, s := range []string{"", "/ping", "/ping=", "/ping=status", "/ping=status:700", "/ping=rate:2", "/ping=foo:1"} {
+		if _, err := ParseFault(s); err == nil {
+			t.Errorf("parse `%s` should fail", s)
+		}
+	}
+}
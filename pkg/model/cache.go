@@ -0,0 +1,102 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package model
+
+import (
+	"bk-iam-cli/pkg/expression"
+)
+
+// NOTE: the debug/cache apis dump the internal structs of the backend, some of them have no json tags,
+//       so the keys may be `SubjectPK` or `subject_pk`, the structs here are decoded by unmarshalLoose
+
+// CacheAction is the action in the policy cache of the subject
+type CacheAction struct {
+	System string `json:"system"`
+	ID     string `json:"id"`
+	PK     int64  `json:"pk"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (a *CacheAction) UnmarshalJSON(data []byte) error {
+	type alias CacheAction
+	return unmarshalLoose(data, (*alias)(a))
+}
+
+// CachedPolicy is the policy in the cache, the expression is referred by the pk
+type CachedPolicy struct {
+	PK           int64 `json:"pk"`
+	SubjectPK    int64 `json:"subject_pk"`
+	ExpressionPK int64 `json:"expression_pk"`
+	ExpiredAt    int64 `json:"expired_at"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *CachedPolicy) UnmarshalJSON(data []byte) error {
+	type alias CachedPolicy
+	return unmarshalLoose(data, (*alias)(p))
+}
+
+// Expression is the expression of the policies, the content is the raw resource expression in json
+type Expression struct {
+	PK         int64  `json:"pk"`
+	Type       int64  `json:"type,omitempty"`
+	Expression string `json:"expression"`
+	Signature  string `json:"signature,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (e *Expression) UnmarshalJSON(data []byte) error {
+	type alias Expression
+	return unmarshalLoose(data, (*alias)(e))
+}
+
+// Parse parses the content, the empty content means the action without resource types, any
+func (e *Expression) Parse() (*expression.Expression, error) {
+	if e.Expression == "" {
+		return &expression.Expression{Op: expression.OpAny}, nil
+	}
+	return expression.Parse([]byte(e.Expression))
+}
+
+// CachePolicy is the policy cache of the subject, returned by /api/v1/debug/cache/policy;
+// without action: the actions(keys) in cache; with action: the policies and expressions of the action
+type CachePolicy struct {
+	SubjectPK int64 `json:"subject_pk"`
+
+	Keys    []string      `json:"keys,omitempty"`
+	Actions []CacheAction `json:"actions,omitempty"`
+
+	ActionPK    int64          `json:"action_pk,omitempty"`
+	Policies    []CachedPolicy `json:"policies,omitempty"`
+	Expressions []Expression   `json:"expressions,omitempty"`
+	NotInCache  bool           `json:"notInCache,omitempty"`
+
+	Errs []interface{} `json:"errs"`
+}
+
+// ExpressionOf returns the expression of the policy, nil if not in the expressions
+func (c *CachePolicy) ExpressionOf(p CachedPolicy) *Expression {
+	for i := range c.Expressions {
+		if c.Expressions[i].PK == p.ExpressionPK {
+			return &c.Expressions[i]
+		}
+	}
+	return nil
+}
+
+// CacheExpression is the expressions in cache, returned by /api/v1/debug/cache/expression
+type CacheExpression struct {
+	PKs         []int64      `json:"pks"`
+	NoCachePKs  []int64      `json:"noCachePKs"`
+	Expressions []Expression `json:"expressions"`
+	Err         interface{}  `json:"err"`
+}
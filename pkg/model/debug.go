@@ -0,0 +1,61 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package model
+
+// DebugEntry is the debug record of a request/task of the SaaS, returned by /api/v1/debug/
+type DebugEntry struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Exc  string `json:"exc"`
+
+	// the call stack, only returned by the get api /api/v1/debug/{id}/
+	Stack []interface{} `json:"stack,omitempty"`
+}
+
+// HasError returns true if there is an exception in the request/task
+func (e *DebugEntry) HasError() bool {
+	return e.Exc != ""
+}
+
+// PolicyDebug is the debug info returned by the backend apis with debug=true, e.g. /api/v1/debug/query/policy
+type PolicyDebug struct {
+	Time    string                 `json:"time"`
+	Context map[string]interface{} `json:"context"`
+	Steps   []DebugStep            `json:"steps"`
+	Evals   map[string]interface{} `json:"evals"`
+	Error   string                 `json:"error"`
+
+	// the debug info of the sub calls, e.g. each action of the batch apis
+	SubDebugs []PolicyDebug `json:"sub_debugs,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *PolicyDebug) UnmarshalJSON(data []byte) error {
+	type alias PolicyDebug
+	return unmarshalLoose(data, (*alias)(d))
+}
+
+// DebugStep is a step of the backend processing, the data is different in each step,
+// e.g. the policies/expressions queried, the cache hit or not, the time cost
+type DebugStep struct {
+	Index int                    `json:"index"`
+	Name  string                 `json:"name"`
+	Data  map[string]interface{} `json:"data"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *DebugStep) UnmarshalJSON(data []byte) error {
+	type alias DebugStep
+	return unmarshalLoose(data, (*alias)(s))
+}
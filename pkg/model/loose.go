@@ -0,0 +1,54 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// unmarshalLoose decodes the json object into the struct, the keys are case and underscore insensitive,
+// e.g. `SubjectPK`, `subjectPk` and `subject_pk` all match the field with tag `json:"subject_pk"`
+func unmarshalLoose(data []byte, v interface{}) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	tags := map[string]string{}
+	t := reflect.TypeOf(v).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag != "" && tag != "-" {
+			tags[looseKey(tag)] = tag
+		}
+	}
+
+	normalized := make(map[string]json.RawMessage, len(raw))
+	for k, value := range raw {
+		if tag, ok := tags[looseKey(k)]; ok {
+			k = tag
+		}
+		normalized[k] = value
+	}
+
+	b, err := json.Marshal(normalized)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func looseKey(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", ""))
+}
@@ -0,0 +1,107 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package model
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSubjectGroupExpiry(t *testing.T) {
+	now := time.Unix(1649591000, 0)
+
+	cases := []struct {
+		group     SubjectGroup
+		permanent bool
+		expired   bool
+	}{
+		{SubjectGroup{PK: 1, PolicyExpiredAt: PermanentExpiredAt}, true, false},
+		{SubjectGroup{PK: 2, PolicyExpiredAt: 1649591084}, false, false},
+		{SubjectGroup{PK: 3, PolicyExpiredAt: 1649500000}, false, true},
+	}
+	for _, c := range cases {
+		if got := c.group.IsPermanent(); got != c.permanent {
+			t.Errorf("group %d IsPermanent: got %v, want %v", c.group.PK, got, c.permanent)
+		}
+		if got := c.group.IsExpired(now); got != c.expired {
+			t.Errorf("group %d IsExpired: got %v, want %v", c.group.PK, got, c.expired)
+		}
+	}
+}
+
+func TestUnmarshalLoose(t *testing.T) {
+	cases := []string{
+		`{"PK": 1, "SubjectPK": 2, "ExpressionPK": 3, "ExpiredAt": 4}`,
+		`{"pk": 1, "subject_pk": 2, "expression_pk": 3, "expired_at": 4}`,
+		`{"pk": 1, "subjectPk": 2, "expressionPK": 3, "expiredAt": 4, "unknown": 5}`,
+	}
+	want := CachedPolicy{PK: 1, SubjectPK: 2, ExpressionPK: 3, ExpiredAt: 4}
+
+	for _, c := range cases {
+		var p CachedPolicy
+		if err := json.Unmarshal([]byte(c), &p); err != nil {
+			t.Fatalf("unmarshal %s fail: %s", c, err)
+		}
+		if p != want {
+			t.Errorf("unmarshal %s: got %+v, want %+v", c, p, want)
+		}
+	}
+}
+
+func TestDiffModels(t *testing.T) {
+	left := &SystemModel{
+		System: System{ID: "bk_sops"},
+		Actions: []Action{
+			{PK: 2, ID: "flow_view", Name: "view", RelatedActions: []string{"b", "a"}},
+			{PK: 1, ID: "flow_delete", Name: "delete"},
+		},
+		ResourceTypes: []ResourceType{{ID: "flow", Name: "flow"}},
+	}
+	right := &SystemModel{
+		System: System{ID: "bk_sops"},
+		Actions: []Action{
+			{PK: 9, ID: "flow_view", Name: "view", RelatedActions: []string{"a", "b"}},
+			{ID: "flow_edit", Name: "edit"},
+		},
+		ResourceTypes: []ResourceType{{ID: "flow", Name: "flow template"}},
+	}
+
+	n := left.Normalize()
+	if n.Actions[0].ID != "flow_delete" || n.Actions[1].RelatedActions[0] != "a" || n.Actions[0].PK != 0 {
+		t.Errorf("normalize: got %+v", n.Actions)
+	}
+	if n.InstanceSelections == nil || n.Actions[0].RelatedActions == nil {
+		t.Errorf("normalize: nil lists should be empty")
+	}
+
+	d := DiffModels(left, right)
+	if d.Equal() {
+		t.Fatal("diff: should not be equal")
+	}
+	if len(d.Actions.Added) != 1 || d.Actions.Added[0] != "flow_edit" {
+		t.Errorf("diff actions added: got %v", d.Actions.Added)
+	}
+	if len(d.Actions.Removed) != 1 || d.Actions.Removed[0] != "flow_delete" {
+		t.Errorf("diff actions removed: got %v", d.Actions.Removed)
+	}
+	if len(d.Actions.Changed) != 0 {
+		t.Errorf("diff actions changed: got %+v", d.Actions.Changed)
+	}
+	if len(d.ResourceTypes.Changed) != 1 || d.ResourceTypes.Changed[0].Fields[0].Field != "name" {
+		t.Errorf("diff resource types changed: got %+v", d.ResourceTypes.Changed)
+	}
+
+	if !DiffModels(left, left.Normalize()).Equal() {
+		t.Error("diff: the model should equal to the normalized one")
+	}
+}
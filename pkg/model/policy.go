@@ -0,0 +1,58 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package model
+
+import (
+	"encoding/json"
+
+	"bk-iam-cli/pkg/expression"
+)
+
+// Policy is the policy returned by the system policies api /api/v1/systems/{system}/policies
+type Policy struct {
+	Version string `json:"version,omitempty"`
+	ID      int64  `json:"id"`
+	System  string `json:"system,omitempty"`
+	Action  *struct {
+		ID string `json:"id"`
+	} `json:"action,omitempty"`
+	Subject    Subject         `json:"subject"`
+	Expression json.RawMessage `json:"expression"`
+	ExpiredAt  int64           `json:"expired_at"`
+}
+
+// ParseExpression parses the expression of the policy
+func (p *Policy) ParseExpression() (*expression.Expression, error) {
+	return expression.Parse(p.Expression)
+}
+
+// PolicyListMetadata is the metadata of the policy list, the timestamp should be used for the next pages
+type PolicyListMetadata struct {
+	System string `json:"system"`
+	Action struct {
+		ID string `json:"id"`
+	} `json:"action"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// PolicyList is a page of the policies of an action
+type PolicyList struct {
+	Metadata PolicyListMetadata `json:"metadata"`
+	Count    int64              `json:"count"`
+	Results  []Policy           `json:"results"`
+}
+
+// PolicySubject is the subject of the policy
+type PolicySubject struct {
+	ID      int64   `json:"id"`
+	Subject Subject `json:"subject"`
+}
@@ -0,0 +1,200 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Normalize returns a stable snapshot of the model, two snapshots of the same model are identical:
+// the actions/resource types/instance selections are sorted by id, the unordered lists(related actions, parents)
+// are sorted, and the nil lists are empty; the ordered lists(related resource types, resource type chain) are kept
+func (m *SystemModel) Normalize() *SystemModel {
+	n := &SystemModel{
+		System:             m.System,
+		Actions:            make([]Action, 0, len(m.Actions)),
+		ResourceTypes:      make([]ResourceType, 0, len(m.ResourceTypes)),
+		InstanceSelections: make([]InstanceSelection, 0, len(m.InstanceSelections)),
+	}
+
+	for _, a := range m.Actions {
+		// the pk differs between the environments
+		a.PK = 0
+		a.RelatedActions = sortedStrings(a.RelatedActions)
+		rrts := make([]RelatedResourceType, 0, len(a.RelatedResourceTypes))
+		for _, rrt := range a.RelatedResourceTypes {
+			rrt.InstanceSelections = nonNilRefs(rrt.InstanceSelections)
+			rrts = append(rrts, rrt)
+		}
+		a.RelatedResourceTypes = rrts
+		n.Actions = append(n.Actions, a)
+	}
+	sort.SliceStable(n.Actions, func(i, j int) bool { return n.Actions[i].ID < n.Actions[j].ID })
+
+	for _, rt := range m.ResourceTypes {
+		rt.Parents = nonNilRefs(rt.Parents)
+		sort.SliceStable(rt.Parents, func(i, j int) bool {
+			a, b := rt.Parents[i], rt.Parents[j]
+			return a.System < b.System || (a.System == b.System && a.ID < b.ID)
+		})
+		n.ResourceTypes = append(n.ResourceTypes, rt)
+	}
+	sort.SliceStable(n.ResourceTypes, func(i, j int) bool { return n.ResourceTypes[i].ID < n.ResourceTypes[j].ID })
+
+	for _, is := range m.InstanceSelections {
+		is.ResourceTypeChain = nonNilRefs(is.ResourceTypeChain)
+		n.InstanceSelections = append(n.InstanceSelections, is)
+	}
+	sort.SliceStable(n.InstanceSelections, func(i, j int) bool {
+		return n.InstanceSelections[i].ID < n.InstanceSelections[j].ID
+	})
+	return n
+}
+
+func sortedStrings(s []string) []string {
+	result := append([]string{}, s...)
+	sort.Strings(result)
+	return result
+}
+
+func nonNilRefs(refs []ResourceTypeRef) []ResourceTypeRef {
+	return append([]ResourceTypeRef{}, refs...)
+}
+
+// FieldChange is the changed field of the entity
+type FieldChange struct {
+	Field string      `json:"field"`
+	Left  interface{} `json:"left"`
+	Right interface{} `json:"right"`
+}
+
+// EntityChange is the changed action/resource type/instance selection
+type EntityChange struct {
+	ID     string        `json:"id"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// EntityDiff is the difference of a kind of entities
+type EntityDiff struct {
+	Added   []string       `json:"added"`
+	Removed []string       `json:"removed"`
+	Changed []EntityChange `json:"changed"`
+}
+
+// Equal returns true if nothing added/removed/changed
+func (d EntityDiff) Equal() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// ModelDiff is the difference of two models, e.g. between the stage and prod environments
+type ModelDiff struct {
+	System             FieldChanges `json:"system"`
+	Actions            EntityDiff   `json:"actions"`
+	ResourceTypes      EntityDiff   `json:"resource_types"`
+	InstanceSelections EntityDiff   `json:"instance_selections"`
+}
+
+// FieldChanges is the changed fields of the system
+type FieldChanges []FieldChange
+
+// Equal returns true if the models are the same
+func (d *ModelDiff) Equal() bool {
+	return len(d.System) == 0 && d.Actions.Equal() && d.ResourceTypes.Equal() && d.InstanceSelections.Equal()
+}
+
+// DiffModels compares the normalized models, the left is the old one
+func DiffModels(left, right *SystemModel) *ModelDiff {
+	l, r := left.Normalize(), right.Normalize()
+
+	d := &ModelDiff{System: diffFields(l.System, r.System)}
+	if d.System == nil {
+		d.System = FieldChanges{}
+	}
+
+	d.Actions = diffEntities(entityMap(l.Actions, func(i int) string { return l.Actions[i].ID }),
+		entityMap(r.Actions, func(i int) string { return r.Actions[i].ID }))
+	d.ResourceTypes = diffEntities(entityMap(l.ResourceTypes, func(i int) string { return l.ResourceTypes[i].ID }),
+		entityMap(r.ResourceTypes, func(i int) string { return r.ResourceTypes[i].ID }))
+	d.InstanceSelections = diffEntities(
+		entityMap(l.InstanceSelections, func(i int) string { return l.InstanceSelections[i].ID }),
+		entityMap(r.InstanceSelections, func(i int) string { return r.InstanceSelections[i].ID }))
+	return d
+}
+
+// entityMap returns the entities in the slice by id
+func entityMap(slice interface{}, id func(i int) string) map[string]interface{} {
+	v := reflect.ValueOf(slice)
+	m := make(map[string]interface{}, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		m[id(i)] = v.Index(i).Interface()
+	}
+	return m
+}
+
+func diffEntities(left, right map[string]interface{}) EntityDiff {
+	d := EntityDiff{Added: []string{}, Removed: []string{}, Changed: []EntityChange{}}
+	for _, id := range sortedKeys(left) {
+		r, ok := right[id]
+		if !ok {
+			d.Removed = append(d.Removed, id)
+			continue
+		}
+		if fields := diffFields(left[id], r); len(fields) > 0 {
+			d.Changed = append(d.Changed, EntityChange{ID: id, Fields: fields})
+		}
+	}
+	for _, id := range sortedKeys(right) {
+		if _, ok := left[id]; !ok {
+			d.Added = append(d.Added, id)
+		}
+	}
+	return d
+}
+
+// diffFields compares the json fields of the entities
+func diffFields(left, right interface{}) FieldChanges {
+	l, r := toJSONMap(left), toJSONMap(right)
+
+	keys := map[string]interface{}{}
+	for k := range l {
+		keys[k] = nil
+	}
+	for k := range r {
+		keys[k] = nil
+	}
+
+	var changes FieldChanges
+	for _, k := range sortedKeys(keys) {
+		if !reflect.DeepEqual(l[k], r[k]) {
+			changes = append(changes, FieldChange{Field: k, Left: l[k], Right: r[k]})
+		}
+	}
+	return changes
+}
+
+func toJSONMap(v interface{}) map[string]interface{} {
+	data, _ := json.Marshal(v)
+	m := map[string]interface{}{}
+	_ = json.Unmarshal(data, &m)
+	return m
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,93 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// PermanentExpiredAt is the policy_expired_at of the permanent group membership, 2100-01-01
+const PermanentExpiredAt int64 = 4102444800
+
+// Subject is the user/group/department
+type Subject struct {
+	PK   int64  `json:"pk,omitempty"`
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// String returns the subject as `{type}:{id}`
+func (s Subject) String() string {
+	return fmt.Sprintf("%s:%s", s.Type, s.ID)
+}
+
+// SubjectGroup is the group the subject joined, and the expired time of the membership
+type SubjectGroup struct {
+	PK              int64 `json:"pk"`
+	PolicyExpiredAt int64 `json:"policy_expired_at"`
+}
+
+// IsPermanent returns true if the membership never expires
+func (g SubjectGroup) IsPermanent() bool {
+	return g.PolicyExpiredAt >= PermanentExpiredAt
+}
+
+// ExpiredAt returns the expired time of the membership
+func (g SubjectGroup) ExpiredAt() time.Time {
+	return time.Unix(g.PolicyExpiredAt, 0)
+}
+
+// IsExpired returns true if the membership has expired at the time
+func (g SubjectGroup) IsExpired(now time.Time) bool {
+	return !g.IsPermanent() && g.PolicyExpiredAt < now.Unix()
+}
+
+// SubjectDepartment is the department of the user, and the groups the department joined
+type SubjectDepartment struct {
+	Subject
+
+	Groups []SubjectGroup `json:"groups"`
+}
+
+// SubjectDetail is the subject and its departments/groups, returned by /api/v1/debug/query/subject
+type SubjectDetail struct {
+	Subject     Subject             `json:"subject"`
+	Departments []SubjectDepartment `json:"departments"`
+	Groups      []SubjectGroup      `json:"groups"`
+	// the errors while querying, key is the step
+	Errs map[string]interface{} `json:"errs"`
+}
+
+// GroupSource is a group of the subject and where it comes from
+type GroupSource struct {
+	SubjectGroup
+
+	// nil if the group is joined directly
+	Department *Subject `json:"department,omitempty"`
+}
+
+// AllGroups returns the groups joined directly and via the departments
+func (d *SubjectDetail) AllGroups() []GroupSource {
+	groups := make([]GroupSource, 0, len(d.Groups))
+	for _, g := range d.Groups {
+		groups = append(groups, GroupSource{SubjectGroup: g})
+	}
+	for i := range d.Departments {
+		department := &d.Departments[i].Subject
+		for _, g := range d.Departments[i].Groups {
+			groups = append(groups, GroupSource{SubjectGroup: g, Department: department})
+		}
+	}
+	return groups
+}
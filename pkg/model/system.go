@@ -0,0 +1,128 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package model
+
+// System is the system registered in IAM
+type System struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	NameEn         string          `json:"name_en"`
+	Description    string          `json:"description"`
+	DescriptionEn  string          `json:"description_en"`
+	Clients        string          `json:"clients"`
+	ProviderConfig *ProviderConfig `json:"provider_config,omitempty"`
+}
+
+// ProviderConfig is the config of the system/resource type to call back the system
+type ProviderConfig struct {
+	Host    string `json:"host,omitempty"`
+	Auth    string `json:"auth,omitempty"`
+	Healthz string `json:"healthz,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// SystemModel is the permission model of the system, returned by /api/v1/debug/query/model
+type SystemModel struct {
+	System             System              `json:"system"`
+	Actions            []Action            `json:"actions"`
+	ResourceTypes      []ResourceType      `json:"resource_types"`
+	InstanceSelections []InstanceSelection `json:"instance_selections"`
+}
+
+// Action is the action of the system
+type Action struct {
+	// NOTE: the pk is only returned by the cache api, the query api returns the pks separately
+	PK int64 `json:"pk,omitempty"`
+
+	ID                   string                `json:"id"`
+	Name                 string                `json:"name"`
+	NameEn               string                `json:"name_en"`
+	Description          string                `json:"description"`
+	Type                 string                `json:"type"`
+	Version              int64                 `json:"version"`
+	AuthType             string                `json:"auth_type"`
+	RelatedResourceTypes []RelatedResourceType `json:"related_resource_types"`
+	RelatedActions       []string              `json:"related_actions"`
+}
+
+// RelatedResourceType is the resource type the action depends on
+type RelatedResourceType struct {
+	System        string `json:"system_id"`
+	ID            string `json:"id"`
+	SelectionMode string `json:"selection_mode"`
+
+	InstanceSelections []ResourceTypeRef `json:"related_instance_selections"`
+}
+
+// ResourceTypeRef refers to a resource type or an instance selection of a system
+type ResourceTypeRef struct {
+	System string `json:"system_id"`
+	ID     string `json:"id"`
+}
+
+// ResourceType is the resource type of the system
+type ResourceType struct {
+	ID             string            `json:"id"`
+	Name           string            `json:"name"`
+	NameEn         string            `json:"name_en"`
+	Description    string            `json:"description"`
+	Parents        []ResourceTypeRef `json:"parents"`
+	ProviderConfig *ProviderConfig   `json:"provider_config,omitempty"`
+	Version        int64             `json:"version"`
+}
+
+// InstanceSelection is the view for the user to select the resource instances
+type InstanceSelection struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	NameEn            string            `json:"name_en"`
+	IsDynamic         bool              `json:"is_dynamic"`
+	ResourceTypeChain []ResourceTypeRef `json:"resource_type_chain"`
+}
+
+// ActionList is the actions of the system, returned by /api/v1/debug/query/action
+type ActionList struct {
+	Actions []Action `json:"actions"`
+	// key is the action id
+	PKs map[string]int64 `json:"pks"`
+}
+
+// PK returns the pk of the action, 0 if not found
+func (l *ActionList) PK(actionID string) int64 {
+	if pk, ok := l.PKs[actionID]; ok {
+		return pk
+	}
+	for _, a := range l.Actions {
+		if a.ID == actionID {
+			return a.PK
+		}
+	}
+	return 0
+}
+
+// IDs returns the ids of all the actions
+func (l *ActionList) IDs() []string {
+	ids := make([]string, 0, len(l.Actions))
+	for _, a := range l.Actions {
+		ids = append(ids, a.ID)
+	}
+	return ids
+}
+
+// VersionInfo is the version of the IAM backend, returned by /version
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+	Env       string `json:"env,omitempty"`
+}
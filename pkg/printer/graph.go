@@ -0,0 +1,90 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package printer
+
+import (
+	"fmt"
+	"io"
+)
+
+// GraphNode is the node of the graph, e.g. the action
+type GraphNode struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	// the node is referred but not defined, e.g. the related action not in the model
+	Missing bool `json:"missing,omitempty"`
+}
+
+// GraphEdge is the directed edge of the graph, e.g. the action depends on the related action
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is the directed graph printed in dot/mermaid
+type Graph struct {
+	Name  string      `json:"name"`
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// PrintGraph prints the graph in dot or mermaid
+func PrintGraph(w io.Writer, g *Graph, format string) error {
+	switch format {
+	case TreeFormatDot, "":
+		printDotGraph(w, g)
+	case TreeFormatMermaid:
+		printMermaidGraph(w, g)
+	default:
+		return fmt.Errorf("unsupported graph format `%s`, should be dot or mermaid", format)
+	}
+	return nil
+}
+
+func printDotGraph(w io.Writer, g *Graph) {
+	fmt.Fprintf(w, "digraph %s {\n", dotQuote(g.Name))
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	for _, n := range g.Nodes {
+		style := ""
+		if n.Missing {
+			style = ", style=dashed, color=red, fontcolor=red"
+		}
+		fmt.Fprintf(w, "  %s [label=%s%s];\n", dotQuote(n.ID), dotQuote(n.Label), style)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "  %s -> %s;\n", dotQuote(e.From), dotQuote(e.To))
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func printMermaidGraph(w io.Writer, g *Graph) {
+	fmt.Fprintln(w, "graph LR")
+
+	// the id in mermaid should be simple, use the index
+	ids := make(map[string]string, len(g.Nodes))
+	missing := false
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(w, "  %s[%s]\n", ids[n.ID], mermaidQuote(n.Label))
+		if n.Missing {
+			fmt.Fprintf(w, "  class %s missing\n", ids[n.ID])
+			missing = true
+		}
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "  %s --> %s\n", ids[e.From], ids[e.To])
+	}
+	if missing {
+		fmt.Fprintln(w, "  classDef missing stroke:#f00,stroke-dasharray:5 5,color:#f00")
+	}
+}
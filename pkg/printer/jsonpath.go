@@ -0,0 +1,494 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a subset of the kubectl jsonpath template, e.g.
+//
+//	{.subject.id}
+//	{.groups[*].pk}
+//	{.departments[0].groups[*].policy_expired_at}
+//	{range .groups[*]}{.pk}{"\t"}{.policy_expired_at}{"\n"}{end}
+//	{.groups[?(@.policy_expired_at < 4102444800)].pk}
+type JSONPath struct {
+	nodes []node
+}
+
+type nodeType int
+
+const (
+	nodeText nodeType = iota
+	nodeField
+	nodeRange
+	nodeEnd
+)
+
+type node struct {
+	typ nodeType
+
+	// the literal text of nodeText
+	text string
+	// the path segments of nodeField/nodeRange
+	segments []segment
+	// the children of nodeRange
+	children []node
+}
+
+type segmentType int
+
+const (
+	segmentKey segmentType = iota
+	segmentIndex
+	segmentWildcard
+	segmentFilter
+)
+
+type segment struct {
+	typ    segmentType
+	key    string
+	index  int
+	filter *filter
+}
+
+// filter is the [?(@.key op value)] of the list, only the existence is checked if op is empty, e.g. [?(@.key)]
+type filter struct {
+	path  []segment
+	op    string
+	value interface{}
+}
+
+// filterOps are matched in order, the two chars ones first
+var filterOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// indexClose returns the index of the close char outside the quoted strings, -1 if not found,
+// e.g. the } in {.items[?(@.name=="}")]} is part of the string literal
+func indexClose(text string, close byte) int {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == close:
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseJSONPath parses the jsonpath template
+func ParseJSONPath(text string) (*JSONPath, error) {
+	flat := make([]node, 0)
+
+	for len(text) > 0 {
+		start := strings.Index(text, "{")
+		if start == -1 {
+			flat = append(flat, node{typ: nodeText, text: unescape(text)})
+			break
+		}
+		if start > 0 {
+			flat = append(flat, node{typ: nodeText, text: unescape(text[:start])})
+		}
+
+		end := indexClose(text[start:], '}')
+		if end == -1 {
+			return nil, fmt.Errorf("invalid jsonpath `%s`, unclosed {", text)
+		}
+		expr := strings.TrimSpace(text[start+1 : start+end])
+		text = text[start+end+1:]
+
+		n, err := parseExpr(expr)
+		if err != nil {
+			return nil, err
+		}
+		flat = append(flat, n)
+	}
+
+	nodes, rest, err := buildTree(flat)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("invalid jsonpath, unexpected {end}")
+	}
+
+	return &JSONPath{nodes: nodes}, nil
+}
+
+func parseExpr(expr string) (node, error) {
+	switch {
+	case expr == "end":
+		return node{typ: nodeEnd}, nil
+	case strings.HasPrefix(expr, "range "):
+		segments, err := parseSegments(strings.TrimSpace(strings.TrimPrefix(expr, "range ")))
+		if err != nil {
+			return node{}, err
+		}
+		return node{typ: nodeRange, segments: segments}, nil
+	case strings.HasPrefix(expr, `"`):
+		s, err := strconv.Unquote(expr)
+		if err != nil {
+			return node{}, fmt.Errorf("invalid jsonpath string literal %s", expr)
+		}
+		return node{typ: nodeText, text: s}, nil
+	default:
+		segments, err := parseSegments(expr)
+		if err != nil {
+			return node{}, err
+		}
+		return node{typ: nodeField, segments: segments}, nil
+	}
+}
+
+// buildTree nests the nodes between {range} and {end}, returns the nodes after the matched {end}
+func buildTree(flat []node) (nodes []node, rest []node, err error) {
+	nodes = make([]node, 0, len(flat))
+	for len(flat) > 0 {
+		n := flat[0]
+		flat = flat[1:]
+
+		switch n.typ {
+		case nodeEnd:
+			return nodes, append([]node{n}, flat...), nil
+		case nodeRange:
+			var children []node
+			children, flat, err = buildTree(flat)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(flat) == 0 {
+				return nil, nil, fmt.Errorf("invalid jsonpath, {range} without {end}")
+			}
+			// skip the {end}
+			flat = flat[1:]
+			n.children = children
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil, nil
+}
+
+func parseSegments(expr string) ([]segment, error) {
+	expr = strings.TrimPrefix(expr, "$")
+
+	segments := make([]segment, 0)
+	for len(expr) > 0 {
+		switch expr[0] {
+		case '.':
+			expr = expr[1:]
+			if expr == "" {
+				break
+			}
+			if expr[0] == '*' {
+				segments = append(segments, segment{typ: segmentWildcard})
+				expr = expr[1:]
+				continue
+			}
+
+			end := strings.IndexAny(expr, ".[")
+			if end == -1 {
+				end = len(expr)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid jsonpath `%s`, empty field", expr)
+			}
+			segments = append(segments, segment{typ: segmentKey, key: expr[:end]})
+			expr = expr[end:]
+		case '[':
+			end := indexClose(expr, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid jsonpath `%s`, unclosed [", expr)
+			}
+			inner := strings.TrimSpace(expr[1:end])
+			expr = expr[end+1:]
+
+			switch {
+			case inner == "*":
+				segments = append(segments, segment{typ: segmentWildcard})
+			case strings.HasPrefix(inner, "?(") && strings.HasSuffix(inner, ")"):
+				f, err := parseFilter(strings.TrimSpace(inner[2 : len(inner)-1]))
+				if err != nil {
+					return nil, err
+				}
+				segments = append(segments, segment{typ: segmentFilter, filter: f})
+			case strings.HasPrefix(inner, "'") && strings.HasSuffix(inner, "'") && len(inner) >= 2:
+				segments = append(segments, segment{typ: segmentKey, key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid jsonpath index [%s]", inner)
+				}
+				segments = append(segments, segment{typ: segmentIndex, index: index})
+			}
+		default:
+			return nil, fmt.Errorf("invalid jsonpath `%s`, should start with . or [", expr)
+		}
+	}
+	return segments, nil
+}
+
+func parseFilter(expr string) (*filter, error) {
+	left, op, right := expr, "", ""
+	for i := 0; i < len(expr) && op == ""; i++ {
+		if c := expr[i]; c == '"' || c == '\'' {
+			// skip the string literal
+			end := indexClose(expr[i+1:], c)
+			if end == -1 {
+				return nil, fmt.Errorf("invalid jsonpath filter `%s`, unclosed %c", expr, c)
+			}
+			i += end + 1
+			continue
+		}
+		for _, o := range filterOps {
+			if strings.HasPrefix(expr[i:], o) {
+				left, op, right = strings.TrimSpace(expr[:i]), o, strings.TrimSpace(expr[i+len(o):])
+				break
+			}
+		}
+	}
+
+	if !strings.HasPrefix(left, "@") {
+		return nil, fmt.Errorf("invalid jsonpath filter `%s`, should be like ?(@.key == value)", expr)
+	}
+	path, err := parseSegments(strings.TrimPrefix(left, "@"))
+	if err != nil {
+		return nil, err
+	}
+	f := &filter{path: path, op: op}
+	if op == "" {
+		return f, nil
+	}
+
+	switch {
+	case right == "":
+		return nil, fmt.Errorf("invalid jsonpath filter `%s`, value required", expr)
+	case strings.HasPrefix(right, `"`):
+		if f.value, err = strconv.Unquote(right); err != nil {
+			return nil, fmt.Errorf("invalid jsonpath string literal %s", right)
+		}
+	case strings.HasPrefix(right, "'"):
+		if len(right) < 2 || !strings.HasSuffix(right, "'") {
+			return nil, fmt.Errorf("invalid jsonpath string literal %s", right)
+		}
+		f.value = right[1 : len(right)-1]
+	case right == "true" || right == "false":
+		f.value = right == "true"
+	case right == "null":
+		f.value = nil
+	default:
+		n, err := strconv.ParseFloat(right, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jsonpath filter value %s, should be a string, number, bool or null", right)
+		}
+		f.value = n
+	}
+	return f, nil
+}
+
+// match returns true if the item matches the filter, the numbers are compared as number, the strings exactly
+func (f *filter) match(item interface{}) bool {
+	values := lookup(item, f.path)
+	if f.op == "" {
+		return len(values) > 0 && values[0] != nil
+	}
+	if len(values) == 0 {
+		return false
+	}
+
+	v := values[0]
+	c, ordered := 0, true
+	if x, ok := toFloat(v); ok {
+		y, ok := toFloat(f.value)
+		if !ok {
+			return f.op == "!="
+		}
+		c = compareFloat(x, y)
+	} else if x, ok := v.(string); ok {
+		y, ok := f.value.(string)
+		if !ok {
+			return f.op == "!="
+		}
+		c = strings.Compare(x, y)
+	} else {
+		// bool or null only ==/!=, the map/list never equal
+		ordered = false
+		switch v.(type) {
+		case bool, nil:
+			if v != f.value {
+				c = 1
+			}
+		default:
+			c = 1
+		}
+	}
+
+	switch f.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return ordered && c < 0
+	case "<=":
+		return ordered && c <= 0
+	case ">":
+		return ordered && c > 0
+	default:
+		return ordered && c >= 0
+	}
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	}
+	return 0, false
+}
+
+func compareFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func unescape(text string) string {
+	return strings.NewReplacer(`\n`, "\n", `\t`, "\t").Replace(text)
+}
+
+// Execute evaluates the jsonpath against the data and writes the result into w
+func (j *JSONPath) Execute(w io.Writer, data interface{}) error {
+	var sb strings.Builder
+	err := executeNodes(&sb, j.nodes, data)
+	if err != nil {
+		return err
+	}
+
+	out := sb.String()
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	_, err = io.WriteString(w, out)
+	return err
+}
+
+func executeNodes(sb *strings.Builder, nodes []node, data interface{}) error {
+	for _, n := range nodes {
+		switch n.typ {
+		case nodeText:
+			sb.WriteString(n.text)
+		case nodeField:
+			values := lookup(data, n.segments)
+			texts := make([]string, 0, len(values))
+			for _, v := range values {
+				texts = append(texts, toText(v))
+			}
+			sb.WriteString(strings.Join(texts, " "))
+		case nodeRange:
+			for _, v := range lookup(data, n.segments) {
+				err := executeNodes(sb, n.children, v)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func lookup(data interface{}, segments []segment) []interface{} {
+	current := []interface{}{data}
+	for _, seg := range segments {
+		next := make([]interface{}, 0, len(current))
+		for _, v := range current {
+			switch seg.typ {
+			case segmentKey:
+				if m, ok := v.(map[string]interface{}); ok {
+					if value, ok := m[seg.key]; ok {
+						next = append(next, value)
+					}
+				}
+			case segmentIndex:
+				if l, ok := v.([]interface{}); ok {
+					index := seg.index
+					if index < 0 {
+						index += len(l)
+					}
+					if index >= 0 && index < len(l) {
+						next = append(next, l[index])
+					}
+				}
+			case segmentFilter:
+				if l, ok := v.([]interface{}); ok {
+					for _, item := range l {
+						if seg.filter.match(item) {
+							next = append(next, item)
+						}
+					}
+				}
+			case segmentWildcard:
+				switch x := v.(type) {
+				case []interface{}:
+					next = append(next, x...)
+				case map[string]interface{}:
+					keys := make([]string, 0, len(x))
+					for key := range x {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, x[key])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+// toText returns the text of the value, the map/list is returned as compact json
+func toText(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(x)
+		return string(b)
+	default:
+		return fmt.Sprint(x)
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package printer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONPath(t *testing.T) {
+	data, err := normalize(map[string]interface{}{
+		"subject": map[string]string{"type": "user", "id": "tom"},
+		"items": []map[string]interface{}{
+			{"name": "}", "pk": 1, "expired_at": 100, "enabled": true},
+			{"name": "a]b", "pk": 2, "expired_at": 200, "enabled": false},
+			{"name": "c", "pk": 3, "expired_at": 300, "tags": []string{"x"}},
+		},
+		"map": map[string]int{"b": 2, "a": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		template string
+		want     string
+	}{
+		{"{.subject.id}", "tom"},
+		{"{$.subject['type']}", "user"},
+		{"{.items[*].pk}", "1 2 3"},
+		{"{.items[0].name}", "}"},
+		{"{.items[-1].pk}", "3"},
+		{"{.items[5].pk}", ""},
+		{"{.missing.key}", ""},
+		{"{.map.*}", "1 2"},
+		{"{.items[2].tags}", `["x"]`},
+		{"id: {.subject.id}", "id: tom"},
+		{`{range .items[*]}{.pk}{"\t"}{.name}{"\n"}{end}`, "1\t}\n2\ta]b\n3\tc"},
+		{`{range .items[*]}{range .tags[*]}{.}{end}{end}`, "x"},
+		// the close chars in the string literals
+		{`{.items[?(@.name=="}")].pk}`, "1"},
+		{`{.items[?(@.name=='a]b')].pk}`, "2"},
+		{`{.items[?(@.name!="}")].pk}`, "2 3"},
+		{`{.items[?(@.expired_at < 200)].pk}`, "1"},
+		{`{.items[?(@.expired_at<=200)].pk}`, "1 2"},
+		{`{.items[?(@.expired_at > 100)].pk}`, "2 3"},
+		{`{.items[?(@.expired_at >= 300)].pk}`, "3"},
+		{`{.items[?(@.enabled == true)].pk}`, "1"},
+		{`{.items[?(@.tags)].pk}`, "3"},
+		// never equal between the number and string
+		{`{.items[?(@.pk == "1")].pk}`, ""},
+	}
+	for _, c := range cases {
+		jp, err := ParseJSONPath(c.template)
+		if err != nil {
+			t.Errorf("ParseJSONPath(%s): %v", c.template, err)
+			continue
+		}
+		var sb strings.Builder
+		if err = jp.Execute(&sb, data); err != nil {
+			t.Errorf("Execute(%s): %v", c.template, err)
+			continue
+		}
+		if got := strings.TrimSuffix(sb.String(), "\n"); got != c.want {
+			t.Errorf("Execute(%s) = %q, want %q", c.template, got, c.want)
+		}
+	}
+}
+
+func TestJSONPathInvalid(t *testing.T) {
+	for _, template := range []string{
+		"{.a",
+		`{.items[?(@.name=="}")}`,
+		"{.items[0}",
+		"{.items[x]}",
+		"{range .items[*]}{.pk}",
+		"{.pk}{end}",
+		`{"unclosed}`,
+		"{a}",
+		"{.items[?(name == 1)]}",
+		"{.items[?(@.name == )]}",
+		"{.items[?(@.name == abc)]}",
+	} {
+		if _, err := ParseJSONPath(template); err == nil {
+			t.Errorf("ParseJSONPath(%s) should fail", template)
+		}
+	}
+}
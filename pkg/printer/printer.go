@@ -0,0 +1,232 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/TylerBrock/colorjson"
+	"github.com/mattn/go-isatty"
+	"gopkg.in/yaml.v2"
+)
+
+// Format is the output format, set via -o/--output
+type Format string
+
+const (
+	// FormatDefault is colorized json if stdout is a terminal, else plain json
+	FormatDefault    Format = ""
+	FormatJSON       Format = "json"
+	FormatYAML       Format = "yaml"
+	FormatTable      Format = "table"
+	FormatJSONPath   Format = "jsonpath"
+	FormatGoTemplate Format = "go-template"
+)
+
+// SupportedFormats is used in the help message of the -o/--output flag
+const SupportedFormats = "json|yaml|table|jsonpath=<expr>|go-template=<template>"
+
+// IsTerminal returns true if the file is a terminal, the color will be disabled if stdout is not a terminal
+func IsTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// Printer prints the data in the specified format
+type Printer struct {
+	format Format
+	color  bool
+
+	jsonPath *JSONPath
+	template *template.Template
+}
+
+// New parses the output(e.g. `yaml`, `jsonpath={.id}`) and returns a printer
+func New(output string) (*Printer, error) {
+	p := &Printer{
+		color: IsTerminal(os.Stdout),
+	}
+
+	name, arg := output, ""
+	if idx := strings.Index(output, "="); idx != -1 {
+		name, arg = output[:idx], output[idx+1:]
+	}
+
+	p.format = Format(name)
+
+	switch p.format {
+	case FormatDefault, FormatJSON, FormatYAML, FormatTable:
+		if arg != "" {
+			return nil, fmt.Errorf("output format `%s` does not accept argument", name)
+		}
+	case FormatJSONPath:
+		if arg == "" {
+			return nil, fmt.Errorf("jsonpath expression required, e.g. -o jsonpath='{.subject.id}'")
+		}
+		jp, err := ParseJSONPath(arg)
+		if err != nil {
+			return nil, err
+		}
+		p.jsonPath = jp
+	case FormatGoTemplate:
+		if arg == "" {
+			return nil, fmt.Errorf("template required, e.g. -o go-template='{{.subject.id}}'")
+		}
+		t, err := template.New("output").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("parse go-template fail! %w", err)
+		}
+		p.template = t
+	default:
+		return nil, fmt.Errorf("output format `%s` not supported, should be one of %s", name, SupportedFormats)
+	}
+
+	return p, nil
+}
+
+// Format returns the format of the printer
+func (p *Printer) Format() Format {
+	return p.format
+}
+
+// Print prints the obj into w, the kind is used to pick the columns of table
+func (p *Printer) Print(w io.Writer, kind Kind, obj interface{}) error {
+	switch p.format {
+	case FormatJSON:
+		return printJSON(w, obj)
+	case FormatYAML:
+		return printYAML(w, obj)
+	case FormatTable:
+		data, err := normalize(obj)
+		if err != nil {
+			return err
+		}
+		return printTable(w, kind, data)
+	case FormatJSONPath:
+		data, err := normalize(obj)
+		if err != nil {
+			return err
+		}
+		return p.jsonPath.Execute(w, data)
+	case FormatGoTemplate:
+		data, err := normalize(obj)
+		if err != nil {
+			return err
+		}
+		if err = p.template.Execute(w, data); err != nil {
+			return fmt.Errorf("execute go-template fail! %w", err)
+		}
+		_, err = fmt.Fprintln(w)
+		return err
+	default:
+		if p.color {
+			return printColorJSON(w, obj)
+		}
+		return printJSON(w, obj)
+	}
+}
+
+// normalize converts the obj(maybe a struct) into the generic json types(map[string]interface{}/[]interface{}/...),
+// the integers are kept as int64 instead of float64, e.g. the timestamps will not be printed as 1.642493707e+09
+func normalize(obj interface{}) (interface{}, error) {
+	data, err := decodeWithNumber(obj)
+	if err != nil {
+		return nil, err
+	}
+	return convertNumbers(data), nil
+}
+
+func decodeWithNumber(obj interface{}) (interface{}, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("marshal data fail! %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	var data interface{}
+	err = decoder.Decode(&data)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal data fail! %w", err)
+	}
+	return data, nil
+}
+
+func convertNumbers(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = convertNumbers(value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = convertNumbers(value)
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	default:
+		return v
+	}
+}
+
+func printJSON(w io.Writer, obj interface{}) error {
+	b, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal json fail! %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func printColorJSON(w io.Writer, obj interface{}) error {
+	// NOTE: colorjson only supports the generic json types
+	data, err := decodeWithNumber(obj)
+	if err != nil {
+		return err
+	}
+
+	f := colorjson.NewFormatter()
+	f.Indent = 2
+
+	s, err := f.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal json fail! %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(s))
+	return err
+}
+
+func printYAML(w io.Writer, obj interface{}) error {
+	data, err := normalize(obj)
+	if err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshal yaml fail! %w", err)
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,71 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package printer
+
+import (
+	"strings"
+	"testing"
+)
+
+var testPolicies = map[string]interface{}{
+	"count": 2,
+	"results": []map[string]interface{}{
+		{"id": 1, "subject": map[string]string{"type": "user", "id": "tom"}, "expired_at": 4102444800},
+		{"id": 2, "subject": map[string]string{"type": "group", "id": "1"}, "expression": "any"},
+	},
+}
+
+func TestPrint(t *testing.T) {
+	cases := []struct {
+		output string
+		kind   Kind
+		data   interface{}
+		want   string
+	}{
+		{"", KindUnknown, map[string]int{"id": 1}, "{\n  \"id\": 1\n}\n"},
+		{"json", KindUnknown, []string{"a"}, "[\n  \"a\"\n]\n"},
+		// the integers are not printed as float
+		{"yaml", KindUnknown, map[string]interface{}{"ts": 1642493707, "ids": []int{1}}, "ids:\n- 1\nts: 1642493707\n"},
+		{"table", KindPolicyList, testPolicies, "ID  SUBJECT   EXPIRED AT           EXPRESSION\n" +
+			"1   user:tom  " + FormatTimestamp(int64(4102444800)) + "  -\n" +
+			"2   group:1   -                    any\n"},
+		// the generic table of unknown kind
+		{"table", KindUnknown, []map[string]int{{"b": 2, "a": 1}}, "A  B\n1  2\n"},
+		{"table", KindUnknown, map[string]string{"id": "tom"}, "KEY  VALUE\nid   tom\n"},
+		{"jsonpath={.results[*].id}", KindUnknown, testPolicies, "1 2\n"},
+		{"go-template={{range .results}}{{.id}},{{end}}", KindUnknown, testPolicies, "1,2,\n"},
+	}
+	for _, c := range cases {
+		p, err := New(c.output)
+		if err != nil {
+			t.Fatalf("New(%s): %v", c.output, err)
+		}
+		p.color = false
+
+		var sb strings.Builder
+		if err = p.Print(&sb, c.kind, c.data); err != nil {
+			t.Errorf("Print -o %s: %v", c.output, err)
+			continue
+		}
+		if sb.String() != c.want {
+			t.Errorf("Print -o %s:\n%q\nwant\n%q", c.output, sb.String(), c.want)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, output := range []string{"xml", "json=x", "jsonpath", "jsonpath={.a", "go-template", "go-template={{.a"} {
+		if _, err := New(output); err == nil {
+			t.Errorf("New(%s) should fail", output)
+		}
+	}
+}
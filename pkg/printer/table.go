@@ -0,0 +1,415 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package printer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Kind is the kind of the data, used to pick the columns of table
+type Kind string
+
+const (
+	KindUnknown         Kind = ""
+	KindSystem          Kind = "system"
+	KindModel           Kind = "model"
+	KindAction          Kind = "action"
+	KindSubject         Kind = "subject"
+	KindPolicy          Kind = "policy"
+	KindPolicyList      Kind = "policy_list"
+	KindPolicySubjects  Kind = "policy_subjects"
+	KindCachePolicy     Kind = "cache_policy"
+	KindCacheExpression Kind = "cache_expression"
+	KindVersion         Kind = "version"
+	KindDebugList       Kind = "debug_list"
+	KindDebug           Kind = "debug"
+	KindStatus          Kind = "status"
+	KindExpiry          Kind = "expiry"
+
+	KindModelActions            Kind = "model_actions"
+	KindModelResourceTypes      Kind = "model_resource_types"
+	KindModelInstanceSelections Kind = "model_instance_selections"
+	KindLintRules               Kind = "lint_rules"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// Column is a column of the table
+type Column struct {
+	Header string
+	Value  func(row map[string]interface{}) string
+}
+
+// Table defines how to print a kind of data as table
+type Table struct {
+	// Rows returns the rows from the data, return nil if the data is not as expected, then fallback to the generic table
+	Rows    func(data interface{}) []map[string]interface{}
+	Columns []Column
+}
+
+var tables = map[Kind]Table{
+	KindSystem: {
+		Rows: rowsOf(""),
+		Columns: []Column{
+			field("ID", "id"),
+			field("NAME", "name"),
+			field("NAME_EN", "name_en"),
+			field("CLIENTS", "clients"),
+		},
+	},
+	KindAction: {
+		Rows: actionRows,
+		Columns: []Column{
+			field("ID", "id"),
+			field("NAME", "name"),
+			field("PK", "pk"),
+		},
+	},
+	KindSubject: {
+		Rows: subjectGroupRows,
+		Columns: []Column{
+			field("FROM", "from"),
+			field("GROUP PK", "pk"),
+			timestamp("EXPIRED AT", "policy_expired_at"),
+		},
+	},
+	KindPolicyList: {
+		Rows: rowsOf("results"),
+		Columns: []Column{
+			field("ID", "id"),
+			subject("SUBJECT", "subject"),
+			timestamp("EXPIRED AT", "expired_at"),
+			field("EXPRESSION", "expression"),
+		},
+	},
+	KindPolicySubjects: {
+		Rows: rowsOf(""),
+		Columns: []Column{
+			field("ID", "id"),
+			subject("SUBJECT", "subject"),
+		},
+	},
+	KindCachePolicy: {
+		Rows: rowsOf("actions"),
+		Columns: []Column{
+			field("SYSTEM", "system"),
+			field("ACTION", "id"),
+			field("ACTION PK", "pk"),
+		},
+	},
+	KindCacheExpression: {
+		Rows: rowsOf("expressions"),
+		Columns: []Column{
+			field("PK", "pk"),
+			field("EXPRESSION", "expression"),
+		},
+	},
+	KindDebugList: {
+		Rows: rowsOf(""),
+		Columns: []Column{
+			field("ID", "id"),
+			field("TYPE", "type"),
+			field("NAME", "name"),
+			field("PATH", "path"),
+			field("EXC", "exc"),
+		},
+	},
+	KindStatus: {
+		Rows: rowsOf("credentials"),
+		Columns: []Column{
+			field("NAME", "name"),
+			field("HOST", "host"),
+			field("APP CODE", "app_code"),
+			field("SYSTEM", "system"),
+			timestamp("EXPIRED AT", "expired_at"),
+			field("REMAINING", "remaining"),
+			field("SOURCE", "source"),
+			field("STATUS", "status"),
+			field("ERROR", "error"),
+		},
+	},
+	KindExpiry: {
+		Rows: rowsOf("memberships"),
+		Columns: []Column{
+			field("SUBJECT", "subject"),
+			field("GROUP PK", "group_pk"),
+			field("FROM", "from"),
+			timestamp("EXPIRED AT", "expired_at"),
+			field("REMAINING", "remaining"),
+			field("STATUS", "status"),
+		},
+	},
+	KindModelActions: {
+		Rows: rowsOf(""),
+		Columns: []Column{
+			field("ID", "id"),
+			field("PK", "pk"),
+			field("NAME", "name"),
+			field("TYPE", "type"),
+			field("AUTH TYPE", "auth_type"),
+			list("RELATED RESOURCE TYPES", "related_resource_types"),
+			list("RELATED ACTIONS", "related_actions"),
+		},
+	},
+	KindModelResourceTypes: {
+		Rows: rowsOf(""),
+		Columns: []Column{
+			field("ID", "id"),
+			field("NAME", "name"),
+			field("NAME_EN", "name_en"),
+			list("PARENTS", "parents"),
+			field("PATH", "path"),
+		},
+	},
+	KindModelInstanceSelections: {
+		Rows: rowsOf(""),
+		Columns: []Column{
+			field("ID", "id"),
+			field("NAME", "name"),
+			field("DYNAMIC", "is_dynamic"),
+			field("RESOURCE TYPE CHAIN", "resource_type_chain"),
+		},
+	},
+	KindLintRules: {
+		Rows: rowsOf(""),
+		Columns: []Column{
+			field("NAME", "name"),
+			field("SEVERITY", "severity"),
+			field("DESCRIPTION", "description"),
+		},
+	},
+}
+
+// rowsOf returns the list of maps under the key, the data itself if key is empty
+func rowsOf(key string) func(data interface{}) []map[string]interface{} {
+	return func(data interface{}) []map[string]interface{} {
+		if key != "" {
+			m, ok := data.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			data = getField(m, key)
+		}
+		return toRows(data)
+	}
+}
+
+func toRows(data interface{}) []map[string]interface{} {
+	list, ok := data.([]interface{})
+	if !ok {
+		return nil
+	}
+	rows := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		rows = append(rows, m)
+	}
+	return rows
+}
+
+// actionRows returns the actions, fill the pk from the `pks` if the action has no pk
+func actionRows(data interface{}) []map[string]interface{} {
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return toRows(data)
+	}
+
+	rows := toRows(getField(m, "actions"))
+	pks, _ := getField(m, "pks").(map[string]interface{})
+	for _, row := range rows {
+		if getField(row, "pk") != nil || pks == nil {
+			continue
+		}
+		row["pk"] = pks[toText(getField(row, "id"))]
+	}
+	return rows
+}
+
+// subjectGroupRows flattens the direct groups and the groups of the departments
+func subjectGroupRows(data interface{}) []map[string]interface{} {
+	m, ok := data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	rows := make([]map[string]interface{}, 0)
+	for _, g := range toRows(getField(m, "groups")) {
+		g["from"] = "direct"
+		rows = append(rows, g)
+	}
+	for _, d := range toRows(getField(m, "departments")) {
+		from := fmt.Sprintf("department %s(%s)", toText(getField(d, "name")), toText(getField(d, "id")))
+		for _, g := range toRows(getField(d, "groups")) {
+			g["from"] = from
+			rows = append(rows, g)
+		}
+	}
+	return rows
+}
+
+// getField returns the value of the key, the key is case-insensitive, e.g. `id` matches `ID`
+func getField(m map[string]interface{}, key string) interface{} {
+	if v, ok := m[key]; ok {
+		return v
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return nil
+}
+
+func field(header, key string) Column {
+	return Column{
+		Header: header,
+		Value: func(row map[string]interface{}) string {
+			return toText(getField(row, key))
+		},
+	}
+}
+
+// list joins the values of the list by comma
+func list(header, key string) Column {
+	return Column{
+		Header: header,
+		Value: func(row map[string]interface{}) string {
+			values, ok := getField(row, key).([]interface{})
+			if !ok {
+				return toText(getField(row, key))
+			}
+			texts := make([]string, 0, len(values))
+			for _, v := range values {
+				texts = append(texts, toText(v))
+			}
+			return strings.Join(texts, ", ")
+		},
+	}
+}
+
+func timestamp(header, key string) Column {
+	return Column{
+		Header: header,
+		Value: func(row map[string]interface{}) string {
+			return FormatTimestamp(getField(row, key))
+		},
+	}
+}
+
+func subject(header, key string) Column {
+	return Column{
+		Header: header,
+		Value: func(row map[string]interface{}) string {
+			s, ok := getField(row, key).(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			return fmt.Sprintf("%s:%s", toText(getField(s, "type")), toText(getField(s, "id")))
+		},
+	}
+}
+
+// FormatTimestamp formats the unix timestamp into local time
+func FormatTimestamp(v interface{}) string {
+	var ts int64
+	switch x := v.(type) {
+	case int64:
+		ts = x
+	case float64:
+		ts = int64(x)
+	default:
+		return toText(v)
+	}
+	return time.Unix(ts, 0).Format(timeLayout)
+}
+
+func printTable(w io.Writer, kind Kind, data interface{}) error {
+	if t, ok := tables[kind]; ok {
+		if rows := t.Rows(data); rows != nil {
+			return writeTable(w, t.Columns, rows)
+		}
+	}
+	return printGenericTable(w, data)
+}
+
+// printGenericTable prints a list of objects with all the keys as columns, or an object as KEY/VALUE
+func printGenericTable(w io.Writer, data interface{}) error {
+	if rows := toRows(data); rows != nil {
+		keySet := map[string]struct{}{}
+		for _, row := range rows {
+			for key := range row {
+				keySet[key] = struct{}{}
+			}
+		}
+		keys := make([]string, 0, len(keySet))
+		for key := range keySet {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		columns := make([]Column, 0, len(keys))
+		for _, key := range keys {
+			columns = append(columns, field(strings.ToUpper(key), key))
+		}
+		return writeTable(w, columns, rows)
+	}
+
+	if m, ok := data.(map[string]interface{}); ok {
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		rows := make([]map[string]interface{}, 0, len(keys))
+		for _, key := range keys {
+			rows = append(rows, map[string]interface{}{"key": key, "value": m[key]})
+		}
+		return writeTable(w, []Column{field("KEY", "key"), field("VALUE", "value")}, rows)
+	}
+
+	_, err := fmt.Fprintln(w, toText(data))
+	return err
+}
+
+func writeTable(w io.Writer, columns []Column, rows []map[string]interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	headers := make([]string, 0, len(columns))
+	for _, c := range columns {
+		headers = append(headers, c.Header)
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+
+	for _, row := range rows {
+		values := make([]string, 0, len(columns))
+		for _, c := range columns {
+			v := c.Value(row)
+			if v == "" {
+				v = "-"
+			}
+			// NOTE: the tab and newline will break the table
+			v = strings.NewReplacer("\t", " ", "\n", " ").Replace(v)
+			values = append(values, v)
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,166 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package printer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gookit/color"
+)
+
+// the tree formats
+const (
+	TreeFormatASCII   = "ascii"
+	TreeFormatDot     = "dot"
+	TreeFormatMermaid = "mermaid"
+)
+
+// the status of the tree node, highlighted in the output
+const (
+	TreeStatusNormal  = ""
+	TreeStatusWarning = "warning"
+	TreeStatusExpired = "expired"
+	TreeStatusError   = "error"
+)
+
+// TreeNode is the node of the tree, e.g. the subject/department/group
+type TreeNode struct {
+	Label    string      `json:"label"`
+	Status   string      `json:"status,omitempty"`
+	Children []*TreeNode `json:"children,omitempty"`
+}
+
+// Add appends the child and returns it
+func (n *TreeNode) Add(label, status string) *TreeNode {
+	child := &TreeNode{Label: label, Status: status}
+	n.Children = append(n.Children, child)
+	return child
+}
+
+// PrintTree prints the tree in ascii/dot/mermaid, the ascii is colorized if colored
+func PrintTree(w io.Writer, root *TreeNode, format string, colored bool) error {
+	switch format {
+	case TreeFormatASCII, "":
+		printASCIITree(w, root, colored)
+	case TreeFormatDot:
+		printDotTree(w, root)
+	case TreeFormatMermaid:
+		printMermaidTree(w, root)
+	default:
+		return fmt.Errorf("unsupported tree format `%s`, should be ascii, dot or mermaid", format)
+	}
+	return nil
+}
+
+func printASCIITree(w io.Writer, root *TreeNode, colored bool) {
+	label := func(n *TreeNode) string {
+		if !colored {
+			return n.Label
+		}
+		switch n.Status {
+		case TreeStatusWarning:
+			return color.Yellow.Sprint(n.Label)
+		case TreeStatusExpired, TreeStatusError:
+			return color.Red.Sprint(n.Label)
+		}
+		return n.Label
+	}
+
+	var walk func(n *TreeNode, prefix string)
+	walk = func(n *TreeNode, prefix string) {
+		for i, child := range n.Children {
+			branch, indent := "├── ", "│   "
+			if i == len(n.Children)-1 {
+				branch, indent = "└── ", "    "
+			}
+			fmt.Fprintf(w, "%s%s%s\n", prefix, branch, label(child))
+			walk(child, prefix+indent)
+		}
+	}
+
+	fmt.Fprintln(w, label(root))
+	walk(root, "")
+}
+
+// walkTree visits the nodes in pre-order, the id is `n{index}`
+func walkTree(root *TreeNode, fn func(id string, n *TreeNode, parentID string)) {
+	index := 0
+	var walk func(n *TreeNode, parentID string)
+	walk = func(n *TreeNode, parentID string) {
+		id := fmt.Sprintf("n%d", index)
+		index++
+		fn(id, n, parentID)
+		for _, child := range n.Children {
+			walk(child, id)
+		}
+	}
+	walk(root, "")
+}
+
+var dotStyles = map[string]string{
+	TreeStatusWarning: `, style=filled, fillcolor="#fff3cd"`,
+	TreeStatusExpired: `, style=filled, fillcolor="#f8d7da"`,
+	TreeStatusError:   `, color=red, fontcolor=red`,
+}
+
+func printDotTree(w io.Writer, root *TreeNode) {
+	fmt.Fprintln(w, "digraph tree {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	walkTree(root, func(id string, n *TreeNode, parentID string) {
+		fmt.Fprintf(w, "  %s [label=%s%s];\n", id, dotQuote(n.Label), dotStyles[n.Status])
+		if parentID != "" {
+			fmt.Fprintf(w, "  %s -> %s;\n", parentID, id)
+		}
+	})
+	fmt.Fprintln(w, "}")
+}
+
+var mermaidStyles = map[string]string{
+	TreeStatusWarning: "fill:#fff3cd",
+	TreeStatusExpired: "fill:#f8d7da",
+	TreeStatusError:   "stroke:#f00,color:#f00",
+}
+
+func printMermaidTree(w io.Writer, root *TreeNode) {
+	fmt.Fprintln(w, "graph LR")
+
+	used := map[string]bool{}
+	walkTree(root, func(id string, n *TreeNode, parentID string) {
+		fmt.Fprintf(w, "  %s[%s]\n", id, mermaidQuote(n.Label))
+		if parentID != "" {
+			fmt.Fprintf(w, "  %s --> %s\n", parentID, id)
+		}
+		if n.Status != TreeStatusNormal {
+			fmt.Fprintf(w, "  class %s %s\n", id, n.Status)
+			used[n.Status] = true
+		}
+	})
+
+	for _, status := range []string{TreeStatusWarning, TreeStatusExpired, TreeStatusError} {
+		if used[status] {
+			fmt.Fprintf(w, "  classDef %s %s\n", status, mermaidStyles[status])
+		}
+	}
+}
+
+// dotQuote returns the quoted string in dot, the line breaks are kept
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// mermaidQuote returns the quoted label in mermaid, the line breaks are kept
+func mermaidQuote(s string) string {
+	return `"` + strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace(s) + `"`
+}
@@ -0,0 +1,53 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ReadConfig reads the yaml config file, returns empty config if not exists
+func ReadConfig(file string) (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, fmt.Errorf("read config fail! %w", err)
+	}
+
+	err = yaml.Unmarshal(dat, &config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s! %w", file, err)
+	}
+	return config, nil
+}
+
+// WriteConfig writes the yaml config file with 0600 permissions, it may contain the app_secret
+func WriteConfig(file string, config map[string]interface{}) error {
+	dat, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	err = writePrivateFile(file, dat)
+	if err != nil {
+		return fmt.Errorf("write config fail! %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	// DefaultContextName is the context used when no context is specified and no context is selected
+	DefaultContextName = "default"
+
+	rootDirName        = ".bk-iam-cli"
+	contextsDirName    = "contexts"
+	currentContextFile = "current-context"
+	shellHistoryFile   = "history"
+)
+
+var contextNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Context is a named IAM environment, all the files(credentials, use system) of a context
+// are stored under $HOME/.bk-iam-cli/contexts/{name}/
+type Context struct {
+	Name string
+
+	dir string
+}
+
+// Path returns the path of the file inside the context dir
+func (c *Context) Path(file string) string {
+	return filepath.Join(c.dir, file)
+}
+
+// Exists returns true if the context dir has been created
+func (c *Context) Exists() bool {
+	_, err := os.Stat(c.dir)
+	return err == nil
+}
+
+func rootDir() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("get home dir fail! %w", err)
+	}
+	return filepath.Join(home, rootDirName), nil
+}
+
+// ShellHistoryFile returns the path of the history file of the shell
+func ShellHistoryFile() (string, error) {
+	root, err := rootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, shellHistoryFile), nil
+}
+
+func contextsDir() (string, error) {
+	root, err := rootDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, contextsDirName), nil
+}
+
+func validateContextName(name string) error {
+	if !contextNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid context name `%s`, should match %s", name, contextNameRegex.String())
+	}
+	return nil
+}
+
+// NewContext returns the context of the name, the context may not exist yet
+func NewContext(name string) (*Context, error) {
+	if err := validateContextName(name); err != nil {
+		return nil, err
+	}
+
+	dir, err := contextsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Context{
+		Name: name,
+		dir:  filepath.Join(dir, name),
+	}, nil
+}
+
+// ResolveContext returns the active context:
+// the name specified (e.g. via --context) > the current context selected by `context use` > default
+func ResolveContext(name string) (*Context, error) {
+	if name != "" {
+		c, err := NewContext(name)
+		if err != nil {
+			return nil, err
+		}
+		if !c.Exists() {
+			return nil, fmt.Errorf("context `%s` not found, please add it first", name)
+		}
+		return c, nil
+	}
+
+	current, err := ReadCurrentContext()
+	if err != nil {
+		return nil, err
+	}
+	return NewContext(current)
+}
+
+// ListContexts returns the names of all the contexts, sorted
+func ListContexts() ([]string, error) {
+	dir, err := contextsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("read contexts fail! %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// AddContext creates the dir of a new context
+func AddContext(name string) (*Context, error) {
+	c, err := NewContext(name)
+	if err != nil {
+		return nil, err
+	}
+	if c.Exists() {
+		return nil, fmt.Errorf("context `%s` already exists", name)
+	}
+
+	err = os.MkdirAll(c.dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("create context fail! %w", err)
+	}
+	return c, nil
+}
+
+// DeleteContext removes the context and all its files, and unset the current context if it's the deleted one
+func DeleteContext(name string) error {
+	c, err := NewContext(name)
+	if err != nil {
+		return err
+	}
+	if !c.Exists() {
+		return fmt.Errorf("context `%s` not found", name)
+	}
+
+	err = os.RemoveAll(c.dir)
+	if err != nil {
+		return fmt.Errorf("delete context fail! %w", err)
+	}
+
+	current, err := ReadCurrentContext()
+	if err != nil {
+		return err
+	}
+	if current == name {
+		return RemoveCurrentContext()
+	}
+	return nil
+}
+
+// RenameContext renames the context, and follow the current context if it's the renamed one
+func RenameContext(oldName, newName string) error {
+	oldContext, err := NewContext(oldName)
+	if err != nil {
+		return err
+	}
+	if !oldContext.Exists() {
+		return fmt.Errorf("context `%s` not found", oldName)
+	}
+
+	newContext, err := NewContext(newName)
+	if err != nil {
+		return err
+	}
+	if newContext.Exists() {
+		return fmt.Errorf("context `%s` already exists", newName)
+	}
+
+	err = os.Rename(oldContext.dir, newContext.dir)
+	if err != nil {
+		return fmt.Errorf("rename context fail! %w", err)
+	}
+
+	current, err := ReadCurrentContext()
+	if err != nil {
+		return err
+	}
+	if current == oldName {
+		return WriteCurrentContext(newName)
+	}
+	return nil
+}
+
+// ReadCurrentContext returns the name of the current context, default if not set
+func ReadCurrentContext() (string, error) {
+	root, err := rootDir()
+	if err != nil {
+		return "", err
+	}
+
+	dat, err := ioutil.ReadFile(filepath.Join(root, currentContextFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DefaultContextName, nil
+		}
+		return "", fmt.Errorf("read current context fail! %w", err)
+	}
+
+	name := strings.TrimSpace(string(dat))
+	if name == "" {
+		return DefaultContextName, nil
+	}
+	return name, nil
+}
+
+// WriteCurrentContext sets the current context, the context should exist
+func WriteCurrentContext(name string) error {
+	c, err := NewContext(name)
+	if err != nil {
+		return err
+	}
+	if !c.Exists() {
+		return fmt.Errorf("context `%s` not found", name)
+	}
+
+	root, err := rootDir()
+	if err != nil {
+		return err
+	}
+
+	err = writeFile(filepath.Join(root, currentContextFile), name)
+	if err != nil {
+		return fmt.Errorf("write current context fail! %w", err)
+	}
+	return nil
+}
+
+// RemoveCurrentContext unset the current context, will fallback to default
+func RemoveCurrentContext() error {
+	root, err := rootDir()
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filepath.Join(root, currentContextFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ImportLegacyFiles copies the files written into the dir by the old versions(before the contexts),
+// e.g. .credential/.use, into the context, returns the imported ones; the legacy files are kept,
+// the ones already exist in the context are not overwritten and returned as skipped
+func (c *Context) ImportLegacyFiles(dir string, names ...string) (imported, skipped []string, err error) {
+	for _, name := range names {
+		dat, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return imported, skipped, fmt.Errorf("read legacy file %s fail! %w", name, err)
+		}
+
+		if _, err = os.Stat(c.Path(name)); err == nil {
+			skipped = append(skipped, name)
+			continue
+		}
+		// NOTE: the credential is still the legacy format, it's migrated on the first read
+		if err = writePrivateFile(c.Path(name), dat); err != nil {
+			return imported, skipped, fmt.Errorf("import legacy file %s fail! %w", name, err)
+		}
+		imported = append(imported, name)
+	}
+	return imported, skipped, nil
+}
+
+// writeFile creates the parent dir if not exists, then writes the content into the file
+func writeFile(file string, content string) error {
+	err := os.MkdirAll(filepath.Dir(file), 0o700)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.WriteString(content)
+	return err
+}
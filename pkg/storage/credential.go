@@ -12,10 +12,15 @@
 package storage
 
 import (
+	"bytes"
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -24,136 +29,346 @@ import (
 	"github.com/TencentBlueKing/gopkg/cryptography"
 )
 
-// TODO: encrypted credential
-
 const (
-	cryptoKey   = "C4QSNKR4GNPIZAH3B0RPWAIV29E7QZ66"
-	aesGcmNonce = "KC9DvYrNGnPW"
+	credentialVersion = 2
+
+	// DefaultCredentialTTL is the default lifetime of the credential
+	DefaultCredentialTTL = time.Hour
 )
 
-func newCrypto() (cryptography.Crypto, error) {
-	c, err := cryptography.NewAESGcm([]byte(cryptoKey), []byte(aesGcmNonce))
-	if err != nil {
-		return nil, fmt.Errorf("cryptos key error: %w", err)
+// credentialFile is the content of the credential file, only the app_secret is protected by the backend,
+// the host/app_code/expired_at are bound to the sealed secret as the additional data of AES-GCM
+type credentialFile struct {
+	Version int    `json:"version"`
+	Backend string `json:"backend"`
+	// the random identity of the credential, used as the account of the keyring
+	Account   string       `json:"account"`
+	Host      string       `json:"host"`
+	AppCode   string       `json:"app_code"`
+	ExpiredAt int64        `json:"expired_at"`
+	Secret    sealedSecret `json:"secret"`
+	// the tls settings of the https host, omitted if not set
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+func (f *credentialFile) aad() []byte {
+	aad := fmt.Sprintf("%d|%s|%s|%s|%d", f.Version, f.Account, f.Host, f.AppCode, f.ExpiredAt)
+	// NOTE: the tls settings are bound too, e.g. the insecure mode can not be turned on by editing the file
+	if f.TLS != nil {
+		aad += fmt.Sprintf("|%s|%t|%s|%s", f.TLS.CAFile, f.TLS.InsecureSkipVerify, f.TLS.CertFile, f.TLS.KeyFile)
 	}
-	return c, nil
+	return []byte(aad)
+}
+
+// TLSConfig is the tls settings of the https IAM deployment, stored with the login and reused by the later commands
+type TLSConfig struct {
+	// CAFile is the ca bundle to verify the server
+	CAFile             string `json:"ca_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+	// CertFile and KeyFile are the client certificate, for the gateway requires mTLS
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+}
+
+// Empty returns true if nothing set
+func (t TLSConfig) Empty() bool {
+	return t == TLSConfig{}
 }
 
-func encryptToBase64(c cryptography.Crypto, plaintext string) string {
-	encryptedText := c.Encrypt(conv.StringToBytes(plaintext))
-	return base64.StdEncoding.EncodeToString(encryptedText)
+type Credential struct {
+	file    string
+	backend string
+	ttl     time.Duration
+	tls     TLSConfig
 }
 
-func decryptFromBase64(c cryptography.Crypto, encryptedTextB64 string) (plainText string, err error) {
-	var encryptedText []byte
-	encryptedText, err = base64.StdEncoding.DecodeString(encryptedTextB64)
+func NewCredential(file string) *Credential {
+	return &Credential{
+		file:    file,
+		backend: CredentialBackendAuto,
+		ttl:     DefaultCredentialTTL,
+	}
+}
+
+// SetBackend sets the backend used by Write, the one used by Read is recorded in the file
+func (c *Credential) SetBackend(name string) error {
+	if name != CredentialBackendAuto {
+		if _, err := newCredentialBackend(name); err != nil {
+			return err
+		}
+	}
+	c.backend = name
+	return nil
+}
+
+// SetTTL sets the lifetime of the credential written by Write
+func (c *Credential) SetTTL(ttl time.Duration) error {
+	if ttl < time.Second {
+		return fmt.Errorf("invalid ttl %s, should be at least 1s", ttl)
+	}
+	c.ttl = ttl
+	return nil
+}
+
+// SetTLS sets the tls settings written by Write
+func (c *Credential) SetTLS(tls TLSConfig) {
+	c.tls = tls
+}
+
+func (c *Credential) Write(host, appCode, appSecret string) error {
+	return c.write(host, appCode, appSecret, time.Now().Add(c.ttl).Unix())
+}
+
+func (c *Credential) write(host, appCode, appSecret string, expiredAt int64) error {
+	accountBytes := make([]byte, 16)
+	if _, err := rand.Read(accountBytes); err != nil {
+		return err
+	}
+
+	f := credentialFile{
+		Version:   credentialVersion,
+		Account:   hex.EncodeToString(accountBytes),
+		Host:      host,
+		AppCode:   appCode,
+		ExpiredAt: expiredAt,
+	}
+	if !c.tls.Empty() {
+		tls := c.tls
+		f.TLS = &tls
+	}
+
+	// the secret of the old credential in keyring should be removed
+	old, _ := c.readFile()
+
+	var err error
+	f.Backend, f.Secret, err = c.seal(&f, appSecret)
 	if err != nil {
-		return
+		return fmt.Errorf("encrypt credential fail! %w", err)
 	}
 
-	var plaintextBytes []byte
-	plaintextBytes, err = c.Decrypt(encryptedText)
+	data, err := json.MarshalIndent(f, "", "  ")
 	if err != nil {
-		return
+		return err
+	}
+	err = writePrivateFile(c.file, data)
+	if err != nil {
+		return fmt.Errorf("write credential fail! %w", err)
 	}
 
-	return conv.BytesToString(plaintextBytes), err
+	if old != nil {
+		removeSecret(old)
+	}
+	return nil
 }
 
-func encryptCredential(host, appCode, appSecret string, expire int64) (string, error) {
-	plain := fmt.Sprintf("%s,%s,%s,%d", host, appCode, appSecret, expire)
-	c, err := newCrypto()
-	if err != nil {
-		return "", err
+// seal protects the secret by the backend, auto: the keyring if available, otherwise the encrypted file
+func (c *Credential) seal(f *credentialFile, secret string) (string, sealedSecret, error) {
+	names := []string{c.backend}
+	if c.backend == CredentialBackendAuto {
+		names = []string{CredentialBackendFile}
+		if _, ok := systemKeyring(); ok {
+			names = []string{CredentialBackendKeyring, CredentialBackendFile}
+		}
+	}
+
+	var err error
+	for _, name := range names {
+		var b credentialBackend
+		b, err = newCredentialBackend(name)
+		if err != nil {
+			continue
+		}
+
+		var s sealedSecret
+		// NOTE: the keyring may be unavailable even the tool exists, e.g. no Secret Service in ssh session
+		s, err = b.seal(f.Account, []byte(secret), f.aad())
+		if err == nil {
+			return name, s, nil
+		}
 	}
-	return encryptToBase64(c, plain), nil
+	return "", sealedSecret{}, err
 }
 
-func decryptCredential(cs string) (host, appCode, appSecret string, expiration int64, err error) {
-	var c cryptography.Crypto
-	c, err = newCrypto()
+func (c *Credential) Read() (host, appCode, appSecret string, err error) {
+	f, err := c.readFile()
 	if err != nil {
 		return
 	}
+	if f == nil {
+		return c.readLegacy()
+	}
 
-	var credential string
-	credential, err = decryptFromBase64(c, cs)
+	if time.Now().Unix() > f.ExpiredAt {
+		err = fmt.Errorf("credential expires, please login again")
+		return
+	}
+
+	b, err := newCredentialBackend(f.Backend)
 	if err != nil {
+		err = fmt.Errorf("read credential fail! %w", err)
 		return
 	}
+	secret, err := b.open(f.Account, f.Secret, f.aad())
+	if err != nil {
+		err = fmt.Errorf("decrypt credential fail! %w", err)
+		return
+	}
+	return f.Host, f.AppCode, string(secret), nil
+}
 
-	parts := strings.Split(credential, ",")
-	if len(parts) != 4 {
-		err = fmt.Errorf("invalid credential")
+// ReadInfo returns the non-secret info of the credential without decrypting, e.g. for listing
+func (c *Credential) ReadInfo() (host, appCode string, expiredAt int64, err error) {
+	f, err := c.readFile()
+	if err != nil {
+		return
+	}
+	if f == nil {
+		host, appCode, _, expiredAt, err = c.decryptLegacy()
 		return
 	}
+	return f.Host, f.AppCode, f.ExpiredAt, nil
+}
 
-	host = parts[0]
-	appCode = parts[1]
-	appSecret = parts[2]
+// ReadTLS returns the tls settings of the credential without decrypting, empty if not set,
+// NOTE: the settings are verified with the secret by Read
+func (c *Credential) ReadTLS() (TLSConfig, error) {
+	f, err := c.readFile()
+	if err != nil || f == nil || f.TLS == nil {
+		return TLSConfig{}, err
+	}
+	return *f.TLS, nil
+}
 
-	expiration, err = strconv.ParseInt(parts[3], 10, 64)
+// readFile returns the credential file, nil if it's the legacy format
+func (c *Credential) readFile() (*credentialFile, error) {
+	dat, err := ioutil.ReadFile(c.file)
 	if err != nil {
-		err = fmt.Errorf("invalid expiration")
-		return
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("please login first")
+		}
+		return nil, fmt.Errorf("read credential fail! %w", err)
 	}
-	return
+
+	dat = bytes.TrimSpace(dat)
+	if !bytes.HasPrefix(dat, []byte("{")) {
+		return nil, nil
+	}
+
+	var f credentialFile
+	err = json.Unmarshal(dat, &f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential! %w", err)
+	}
+	if f.Version != credentialVersion {
+		return nil, fmt.Errorf("unsupported credential version %d, please login again", f.Version)
+	}
+	return &f, nil
 }
 
-type Credential struct {
-	file string
+func (c *Credential) RemoveCredential() error {
+	if f, err := c.readFile(); err == nil && f != nil {
+		removeSecret(f)
+	}
+	return os.Remove(c.file)
 }
 
-func NewCredential(file string) *Credential {
-	return &Credential{
-		file: file,
+func removeSecret(f *credentialFile) {
+	if b, err := newCredentialBackend(f.Backend); err == nil {
+		_ = b.remove(f.Account)
 	}
 }
 
-func (c *Credential) Write(host, appCode, appSecret string) error {
-	f, err := os.Create(c.file)
+// writePrivateFile writes the file with 0600 permissions atomically, the dir is created with 0700
+func writePrivateFile(file string, data []byte) error {
+	dir := filepath.Dir(file)
+	err := os.MkdirAll(dir, 0o700)
 	if err != nil {
-		return fmt.Errorf("create credential file fail! %w", err)
+		return err
 	}
-	defer f.Close()
 
-	ts := time.Now().Unix()
-	expire := ts + 60*60
-
-	cs, err := encryptCredential(host, appCode, appSecret, expire)
+	// NOTE: the temp file is created with 0600
+	tmp, err := ioutil.TempFile(dir, filepath.Base(file)+".tmp*")
 	if err != nil {
-		return fmt.Errorf("encrypt credential fail! %w", err)
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
 	}
-	_, err = f.WriteString(cs)
 	if err != nil {
-		return fmt.Errorf("write credential fail! %w", err)
+		return err
 	}
 
-	return nil
+	err = os.Chmod(tmp.Name(), 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
 }
 
-func (c *Credential) Read() (host, appCode, appSecret string, err error) {
-	if _, err = os.Stat(c.file); os.IsNotExist(err) {
-		err = fmt.Errorf("please login first")
+// the legacy credential(before version 2) is encrypted by the hard-coded key and nonce,
+// it's only decrypted for migration, and rewritten by the default backend once read
+
+const (
+	legacyCryptoKey   = "C4QSNKR4GNPIZAH3B0RPWAIV29E7QZ66"
+	legacyAESGcmNonce = "KC9DvYrNGnPW"
+)
+
+func (c *Credential) readLegacy() (host, appCode, appSecret string, err error) {
+	var expiration int64
+	host, appCode, appSecret, expiration, err = c.decryptLegacy()
+	if err != nil {
+		return
+	}
+	if time.Now().Unix() > expiration {
+		err = fmt.Errorf("credential expires, please login again")
 		return
 	}
 
+	// migrate, the credential is still usable if fail
+	_ = c.write(host, appCode, appSecret, expiration)
+	return
+}
+
+func (c *Credential) decryptLegacy() (host, appCode, appSecret string, expiration int64, err error) {
 	dat, err := ioutil.ReadFile(c.file)
 	if err != nil {
 		err = fmt.Errorf("read credential fail! %w", err)
 		return
 	}
 
-	var expiration int64
-	host, appCode, appSecret, expiration, err = decryptCredential(string(dat))
-	if time.Now().Unix() > expiration {
-		err = fmt.Errorf("credential expires, please login again")
+	crypto, err := cryptography.NewAESGcm([]byte(legacyCryptoKey), []byte(legacyAESGcmNonce))
+	if err != nil {
 		return
 	}
 
-	return
-}
+	encrypted, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(dat)))
+	if err != nil {
+		err = fmt.Errorf("invalid credential")
+		return
+	}
+	plain, err := crypto.Decrypt(encrypted)
+	if err != nil {
+		err = fmt.Errorf("invalid credential")
+		return
+	}
 
-func (c *Credential) RemoveCredential() error {
-	return os.Remove(c.file)
+	parts := strings.Split(conv.BytesToString(plain), ",")
+	if len(parts) != 4 {
+		err = fmt.Errorf("invalid credential")
+		return
+	}
+
+	host = parts[0]
+	appCode = parts[1]
+	appSecret = parts[2]
+
+	expiration, err = strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		err = fmt.Errorf("invalid expiration")
+		return
+	}
+	return
 }
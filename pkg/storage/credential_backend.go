@@ -0,0 +1,309 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// the backends to protect the app_secret of the credential
+const (
+	// CredentialBackendAuto uses the OS keyring if available, otherwise the encrypted file
+	CredentialBackendAuto = "auto"
+	// CredentialBackendKeyring encrypts the app_secret with the random key stored in the OS keyring
+	// (macOS Keychain / Secret Service)
+	CredentialBackendKeyring = "keyring"
+	// CredentialBackendFile encrypts the app_secret with the per-user random key $HOME/.bk-iam-cli/key
+	CredentialBackendFile = "file"
+	// CredentialBackendPassphrase encrypts the app_secret with the key derived from the passphrase via scrypt
+	CredentialBackendPassphrase = "passphrase"
+)
+
+// CredentialBackends is the supported backends, for the help message
+const CredentialBackends = "auto|keyring|file|passphrase"
+
+const (
+	keyFileName = "key"
+	keySize     = 32
+
+	// the scrypt parameters recommended for interactive logins
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	saltSize = 16
+)
+
+// PassphraseFunc returns the passphrase of the passphrase backend, reads $BK_IAM_CLI_PASSPHRASE by default,
+// the command line may replace it to prompt the user
+var PassphraseFunc = func() ([]byte, error) {
+	if p := os.Getenv("BK_IAM_CLI_PASSPHRASE"); p != "" {
+		return []byte(p), nil
+	}
+	return nil, errors.New("passphrase required, please set the env BK_IAM_CLI_PASSPHRASE")
+}
+
+// sealedSecret is the protected app_secret stored in the credential file
+type sealedSecret struct {
+	// the salt of scrypt, only for the passphrase backend
+	Salt string `json:"salt,omitempty"`
+	// base64(nonce + ciphertext)
+	Data string `json:"data,omitempty"`
+}
+
+// credentialBackend protects the app_secret of the credential,
+// the account is the identity of the credential, the aad is bound to the ciphertext to prevent tampering
+type credentialBackend interface {
+	seal(account string, secret, aad []byte) (sealedSecret, error)
+	open(account string, s sealedSecret, aad []byte) ([]byte, error)
+	remove(account string) error
+}
+
+func newCredentialBackend(name string) (credentialBackend, error) {
+	switch name {
+	case CredentialBackendKeyring:
+		kr, ok := systemKeyring()
+		if !ok {
+			return nil, errors.New("the OS keyring is not available")
+		}
+		return &keyringBackend{keyring: kr}, nil
+	case CredentialBackendFile:
+		return &fileBackend{}, nil
+	case CredentialBackendPassphrase:
+		return &passphraseBackend{}, nil
+	}
+	return nil, fmt.Errorf("unsupported credential backend `%s`, should be one of %s", name, CredentialBackends)
+}
+
+// keyringBackend stores the random key in the OS keyring, the app_secret is encrypted with it in the credential file,
+// so the metadata is bound by AES-GCM the same as the other backends
+type keyringBackend struct {
+	keyring keyring
+}
+
+func (b *keyringBackend) seal(account string, secret, aad []byte) (sealedSecret, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return sealedSecret{}, err
+	}
+	data, err := encrypt(key, secret, aad)
+	if err != nil {
+		return sealedSecret{}, err
+	}
+	if err = b.keyring.Set(account, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return sealedSecret{}, err
+	}
+	return sealedSecret{Data: data}, nil
+}
+
+func (b *keyringBackend) open(account string, s sealedSecret, aad []byte) ([]byte, error) {
+	encoded, err := b.keyring.Get(account)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != keySize {
+		return nil, errors.New("invalid key in the keyring")
+	}
+	return decrypt(key, s.Data, aad)
+}
+
+func (b *keyringBackend) remove(account string) error {
+	return b.keyring.Delete(account)
+}
+
+// fileBackend encrypts the app_secret with the per-user random key
+type fileBackend struct{}
+
+func (b *fileBackend) seal(account string, secret, aad []byte) (sealedSecret, error) {
+	key, err := userKey(true)
+	if err != nil {
+		return sealedSecret{}, err
+	}
+	data, err := encrypt(key, secret, aad)
+	if err != nil {
+		return sealedSecret{}, err
+	}
+	return sealedSecret{Data: data}, nil
+}
+
+func (b *fileBackend) open(account string, s sealedSecret, aad []byte) ([]byte, error) {
+	key, err := userKey(false)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(key, s.Data, aad)
+}
+
+func (b *fileBackend) remove(account string) error {
+	return nil
+}
+
+// passphraseBackend encrypts the app_secret with the key derived from the passphrase,
+// the passphrase is asked only once in a process
+type passphraseBackend struct{}
+
+var passphraseCache struct {
+	sync.Mutex
+	passphrase []byte
+}
+
+func passphrase() ([]byte, error) {
+	passphraseCache.Lock()
+	defer passphraseCache.Unlock()
+
+	if passphraseCache.passphrase != nil {
+		return passphraseCache.passphrase, nil
+	}
+	p, err := PassphraseFunc()
+	if err != nil {
+		return nil, err
+	}
+	if len(p) == 0 {
+		return nil, errors.New("the passphrase should not be empty")
+	}
+	passphraseCache.passphrase = p
+	return p, nil
+}
+
+func deriveKey(passphrase, salt []byte) ([]byte, error) {
+	return scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, keySize)
+}
+
+func (b *passphraseBackend) seal(account string, secret, aad []byte) (sealedSecret, error) {
+	p, err := passphrase()
+	if err != nil {
+		return sealedSecret{}, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err = io.ReadFull(rand.Reader, salt); err != nil {
+		return sealedSecret{}, err
+	}
+	key, err := deriveKey(p, salt)
+	if err != nil {
+		return sealedSecret{}, err
+	}
+
+	data, err := encrypt(key, secret, aad)
+	if err != nil {
+		return sealedSecret{}, err
+	}
+	return sealedSecret{Salt: base64.StdEncoding.EncodeToString(salt), Data: data}, nil
+}
+
+func (b *passphraseBackend) open(account string, s sealedSecret, aad []byte) ([]byte, error) {
+	p, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(s.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %w", err)
+	}
+	key, err := deriveKey(p, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := decrypt(key, s.Data, aad)
+	if err != nil {
+		return nil, errors.New("decrypt fail, the passphrase may be wrong")
+	}
+	return secret, nil
+}
+
+func (b *passphraseBackend) remove(account string) error {
+	return nil
+}
+
+// userKey returns the per-user random key, create it if not exists and create is true
+func userKey(create bool) ([]byte, error) {
+	root, err := rootDir()
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(root, keyFileName)
+
+	key, err := ioutil.ReadFile(file)
+	if err == nil {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("invalid key file %s", file)
+		}
+		// NOTE: the key may be copied from other place with loose permissions
+		_ = os.Chmod(file, 0o600)
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("read key file fail! %w", err)
+	}
+
+	key = make([]byte, keySize)
+	if _, err = io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	if err = writePrivateFile(file, key); err != nil {
+		return nil, fmt.Errorf("write key file fail! %w", err)
+	}
+	return key, nil
+}
+
+// encrypt encrypts with AES-GCM and a random nonce, returns base64(nonce + ciphertext)
+func encrypt(key, plaintext, aad []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, aad)), nil
+}
+
+func decrypt(key []byte, data string, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	if len(b) < gcm.NonceSize() {
+		return nil, errors.New("invalid ciphertext")
+	}
+	return gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
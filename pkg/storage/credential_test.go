@@ -0,0 +1,391 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TencentBlueKing/gopkg/cryptography"
+	"github.com/mitchellh/go-homedir"
+)
+
+func setupHome(t *testing.T) string {
+	homedir.DisableCache = true
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// no keyring in tests unless stubbed
+	old := systemKeyring
+	systemKeyring = func() (keyring, bool) { return nil, false }
+	t.Cleanup(func() { systemKeyring = old })
+	return home
+}
+
+func readCredentialFile(t *testing.T, file string) credentialFile {
+	dat, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f credentialFile
+	if err = json.Unmarshal(dat, &f); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func assertPerm(t *testing.T, file string, perm os.FileMode) {
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != perm {
+		t.Errorf("%s: perm = %o, want %o", file, info.Mode().Perm(), perm)
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	home := setupHome(t)
+	file := filepath.Join(home, ".bk-iam-cli", "contexts", "default", ".credential")
+
+	c := NewCredential(file)
+	if err := c.Write("http://iam", "bk_iam", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	host, appCode, secret, err := c.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "http://iam" || appCode != "bk_iam" || secret != "s3cret" {
+		t.Errorf("Read() = %s %s %s", host, appCode, secret)
+	}
+
+	assertPerm(t, file, 0o600)
+	assertPerm(t, filepath.Join(home, ".bk-iam-cli", keyFileName), 0o600)
+
+	first := readCredentialFile(t, file)
+	if first.Backend != CredentialBackendFile {
+		t.Errorf("backend = %s, want file", first.Backend)
+	}
+	if strings.Contains(first.Secret.Data, "s3cret") {
+		t.Error("secret should be encrypted")
+	}
+
+	// random nonce per write
+	if err = c.Write("http://iam", "bk_iam", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	if second := readCredentialFile(t, file); second.Secret.Data == first.Secret.Data {
+		t.Error("ciphertext should differ between writes")
+	}
+}
+
+func TestCredentialTampered(t *testing.T) {
+	tampers := map[string]func(f *credentialFile){
+		"host":      func(f *credentialFile) { f.Host = "http://evil" },
+		"app_code":  func(f *credentialFile) { f.AppCode = "evil" },
+		"expiredAt": func(f *credentialFile) { f.ExpiredAt += 3600 },
+		"insecure":  func(f *credentialFile) { f.TLS.InsecureSkipVerify = true },
+		"ca_file":   func(f *credentialFile) { f.TLS.CAFile = "/tmp/evil.pem" },
+		"tls":       func(f *credentialFile) { f.TLS = nil },
+	}
+	for _, backend := range []string{CredentialBackendFile, CredentialBackendPassphrase, CredentialBackendKeyring} {
+		for name, tamper := range tampers {
+			t.Run(backend+"/"+name, func(t *testing.T) {
+				home := setupHome(t)
+				file := filepath.Join(home, "credential")
+				setPassphrase(t, "correct horse")
+				kr := memKeyring{}
+				systemKeyring = func() (keyring, bool) { return kr, true }
+
+				c := NewCredential(file)
+				if err := c.SetBackend(backend); err != nil {
+					t.Fatal(err)
+				}
+				c.SetTLS(TLSConfig{CAFile: "/etc/iam/ca.pem"})
+				if err := c.Write("https://iam", "bk_iam", "s3cret"); err != nil {
+					t.Fatal(err)
+				}
+				if _, _, _, err := c.Read(); err != nil {
+					t.Fatal(err)
+				}
+
+				// the metadata is bound to the sealed secret
+				f := readCredentialFile(t, file)
+				if f.Backend != backend {
+					t.Fatalf("backend = %s, want %s", f.Backend, backend)
+				}
+				tamper(&f)
+				dat, _ := json.Marshal(f)
+				if err := ioutil.WriteFile(file, dat, 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if _, _, _, err := c.Read(); err == nil {
+					t.Errorf("Read() should fail if the %s is tampered", name)
+				}
+			})
+		}
+	}
+}
+
+func TestCredentialTLS(t *testing.T) {
+	home := setupHome(t)
+	file := filepath.Join(home, "credential")
+
+	c := NewCredential(file)
+	if err := c.Write("http://iam", "bk_iam", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	if f := readCredentialFile(t, file); f.TLS != nil {
+		t.Errorf("the tls should be omitted if not set, got %+v", f.TLS)
+	}
+
+	want := TLSConfig{CAFile: "/etc/iam/ca.pem", CertFile: "/etc/iam/client.pem", KeyFile: "/etc/iam/client.key"}
+	c.SetTLS(want)
+	if err := c.Write("https://iam", "bk_iam", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := NewCredential(file).ReadTLS(); err != nil || got != want {
+		t.Errorf("ReadTLS() = %+v %v, want %+v", got, err, want)
+	}
+	if _, _, _, err := c.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tls settings are bound to the ciphertext
+	f := readCredentialFile(t, file)
+	f.TLS.InsecureSkipVerify = true
+	dat, _ := json.Marshal(f)
+	if err := ioutil.WriteFile(file, dat, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := c.Read(); err == nil {
+		t.Error("Read() should fail if the tls settings are tampered")
+	}
+}
+
+func TestCredentialExpired(t *testing.T) {
+	home := setupHome(t)
+	c := NewCredential(filepath.Join(home, "credential"))
+	if err := c.write("http://iam", "bk_iam", "s3cret", time.Now().Unix()-1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := c.Read(); err == nil {
+		t.Error("Read() should fail if expired")
+	}
+	if host, _, _, err := c.ReadInfo(); err != nil || host != "http://iam" {
+		t.Errorf("ReadInfo() = %s, %v", host, err)
+	}
+}
+
+func setPassphrase(t *testing.T, p string) {
+	old := PassphraseFunc
+	PassphraseFunc = func() ([]byte, error) { return []byte(p), nil }
+	passphraseCache.passphrase = nil
+	t.Cleanup(func() {
+		PassphraseFunc = old
+		passphraseCache.passphrase = nil
+	})
+}
+
+func TestPassphraseBackend(t *testing.T) {
+	home := setupHome(t)
+	file := filepath.Join(home, "credential")
+
+	setPassphrase(t, "correct horse")
+	c := NewCredential(file)
+	if err := c.SetBackend(CredentialBackendPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write("http://iam", "bk_iam", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, secret, err := NewCredential(file).Read(); err != nil || secret != "s3cret" {
+		t.Errorf("Read() = %s, %v", secret, err)
+	}
+	if f := readCredentialFile(t, file); f.Backend != CredentialBackendPassphrase || f.Secret.Salt == "" {
+		t.Errorf("unexpected credential file %+v", f)
+	}
+
+	setPassphrase(t, "wrong")
+	if _, _, _, err := NewCredential(file).Read(); err == nil {
+		t.Error("Read() should fail with the wrong passphrase")
+	}
+}
+
+type memKeyring map[string]string
+
+func (k memKeyring) Set(account, secret string) error {
+	k[account] = secret
+	return nil
+}
+
+func (k memKeyring) Get(account string) (string, error) {
+	s, ok := k[account]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return s, nil
+}
+
+func (k memKeyring) Delete(account string) error {
+	delete(k, account)
+	return nil
+}
+
+func TestKeyringBackend(t *testing.T) {
+	home := setupHome(t)
+	file := filepath.Join(home, "credential")
+
+	kr := memKeyring{}
+	systemKeyring = func() (keyring, bool) { return kr, true }
+
+	c := NewCredential(file)
+	if err := c.Write("http://iam", "bk_iam", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	f := readCredentialFile(t, file)
+	if f.Backend != CredentialBackendKeyring || f.Secret.Data == "" {
+		t.Errorf("auto should use the keyring, got %+v", f)
+	}
+	// only the key is in the keyring, the secret is encrypted in the file
+	if key, ok := kr[f.Account]; !ok || strings.Contains(key, "s3cret") || strings.Contains(f.Secret.Data, "s3cret") {
+		t.Errorf("keyring = %v, data = %s", kr, f.Secret.Data)
+	}
+	if _, _, secret, err := c.Read(); err != nil || secret != "s3cret" {
+		t.Errorf("Read() = %s, %v", secret, err)
+	}
+
+	// the old secret is removed on rewrite
+	if err := c.Write("http://iam", "bk_iam", "s3cret2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := kr[f.Account]; ok || len(kr) != 1 {
+		t.Errorf("old secret should be removed, keyring = %v", kr)
+	}
+
+	if err := c.RemoveCredential(); err != nil {
+		t.Fatal(err)
+	}
+	if len(kr) != 0 {
+		t.Errorf("secret should be removed, keyring = %v", kr)
+	}
+}
+
+func TestLegacyMigration(t *testing.T) {
+	home := setupHome(t)
+	file := filepath.Join(home, "credential")
+
+	crypto, err := cryptography.NewAESGcm([]byte(legacyCryptoKey), []byte(legacyAESGcmNonce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := fmt.Sprintf("http://iam,bk_iam,s3cret,%d", time.Now().Unix()+60)
+	legacy := base64.StdEncoding.EncodeToString(crypto.Encrypt([]byte(plain)))
+	if err = ioutil.WriteFile(file, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	host, appCode, secret, err := NewCredential(file).Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host != "http://iam" || appCode != "bk_iam" || secret != "s3cret" {
+		t.Errorf("Read() = %s %s %s", host, appCode, secret)
+	}
+
+	// rewritten with the new format
+	if f := readCredentialFile(t, file); f.Version != credentialVersion || f.Backend != CredentialBackendFile {
+		t.Errorf("credential not migrated: %+v", f)
+	}
+	assertPerm(t, file, 0o600)
+	if _, _, secret, err = NewCredential(file).Read(); err != nil || secret != "s3cret" {
+		t.Errorf("Read() after migration = %s, %v", secret, err)
+	}
+}
+
+func TestSetBackend(t *testing.T) {
+	setupHome(t)
+	c := NewCredential("credential")
+	if err := c.SetBackend("plain"); err == nil {
+		t.Error("SetBackend(plain) should fail")
+	}
+	if err := c.SetBackend(CredentialBackendKeyring); err == nil {
+		t.Error("SetBackend(keyring) should fail without keyring")
+	}
+}
+
+func TestImportLegacyFiles(t *testing.T) {
+	setupHome(t)
+	dir := t.TempDir()
+
+	// the files written by the old versions into the working dir
+	crypto, err := cryptography.NewAESGcm([]byte(legacyCryptoKey), []byte(legacyAESGcmNonce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := fmt.Sprintf("http://iam,bk_iam,s3cret,%d", time.Now().Unix()+60)
+	legacy := base64.StdEncoding.EncodeToString(crypto.Encrypt([]byte(plain)))
+	if err = ioutil.WriteFile(filepath.Join(dir, ".credential"), []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, ".use"), []byte("bk_paas"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewContext(DefaultContextName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, skipped, err := c.ImportLegacyFiles(dir, ".credential", ".saas-credential", ".use")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(imported, ",") != ".credential,.use" || len(skipped) != 0 {
+		t.Errorf("imported = %v, skipped = %v", imported, skipped)
+	}
+	// copied, the legacy files are kept
+	for _, name := range imported {
+		if _, err = os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("the legacy file %s should be kept, %v", name, err)
+		}
+	}
+
+	assertPerm(t, c.Path(".credential"), 0o600)
+	host, appCode, secret, err := NewCredential(c.Path(".credential")).Read()
+	if err != nil || host != "http://iam" || appCode != "bk_iam" || secret != "s3cret" {
+		t.Errorf("Read() = %s %s %s %v", host, appCode, secret, err)
+	}
+	if system, err := ReadUseSystem(c.Path(".use")); err != nil || system != "bk_paas" {
+		t.Errorf("ReadUseSystem() = %s %v", system, err)
+	}
+
+	// the context has them already, not overwritten
+	if err = ioutil.WriteFile(filepath.Join(dir, ".use"), []byte("bk_sops"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	imported, skipped, err = c.ImportLegacyFiles(dir, ".credential", ".saas-credential", ".use")
+	if err != nil || len(imported) != 0 || strings.Join(skipped, ",") != ".credential,.use" {
+		t.Errorf("imported = %v, skipped = %v, err = %v", imported, skipped, err)
+	}
+	if system, _ := ReadUseSystem(c.Path(".use")); system != "bk_paas" {
+		t.Errorf("the use system of the context should not be overwritten, got %s", system)
+	}
+}
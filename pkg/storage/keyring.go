@@ -0,0 +1,113 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+const keyringService = "bk-iam-cli"
+
+// keyring is the OS keyring, the secret is identified by the account
+type keyring interface {
+	Set(account, secret string) error
+	Get(account string) (string, error)
+	Delete(account string) error
+}
+
+// systemKeyring returns the keyring of the OS, via the command line tools instead of D-Bus:
+// macOS: security(Keychain), linux: secret-tool(Secret Service, from libsecret-tools)
+var systemKeyring = func() (keyring, bool) {
+	switch runtime.GOOS {
+	case "darwin":
+		if _, err := exec.LookPath("security"); err == nil {
+			return macKeychain{}, true
+		}
+	case "linux":
+		if _, err := exec.LookPath("secret-tool"); err == nil {
+			return secretTool{}, true
+		}
+	}
+	return nil, false
+}
+
+// keyringCommand runs the command of the keyring, the secret should only be passed via stdin,
+// the args are visible to all users by `ps`; replaced in tests
+var keyringCommand = runKeyringCommand
+
+func runKeyringCommand(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s fail! %w, %s", name, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
+
+type macKeychain struct{}
+
+func (macKeychain) Set(account, secret string) error {
+	// NOTE: the -w of add-generic-password has no way to read from stdin,
+	// so the command is run in the interactive mode(security -i), read from stdin too
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		quoteSecurityArg(keyringService), quoteSecurityArg(account), quoteSecurityArg(secret))
+	_, err := keyringCommand(command, "security", "-i")
+	return err
+}
+
+// quoteSecurityArg quotes the arg of the interactive mode of security, split by the whitespaces otherwise
+func quoteSecurityArg(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+func (macKeychain) Get(account string) (string, error) {
+	return keyringCommand("", "security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+}
+
+func (macKeychain) Delete(account string) error {
+	_, err := keyringCommand("", "security", "delete-generic-password", "-s", keyringService, "-a", account)
+	return err
+}
+
+type secretTool struct{}
+
+func (secretTool) Set(account, secret string) error {
+	// the secret is read from stdin
+	_, err := keyringCommand(secret, "secret-tool", "store", "--label", keyringService+" "+account,
+		"service", keyringService, "account", account)
+	return err
+}
+
+func (secretTool) Get(account string) (string, error) {
+	secret, err := keyringCommand("", "secret-tool", "lookup", "service", keyringService, "account", account)
+	if err != nil {
+		return "", err
+	}
+	if secret == "" {
+		return "", fmt.Errorf("secret of %s not found in keyring", account)
+	}
+	return secret, nil
+}
+
+func (secretTool) Delete(account string) error {
+	_, err := keyringCommand("", "secret-tool", "clear", "service", keyringService, "account", account)
+	return err
+}
@@ -0,0 +1,58 @@
+/*
+ * TencentBlueKing is pleased to support the open source community by making 蓝鲸智云-权限中心Cli
+ * (BlueKing-IAM-Cli) available.
+ * Copyright (C) 2017-2022 THL A29 Limited, a Tencent company. All rights reserved.
+ * Licensed under the MIT License (the "License"); you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at http://opensource.org/licenses/MIT
+ * Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on
+ * an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyringSecretNotInArgs(t *testing.T) {
+	type call struct {
+		stdin string
+		args  []string
+	}
+	var calls []call
+	old := keyringCommand
+	keyringCommand = func(stdin string, name string, args ...string) (string, error) {
+		calls = append(calls, call{stdin: stdin, args: append([]string{name}, args...)})
+		return "", nil
+	}
+	t.Cleanup(func() { keyringCommand = old })
+
+	secret := `s3cret "quoted" \ key`
+	for _, k := range []keyring{macKeychain{}, secretTool{}} {
+		calls = nil
+		if err := k.Set("default/.credential", secret); err != nil {
+			t.Fatal(err)
+		}
+		if len(calls) != 1 {
+			t.Fatalf("%T: got calls %v", k, calls)
+		}
+		for _, arg := range calls[0].args {
+			if strings.Contains(arg, "s3cret") {
+				t.Errorf("%T: the secret should not be in the args %v", k, calls[0].args)
+			}
+		}
+		if !strings.Contains(calls[0].stdin, "s3cret") {
+			t.Errorf("%T: the secret should be passed via stdin, got %q", k, calls[0].stdin)
+		}
+	}
+
+	// the interactive command of security
+	want := `add-generic-password -U -s "bk-iam-cli" -a "default/.credential" -w "s3cret \"quoted\" \\ key"` + "\n"
+	calls = nil
+	_ = macKeychain{}.Set("default/.credential", secret)
+	if calls[0].stdin != want {
+		t.Errorf("got command %q, want %q", calls[0].stdin, want)
+	}
+}
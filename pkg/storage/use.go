@@ -15,10 +15,17 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
-func WriteUseSystem(system string) error {
-	f, err := os.Create(".use")
+func WriteUseSystem(file string, system string) error {
+	err := os.MkdirAll(filepath.Dir(file), 0o700)
+	if err != nil {
+		return fmt.Errorf("create storage dir fail! %w", err)
+	}
+
+	f, err := os.Create(file)
 	if err != nil {
 		return fmt.Errorf("create storage file fail! %w", err)
 	}
@@ -32,20 +39,20 @@ func WriteUseSystem(system string) error {
 	return nil
 }
 
-func ReadUseSystem() (system string, err error) {
-	if _, err = os.Stat(".use"); os.IsNotExist(err) {
+func ReadUseSystem(file string) (system string, err error) {
+	if _, err = os.Stat(file); os.IsNotExist(err) {
 		err = fmt.Errorf("please use system first")
 		return
 	}
 
-	dat, err := ioutil.ReadFile(".use")
+	dat, err := ioutil.ReadFile(file)
 	if err != nil {
 		err = fmt.Errorf("read use system fail! %w", err)
 		return
 	}
-	return string(dat), nil
+	return strings.TrimSpace(string(dat)), nil
 }
 
-func RemoveUseSystem() error {
-	return os.Remove(".use")
+func RemoveUseSystem(file string) error {
+	return os.Remove(file)
 }
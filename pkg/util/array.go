@@ -23,3 +23,16 @@ func Int64ArrayToString(input []int64, sep string) string {
 	}
 	return strings.Join(b, sep)
 }
+
+// UniqueStrings returns the strings without duplicates, the order is kept
+func UniqueStrings(input []string) []string {
+	seen := make(map[string]struct{}, len(input))
+	result := make([]string, 0, len(input))
+	for _, s := range input {
+		if _, ok := seen[s]; !ok {
+			seen[s] = struct{}{}
+			result = append(result, s)
+		}
+	}
+	return result
+}